@@ -4,15 +4,34 @@ import (
 	"commitcaster/config"
 	"commitcaster/internal/database"
 	"commitcaster/internal/handlers"
+	"commitcaster/internal/health"
+	"commitcaster/internal/jobs"
+	"commitcaster/internal/logging"
+	"commitcaster/internal/mailer"
+	"commitcaster/internal/metrics"
 	"commitcaster/internal/middleware"
+	"commitcaster/internal/models"
+	"commitcaster/internal/pipeline"
+	"commitcaster/internal/ratelimit"
 	"commitcaster/internal/services"
+	"commitcaster/internal/store"
+	"commitcaster/internal/tracing"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"gorm.io/gorm"
 
 	_ "commitcaster/docs" // Swagger docs
 )
@@ -33,27 +52,76 @@ import (
 // @securityDefinitions.apikey BearerAuth
 // @in header
 // @name Authorization
-// @description Type "Bearer" followed by a space and JWT token.
+// @description Type "Bearer" followed by a space and JWT or personal access token.
 
 func main() {
 	// Загружаем конфигурацию
 	cfg := config.Load()
+	logging.Setup()
+
+	// Подкоманда migrate: up, down [N], status
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
 
 	// Проверяем режим работы
 	databaseURL := os.Getenv("DATABASE_URL")
 	isSaaSMode := databaseURL != ""
 
+	// Метрики Prometheus и трейсы с меткой режима работы
+	mode := metrics.ModeSingle
+	if isSaaSMode {
+		mode = metrics.ModeSaaS
+	}
+	metrics.Init(mode)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), mode)
+	if err != nil {
+		fatal("Failed to set up tracing", "error", err)
+	}
+
+	// Фоновая генерация и отправка постов; при остановке сервиса дожидаемся её
+	tracker := jobs.NewTracker()
+
+	// Соединение с БД в SaaS режиме; nil в single-user режиме и с хранилищем в памяти
+	var db *gorm.DB
+
+	// Сохраняет отложенные задачи single-user режима при остановке
+	var stopWebhooks func(context.Context)
+
+	// Проверки /livez и /readyz; провайдеры проверяются только при HEALTH_CHECK_PROVIDERS=true
+	checker := health.New(mode)
+	checker.Ready("jobs", health.Jobs(tracker, envDuration("HEALTH_JOB_STUCK_AFTER", 10*time.Minute)))
+	checkProviders := os.Getenv("HEALTH_CHECK_PROVIDERS") == "true"
+
 	gin.SetMode(gin.ReleaseMode)
-	r := gin.Default()
+	r := gin.New()
+	r.Use(tracing.Middleware(), middleware.RequestID(), middleware.Logger(), gin.Recovery(), metrics.Middleware())
+
+	// Доверенные прокси для определения IP клиента (X-Forwarded-For)
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		if err := r.SetTrustedProxies(strings.Split(proxies, ",")); err != nil {
+			fatal("Invalid TRUSTED_PROXIES", "error", err)
+		}
+	}
 
 	// Swagger documentation (доступен в обоих режимах)
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	// Метрики (доступны в обоих режимах; METRICS_TOKEN закрывает endpoint).
+	// В SaaS режиме метрики без токена не отдаются: сервис доступен из интернета
+	if isSaaSMode && os.Getenv("METRICS_TOKEN") == "" {
+		slog.Warn("METRICS_TOKEN is not set, /metrics is disabled in SaaS mode")
+	} else {
+		r.GET("/metrics", metrics.Handler())
+	}
+
 	// Добавляем CORS middleware
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -62,21 +130,65 @@ func main() {
 	})
 
 	if isSaaSMode {
-		log.Println("🌐 Starting in SaaS mode (multi-user)")
+		slog.Info("Starting in SaaS mode (multi-user)")
+
+		// DATABASE_URL=memory:// — данные в памяти процесса (разработка, демо);
+		// иначе подключаемся к БД
+		var st *store.Store
+		if databaseURL == store.MemoryURL {
+			slog.Warn("Using in-memory store: data is lost on restart")
+			st = store.NewMemory()
+		} else {
+			var err error
+			if db, err = database.Connect(); err != nil {
+				fatal("Failed to connect to database", "error", err)
+			}
 
-		// Подключаемся к БД
-		if err := database.Connect(); err != nil {
-			log.Fatalf("Failed to connect to database: %v", err)
+			// Схема БД должна совпадать с миграциями сборки: они применяются командой
+			// "commitcaster migrate up" или при запуске с MIGRATE_ON_START=true
+			if os.Getenv("MIGRATE_ON_START") == "true" {
+				if _, err := database.MigrateUp(context.Background(), db); err != nil {
+					fatal("Failed to apply migrations", "error", err)
+				}
+			}
+			if err := database.CheckSchemaVersion(context.Background(), db); err != nil {
+				fatal("Refusing to start", "error", err)
+			}
+
+			checker.Ready("database", func(ctx context.Context) error { return database.Ping(ctx, db) })
+			checker.Ready("migrations", func(ctx context.Context) error { return database.CheckSchemaVersion(ctx, db) })
+			st = store.NewGORM(db)
+		}
+		if checkProviders {
+			// Ключи провайдеров у каждого пользователя свои: проверяем только доступность API
+			checker.Ready("telegram", health.Cached(health.Reachable("https://api.telegram.org"), time.Minute))
+			checker.Ready("ai", health.Cached(health.Reachable("https://openrouter.ai/api/v1/models"), time.Minute))
 		}
 
 		// Проверяем JWT_SECRET
 		if os.Getenv("JWT_SECRET") == "" {
-			log.Fatal("JWT_SECRET not set (required for SaaS mode)")
+			fatal("JWT_SECRET not set (required for SaaS mode)")
 		}
 
+		// Администраторы сервиса из ADMIN_EMAILS (аккаунты должны существовать)
+		if err := promoteAdmins(context.Background(), st.Users, os.Getenv("ADMIN_EMAILS")); err != nil {
+			fatal("Failed to set up admins", "error", err)
+		}
+
+		// Rate limiting публичных endpoints: по IP, email и проекту webhook
+		limiter := ratelimit.New(db)
+
 		// API handlers
-		apiHandler := handlers.NewAPIHandler()
-		multiWebhookHandler := handlers.NewMultiUserWebhookHandler()
+		apiHandler := handlers.NewAPIHandler(st, mailer.New(), services.NewGitHubOAuthService(), tracker)
+		// Лимит на проект применяется после проверки токена: случайные токены
+		// ограничиваются только лимитом по IP и не создают новых корзин
+		multiWebhookHandler := handlers.NewMultiUserWebhookHandler(st, tracker, limiter,
+			ratelimit.RuleFromEnv("RATE_LIMIT_WEBHOOK_TOKEN", ratelimit.Rule{Limit: 60, Period: time.Minute}))
+
+		// Продолжаем доставки, прерванные предыдущей остановкой
+		if err := multiWebhookHandler.ResumeInterrupted(context.Background()); err != nil {
+			slog.Error("Failed to resume interrupted deliveries", "error", err)
+		}
 
 		// Public routes
 		r.GET("/health", func(c *gin.Context) {
@@ -85,63 +197,432 @@ func main() {
 				"mode":   "saas",
 			})
 		})
-		r.POST("/api/auth/register", apiHandler.Register)
-		r.POST("/api/auth/login", apiHandler.Login)
+
+		authByIP := middleware.RateLimit(limiter, "auth_ip",
+			ratelimit.RuleFromEnv("RATE_LIMIT_AUTH_IP", ratelimit.Rule{Limit: 30, Period: time.Minute}), middleware.ByIP)
+		loginByEmail := middleware.RateLimit(limiter, "login_email",
+			ratelimit.RuleFromEnv("RATE_LIMIT_LOGIN_EMAIL", ratelimit.Rule{Limit: 10, Period: 15 * time.Minute}), middleware.ByJSONField("email"))
+		mailByEmail := middleware.RateLimit(limiter, "mail_email",
+			ratelimit.RuleFromEnv("RATE_LIMIT_MAIL_EMAIL", ratelimit.Rule{Limit: 3, Period: time.Hour}), middleware.ByJSONField("email"))
+		webhookByIP := middleware.RateLimit(limiter, "webhook_ip",
+			ratelimit.RuleFromEnv("RATE_LIMIT_WEBHOOK_IP", ratelimit.Rule{Limit: 120, Period: time.Minute}), middleware.ByIP)
+
+		authRoutes := r.Group("/api/auth")
+		authRoutes.Use(authByIP)
+		{
+			authRoutes.POST("/register", apiHandler.Register)
+			authRoutes.POST("/login", loginByEmail, apiHandler.Login)
+			authRoutes.POST("/refresh", apiHandler.RefreshToken)
+			authRoutes.POST("/forgot", mailByEmail, apiHandler.ForgotPassword)
+			authRoutes.POST("/reset", apiHandler.ResetPassword)
+			authRoutes.POST("/verify", apiHandler.VerifyEmail)
+			authRoutes.GET("/github", apiHandler.GitHubLogin)
+			authRoutes.GET("/github/callback", apiHandler.GitHubCallback)
+			authRoutes.POST("/2fa/verify", apiHandler.VerifyTwoFactor)
+		}
 
 		// Protected routes (require JWT)
 		protected := r.Group("/api")
-		protected.Use(middleware.AuthMiddleware())
+		protected.Use(middleware.AuthMiddleware(st))
+		{
+			// Управление сессиями и токенами доступно только при входе по паролю
+			session := protected.Group("")
+			session.Use(middleware.RequireSession())
+			{
+				session.POST("/auth/logout", apiHandler.Logout)
+				session.POST("/auth/verify/resend", apiHandler.ResendVerification)
+
+				session.GET("/tokens", apiHandler.ListTokens)
+
+				session.GET("/audit", apiHandler.ListAuditLog)
+				session.GET("/account/export", apiHandler.ExportAccount)
+			}
+
+			// Учётные данные и аккаунт меняет только сам пользователь, не администратор в режиме impersonation
+			own := session.Group("")
+			own.Use(middleware.RequireOwnSession())
+			{
+				own.POST("/auth/logout-all", apiHandler.LogoutAll)
+
+				own.POST("/auth/2fa/enroll", apiHandler.EnrollTwoFactor)
+				own.POST("/auth/2fa/confirm", apiHandler.ConfirmTwoFactor)
+				own.POST("/auth/2fa/disable", apiHandler.DisableTwoFactor)
+				own.POST("/auth/2fa/recovery-codes", apiHandler.RegenerateRecoveryCodes)
+
+				own.POST("/tokens", apiHandler.CreateToken)
+				own.DELETE("/tokens/:id", apiHandler.RevokeToken)
+
+				own.DELETE("/account", apiHandler.DeleteAccount)
+			}
+
+			settingsRead := middleware.RequireScope(models.ScopeSettingsRead)
+			settingsWrite := middleware.RequireScope(models.ScopeSettingsWrite)
+			webhookRead := middleware.RequireScope(models.ScopeWebhookRead)
+			webhookWrite := middleware.RequireScope(models.ScopeWebhookWrite)
+			projectsRead := middleware.RequireScope(models.ScopeProjectsRead)
+			projectsWrite := middleware.RequireScope(models.ScopeProjectsWrite)
+			orgsRead := middleware.RequireScope(models.ScopeOrganizationsRead)
+			orgsWrite := middleware.RequireScope(models.ScopeOrganizationsWrite)
+			usageRead := middleware.RequireScope(models.ScopeUsageRead)
+
+			protected.GET("/settings", settingsRead, apiHandler.GetSettings)
+			protected.PUT("/settings", settingsWrite, apiHandler.UpdateSettings)
+			protected.GET("/webhook", webhookRead, apiHandler.GetWebhookInfo)
+			protected.POST("/webhook/rotate", webhookWrite, apiHandler.RotateWebhookToken)
+			protected.POST("/webhook/secret", webhookWrite, apiHandler.RegenerateWebhookSecret)
+
+			protected.GET("/usage", usageRead, apiHandler.GetUsage)
+			protected.GET("/usage/plans", usageRead, apiHandler.ListPlans)
+
+			protected.GET("/projects", projectsRead, apiHandler.ListProjects)
+			protected.POST("/projects", projectsWrite, apiHandler.CreateProject)
+			protected.GET("/projects/:id", projectsRead, apiHandler.GetProject)
+			protected.PUT("/projects/:id", projectsWrite, apiHandler.UpdateProject)
+			protected.DELETE("/projects/:id", projectsWrite, apiHandler.DeleteProject)
+			protected.POST("/projects/:id/webhook/rotate", projectsWrite, apiHandler.RotateProjectWebhookToken)
+			protected.POST("/projects/:id/webhook/secret", projectsWrite, apiHandler.RegenerateProjectWebhookSecret)
+
+			protected.GET("/organizations", orgsRead, apiHandler.ListOrganizations)
+			protected.POST("/organizations", orgsWrite, apiHandler.CreateOrganization)
+			protected.GET("/organizations/:id", orgsRead, apiHandler.GetOrganization)
+			protected.PUT("/organizations/:id", orgsWrite, apiHandler.UpdateOrganization)
+			protected.DELETE("/organizations/:id", orgsWrite, apiHandler.DeleteOrganization)
+			protected.GET("/organizations/:id/members", orgsRead, apiHandler.ListMembers)
+			protected.POST("/organizations/:id/members", orgsWrite, apiHandler.AddMember)
+			protected.PUT("/organizations/:id/members/:user_id", orgsWrite, apiHandler.UpdateMember)
+			protected.DELETE("/organizations/:id/members/:user_id", orgsWrite, apiHandler.RemoveMember)
+		}
+
+		// Администрирование сервиса
+		admin := r.Group("/api/admin")
+		admin.Use(middleware.AuthMiddleware(st), middleware.RequireAdmin(st))
 		{
-			protected.GET("/settings", apiHandler.GetSettings)
-			protected.PUT("/settings", apiHandler.UpdateSettings)
-			protected.GET("/webhook", apiHandler.GetWebhookInfo)
+			admin.GET("/users", apiHandler.AdminListUsers)
+			admin.GET("/audit", apiHandler.AdminListAuditLog)
+			admin.GET("/users/:id", apiHandler.AdminGetUser)
+			admin.PUT("/users/:id/role", apiHandler.AdminSetRole)
+			admin.PUT("/users/:id/plan", apiHandler.AdminSetPlan)
+			admin.POST("/users/:id/deactivate", apiHandler.AdminDeactivateUser)
+			admin.POST("/users/:id/reactivate", apiHandler.AdminReactivateUser)
+			admin.POST("/users/:id/unlock", apiHandler.AdminUnlockUser)
+			admin.POST("/users/:id/impersonate", apiHandler.AdminImpersonateUser)
+			admin.POST("/users/:id/rotate-tokens", apiHandler.AdminRotateUserTokens)
 		}
 
-		// GitHub webhook endpoint (по токену пользователя)
-		r.POST("/webhook/github/:token", multiWebhookHandler.HandleGitHubWebhook)
+		// GitHub webhook endpoint (по токену проекта или пользователя)
+		r.POST("/webhook/github/:token", webhookByIP, multiWebhookHandler.HandleGitHubWebhook)
 
-		log.Println("📋 API Endpoints:")
-		log.Println("  POST /api/auth/register - Register new user")
-		log.Println("  POST /api/auth/login - Login")
-		log.Println("  GET  /api/settings - Get user settings (protected)")
-		log.Println("  PUT  /api/settings - Update settings (protected)")
-		log.Println("  GET  /api/webhook - Get webhook URL (protected)")
-		log.Println("  POST /webhook/github/:token - GitHub webhook")
-		log.Println("")
-		log.Printf("📖 Swagger UI: http://localhost:%s/swagger/index.html", cfg.Port)
+		logRoute("POST /api/auth/register", "Register new user")
+		logRoute("POST /api/auth/login", "Login")
+		logRoute("POST /api/auth/refresh", "Refresh access token")
+		logRoute("POST /api/auth/forgot, /api/auth/reset", "Password reset")
+		logRoute("POST /api/auth/verify", "Verify email")
+		logRoute("GET /api/auth/github", "Login with GitHub")
+		logRoute("POST /api/auth/2fa/verify", "Second login step (2FA)")
+		logRoute("POST /api/auth/2fa/enroll|confirm|disable|recovery-codes", "Manage 2FA (protected)")
+		logRoute("POST /api/auth/logout", "Log out current session (protected)")
+		logRoute("POST /api/auth/logout-all", "Log out all sessions (protected)")
+		logRoute("GET|POST /api/tokens, DELETE /api/tokens/:id", "Personal access tokens (protected)")
+		logRoute("GET /api/audit", "Audit log (protected)")
+		logRoute("GET /api/account/export", "Export account data (protected)")
+		logRoute("DELETE /api/account", "Delete account (protected)")
+		logRoute("GET /api/settings", "Get user settings (protected)")
+		logRoute("PUT /api/settings", "Update settings (protected)")
+		logRoute("GET /api/webhook", "Get webhook URL (protected)")
+		logRoute("POST /api/webhook/rotate", "Rotate webhook token (protected)")
+		logRoute("POST /api/webhook/secret", "Regenerate webhook secret (protected)")
+		logRoute("GET /api/usage, /api/usage/plans", "Usage counters and quotas (protected)")
+		logRoute("GET /api/projects", "List projects (protected)")
+		logRoute("POST /api/projects", "Create project (protected)")
+		logRoute("GET|PUT|DELETE /api/projects/:id", "Manage project (protected)")
+		logRoute("POST /api/projects/:id/webhook/rotate|secret", "Rotate project webhook token or secret (protected)")
+		logRoute("GET|POST /api/organizations", "List/create organizations (protected)")
+		logRoute("GET|PUT|DELETE /api/organizations/:id", "Manage organization (protected)")
+		logRoute("GET|POST /api/organizations/:id/members", "Manage members (protected)")
+		logRoute("GET /api/admin/users, /api/admin/users/:id", "Users and activity (admin)")
+		logRoute("GET /api/admin/audit", "Full audit log (admin)")
+		logRoute("PUT /api/admin/users/:id/role|plan", "Change role or plan (admin)")
+		logRoute("POST /api/admin/users/:id/deactivate|reactivate|unlock|impersonate|rotate-tokens", "Manage user (admin)")
+		logRoute("POST /webhook/github/:token", "GitHub webhook")
+		if os.Getenv("METRICS_TOKEN") != "" {
+			logRoute("GET /metrics", "Prometheus metrics")
+		}
+		logRoute("GET /livez, /readyz", "Liveness and readiness checks")
+		slog.Info("Swagger UI", "url", fmt.Sprintf("http://localhost:%s/swagger/index.html", cfg.Port))
 
 	} else {
-		log.Println("👤 Starting in single-user mode")
+		slog.Info("Starting in single-user mode")
 
-		// Проверяем обязательные переменные для single-user режима
-		if cfg.TelegramBotToken == "" {
-			log.Fatal("TELEGRAM_BOT_TOKEN не установлен")
+		// Проекты из файла конфигурации (CONFIG_FILE) или один проект из переменных окружения
+		var provider pipeline.Provider
+		var fileProvider *pipeline.FileProvider
+		if cfg.ConfigFile != "" {
+			var err error
+			if fileProvider, err = pipeline.NewFileProvider(cfg.ConfigFile); err != nil {
+				fatal("Failed to load config file", "error", err)
+			}
+			provider = fileProvider
+			slog.Info("Config file loaded", "path", cfg.ConfigFile, "projects", fileProvider.Projects())
+		} else {
+			// Проверяем обязательные переменные для single-user режима
+			if cfg.TelegramBotToken == "" {
+				fatal("TELEGRAM_BOT_TOKEN не установлен")
+			}
+			if cfg.TelegramChannelID == "" {
+				fatal("TELEGRAM_CHANNEL_ID не установлен")
+			}
+			if cfg.GroqAPIKey == "" {
+				fatal("GROQ_API_KEY не установлен")
+			}
+			if cfg.GitHubSecret == "" {
+				slog.Warn("GITHUB_WEBHOOK_SECRET не установлен: подпись webhook не проверяется")
+			}
+			provider = pipeline.NewStatic(cfg)
 		}
-		if cfg.TelegramChannelID == "" {
-			log.Fatal("TELEGRAM_CHANNEL_ID не установлен")
+
+		pendingFile := os.Getenv("PENDING_JOBS_FILE")
+		if pendingFile == "" {
+			pendingFile = "data/pending-jobs.json"
 		}
-		if cfg.GroqAPIKey == "" {
-			log.Fatal("GROQ_API_KEY не установлен")
+		webhookHandler := handlers.NewWebhookHandler(provider, tracker, jobs.NewPendingFile(pendingFile))
+		stopWebhooks = webhookHandler.Shutdown
+
+		// Токены провайдеров проверяются только из переменных окружения:
+		// в файле конфигурации у проектов могут быть свои токены
+		if checkProviders && fileProvider == nil {
+			telegramService := services.NewTelegramService(cfg)
+			aiService := services.NewAIService(cfg)
+			checker.Ready("telegram", health.Cached(telegramService.Ping, time.Minute))
+			checker.Ready("ai", health.Cached(aiService.Ping, time.Minute))
 		}
 
-		// Инициализируем сервисы
-		telegramService := services.NewTelegramService(cfg)
-		aiService := services.NewAIService(cfg)
-		webhookHandler := handlers.NewWebhookHandler(cfg, telegramService, aiService)
+		// Выполняем задачи, прерванные предыдущей остановкой
+		if err := webhookHandler.ResumePending(context.Background()); err != nil {
+			slog.Error("Failed to resume interrupted jobs", "error", err)
+		}
 
 		// Роуты для single-user режима
 		r.GET("/health", webhookHandler.HealthCheck)
 		r.POST("/webhook/github", webhookHandler.HandleGitHubWebhook)
 
-		log.Printf("📡 Webhook URL: http://localhost:%s/webhook/github", cfg.Port)
-		log.Printf("📖 Swagger UI: http://localhost:%s/swagger/index.html", cfg.Port)
+		if fileProvider != nil {
+			r.POST("/webhook/github/:token", webhookHandler.HandleGitHubWebhook)
+			reloadOnSIGHUP(fileProvider)
+			// URL проекта — префикс и webhook_token из файла; проект без токена — /webhook/github
+			slog.Info("Webhook URL", "url", fmt.Sprintf("http://localhost:%s/webhook/github/", cfg.Port))
+		} else {
+			slog.Info("Webhook URL", "url", fmt.Sprintf("http://localhost:%s/webhook/github", cfg.Port))
+		}
+		slog.Info("Metrics", "url", fmt.Sprintf("http://localhost:%s/metrics", cfg.Port))
+		slog.Info("Readiness check", "url", fmt.Sprintf("http://localhost:%s/readyz", cfg.Port))
+		slog.Info("Swagger UI", "url", fmt.Sprintf("http://localhost:%s/swagger/index.html", cfg.Port))
 	}
 
+	// Проверки живости и готовности (Docker, systemd, балансировщик)
+	r.GET("/livez", checker.Livez)
+	r.GET("/readyz", checker.Readyz)
+
 	// Запускаем сервер
-	addr := fmt.Sprintf(":%s", cfg.Port)
-	log.Printf("🚀 CommitCaster запущен на порту %s", cfg.Port)
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%s", cfg.Port),
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+	slog.Info("CommitCaster запущен", "port", cfg.Port)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case err := <-serverErr:
+		fatal("Ошибка запуска сервера", "error", err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	// Останавливаемся: перестаём принимать запросы (в т.ч. webhook), дожидаемся
+	// генерации и отправки постов до SHUTDOWN_TIMEOUT, незавершённые сохраняем
+	// для продолжения после запуска
+	timeout := envDuration("SHUTDOWN_TIMEOUT", 30*time.Second)
+	slog.Info("Shutting down", "timeout", timeout.String(), "jobs", tracker.Running())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to stop HTTP server", "error", err)
+	}
+	if stopWebhooks != nil {
+		stopWebhooks(shutdownCtx)
+	}
+	if err := tracker.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to drain jobs", "error", err)
+	}
 
-	if err := r.Run(addr); err != nil {
-		log.Fatalf("Ошибка запуска сервера: %v", err)
+	// Трейсы отправляем с отдельным таймаутом: дедлайн остановки мог уже истечь
+	tracingCtx, cancelTracing := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelTracing()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
 	}
+
+	if db != nil {
+		if err := database.Close(db); err != nil {
+			slog.Error("Failed to close database", "error", err)
+		}
+	}
+	slog.Info("CommitCaster остановлен")
+}
+
+// reloadOnSIGHUP перечитывает файл конфигурации по SIGHUP; если файл с ошибкой,
+// остаются прежние настройки
+func reloadOnSIGHUP(provider *pipeline.FileProvider) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := provider.Reload(); err != nil {
+				slog.Error("Failed to reload config file, keeping previous config", "path", provider.Path(), "error", err)
+				continue
+			}
+			slog.Info("Config file reloaded", "path", provider.Path(), "projects", provider.Projects())
+		}
+	}()
+}
+
+// runMigrate выполняет подкоманду migrate и возвращает код выхода
+func runMigrate(args []string) int {
+	const usage = "usage: commitcaster migrate up | down [N] | status"
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+
+	steps := 1
+	if args[0] == "down" && len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			fmt.Fprintln(os.Stderr, usage)
+			return 2
+		}
+		steps = n
+	}
+
+	if os.Getenv("DATABASE_URL") == store.MemoryURL {
+		fmt.Fprintln(os.Stderr, "in-memory store has no migrations")
+		return 2
+	}
+
+	db, err := database.Connect()
+	if err != nil {
+		slog.Error("Failed to connect to database", "error", err)
+		return 1
+	}
+	defer database.Close(db)
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := database.MigrateUp(ctx, db)
+		if err != nil {
+			slog.Error("Migration failed", "error", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("Schema is up to date")
+		}
+		for _, m := range applied {
+			fmt.Printf("Applied %04d_%s\n", m.Version, m.Name)
+		}
+
+	case "down":
+		reverted, err := database.MigrateDown(ctx, db, steps)
+		if err != nil {
+			slog.Error("Migration failed", "error", err)
+			return 1
+		}
+		if len(reverted) == 0 {
+			fmt.Println("No migrations to revert")
+		}
+		for _, m := range reverted {
+			fmt.Printf("Reverted %04d_%s\n", m.Version, m.Name)
+		}
+
+	case "status":
+		statuses, err := database.Migrations(ctx, db)
+		if err != nil {
+			slog.Error("Failed to read migrations", "error", err)
+			return 1
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%-24s %s\n", status.Version, status.Name, applied)
+		}
+		if err := database.CheckSchemaVersion(ctx, db); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		fmt.Println("Schema is up to date")
+
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	return 0
+}
+
+// promoteAdmins назначает роль admin пользователям из ADMIN_EMAILS (аккаунты должны существовать)
+func promoteAdmins(ctx context.Context, users store.UserStore, admins string) error {
+	var emails []string
+	for _, email := range strings.Split(admins, ",") {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			emails = append(emails, email)
+		}
+	}
+	if len(emails) == 0 {
+		return nil
+	}
+
+	promoted, err := users.PromoteAdmins(ctx, emails)
+	if err != nil {
+		return fmt.Errorf("failed to promote admins: %w", err)
+	}
+	if promoted > 0 {
+		slog.Info("Promoted users to admin", "count", promoted)
+	}
+	return nil
+}
+
+// envDuration читает длительность из переменной окружения (например, 30s или 10m)
+func envDuration(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		slog.Warn("Invalid duration, using default", "name", name, "value", value, "default", def.String())
+		return def
+	}
+	return d
+}
+
+// logRoute пишет в лог доступный маршрут
+func logRoute(route, description string) {
+	slog.Info("Route", "route", route, "description", description)
+}
+
+// fatal пишет ошибку в лог и завершает процесс
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
@@ -1,7 +1,7 @@
 package config
 
 import (
-	"log"
+	"log/slog"
 	"os"
 
 	"github.com/joho/godotenv"
@@ -13,11 +13,14 @@ type Config struct {
 	GroqAPIKey       string
 	GitHubSecret     string
 	Port             string
+
+	// Файл конфигурации single-user режима с несколькими проектами (CONFIG_FILE)
+	ConfigFile string
 }
 
 func Load() *Config {
 	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using environment variables")
+		slog.Info("No .env file found, using environment variables")
 	}
 
 	return &Config{
@@ -26,6 +29,7 @@ func Load() *Config {
 		GroqAPIKey:        getEnv("GROQ_API_KEY", ""),
 		GitHubSecret:      getEnv("GITHUB_WEBHOOK_SECRET", ""),
 		Port:              getEnv("PORT", "8080"),
+		ConfigFile:        getEnv("CONFIG_FILE", ""),
 	}
 }
 
@@ -0,0 +1,225 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// File — YAML файл конфигурации single-user режима (CONFIG_FILE): несколько
+// проектов со своими webhook, каналами, промптами, фильтрами и расписанием.
+// В строковых значениях ${NAME} заменяется переменной окружения NAME
+type File struct {
+	// Значения по умолчанию для всех проектов
+	TelegramBotToken string `yaml:"telegram_bot_token"`
+	GroqAPIKey       string `yaml:"groq_api_key"`
+	AIModel          string `yaml:"ai_model"`
+	PostLanguage     string `yaml:"post_language"`
+	MaxCommits       int    `yaml:"max_commits"`
+	CustomPrompt     string `yaml:"custom_prompt"`
+
+	Projects []FileProject `yaml:"projects"`
+}
+
+// FileProject — проект файла конфигурации
+type FileProject struct {
+	Name string `yaml:"name"`
+
+	// Webhook URL проекта: /webhook/github/<webhook_token>; проект без токена
+	// принимает webhook на /webhook/github (не больше одного такого проекта)
+	WebhookToken string `yaml:"webhook_token"`
+	GitHubSecret string `yaml:"github_secret"`
+
+	// Пустые значения берутся из общих настроек файла
+	PostLanguage string `yaml:"post_language"`
+	MaxCommits   int    `yaml:"max_commits"`
+	CustomPrompt string `yaml:"custom_prompt"`
+
+	// Фильтры: ветки через запятую (glob-шаблоны) и подстрока в сообщении коммита,
+	// при которой коммит не публикуется
+	BranchFilter string `yaml:"branch_filter"`
+	SkipPattern  string `yaml:"skip_pattern"`
+
+	Schedule     *Schedule         `yaml:"schedule"`
+	Destinations []FileDestination `yaml:"destinations"`
+	RoutingRules []FileRoutingRule `yaml:"routing_rules"`
+}
+
+// FileDestination — Telegram канал проекта
+type FileDestination struct {
+	Name              string `yaml:"name"`
+	TelegramChannelID string `yaml:"telegram_channel_id"`
+	// Пустой токен берётся из общих настроек файла
+	TelegramBotToken string `yaml:"telegram_bot_token"`
+}
+
+// FileRoutingRule — правило выбора канала, промпта и языка по репозиторию и ветке
+type FileRoutingRule struct {
+	Repository   string `yaml:"repository"`
+	Ref          string `yaml:"ref"`
+	Destination  string `yaml:"destination"`
+	PostLanguage string `yaml:"post_language"`
+	CustomPrompt string `yaml:"custom_prompt"`
+}
+
+// envPattern — ссылка на переменную окружения: ${NAME}
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// LoadFile читает, подставляет переменные окружения и проверяет файл конфигурации
+func LoadFile(filename string) (*File, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	var file File
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", filename, err)
+	}
+
+	// Переменные подставляются после разбора YAML, поэтому секреты
+	// со спецсимволами не ломают файл
+	missing := make(map[string]bool)
+	expandEnv(reflect.ValueOf(&file).Elem(), missing)
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("config file %s: environment variables not set: %s", filename, strings.Join(names, ", "))
+	}
+
+	if err := file.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", filename, err)
+	}
+	return &file, nil
+}
+
+// expandEnv подставляет переменные окружения во все строковые поля v
+func expandEnv(v reflect.Value, missing map[string]bool) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(envPattern.ReplaceAllStringFunc(v.String(), func(ref string) string {
+			name := envPattern.FindStringSubmatch(ref)[1]
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				missing[name] = true
+			}
+			return value
+		}))
+	case reflect.Pointer:
+		if !v.IsNil() {
+			expandEnv(v.Elem(), missing)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			expandEnv(v.Field(i), missing)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			expandEnv(v.Index(i), missing)
+		}
+	}
+}
+
+// Validate проверяет, что каждому проекту хватает настроек для публикации
+func (f *File) Validate() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if f.GroqAPIKey == "" {
+		fail("groq_api_key is required")
+	}
+	if f.MaxCommits < 0 {
+		fail("max_commits must not be negative")
+	}
+	if len(f.Projects) == 0 {
+		fail("at least one project is required")
+	}
+
+	names := make(map[string]bool)
+	tokens := make(map[string]string)
+	for i, p := range f.Projects {
+		where := fmt.Sprintf("projects[%d]", i)
+		if p.Name == "" {
+			fail("%s: name is required", where)
+		} else {
+			where = fmt.Sprintf("project %q", p.Name)
+			if names[p.Name] {
+				fail("%s: duplicate name", where)
+			}
+			names[p.Name] = true
+		}
+
+		if other, ok := tokens[p.WebhookToken]; ok {
+			if p.WebhookToken == "" {
+				fail("%s: only one project may omit webhook_token (%q already does)", where, other)
+			} else {
+				fail("%s: webhook_token is already used by %q", where, other)
+			}
+		}
+		tokens[p.WebhookToken] = p.Name
+
+		if p.MaxCommits < 0 {
+			fail("%s: max_commits must not be negative", where)
+		}
+		if err := validatePatterns(strings.Split(p.BranchFilter, ",")...); err != nil {
+			fail("%s: branch_filter: %v", where, err)
+		}
+		if p.Schedule != nil {
+			if _, err := p.Schedule.Window(); err != nil {
+				fail("%s: schedule: %v", where, err)
+			}
+		}
+
+		if len(p.Destinations) == 0 {
+			fail("%s: at least one destination is required", where)
+		}
+		destinations := make(map[string]bool)
+		for j, d := range p.Destinations {
+			if d.TelegramChannelID == "" {
+				fail("%s: destinations[%d]: telegram_channel_id is required", where, j)
+			}
+			if d.TelegramBotToken == "" && f.TelegramBotToken == "" {
+				fail("%s: destinations[%d]: telegram_bot_token is required (here or at the top level)", where, j)
+			}
+			if d.Name != "" {
+				destinations[d.Name] = true
+			}
+		}
+
+		for j, r := range p.RoutingRules {
+			if err := validatePatterns(r.Repository, r.Ref); err != nil {
+				fail("%s: routing_rules[%d]: %v", where, j, err)
+			}
+			if r.Destination != "" && !destinations[r.Destination] {
+				fail("%s: routing_rules[%d]: unknown destination %q", where, j, r.Destination)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validatePatterns проверяет glob-шаблоны
+func validatePatterns(patterns ...string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(strings.TrimSpace(pattern), ""); err != nil {
+			return fmt.Errorf("invalid pattern %q", pattern)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig записывает файл конфигурации во временный каталог
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+const validConfig = `
+telegram_bot_token: ${TEST_BOT_TOKEN}
+groq_api_key: ${TEST_GROQ_KEY}
+projects:
+  - name: api
+    webhook_token: api-token
+    github_secret: "${TEST_SECRET}"
+    branch_filter: main, release/*
+    schedule:
+      timezone: Europe/Moscow
+      days: [mon, tue, wed, thu, fri]
+      hours: "09:00-18:00"
+    destinations:
+      - name: news
+        telegram_channel_id: "@news"
+    routing_rules:
+      - ref: refs/heads/release/*
+        destination: news
+`
+
+func TestLoadFile(t *testing.T) {
+	t.Setenv("TEST_BOT_TOKEN", "bot-token")
+	t.Setenv("TEST_GROQ_KEY", "groq-key")
+	// Спецсимволы YAML в значении переменной не ломают файл
+	t.Setenv("TEST_SECRET", `s3cr#t: "x"`)
+
+	file, err := LoadFile(writeConfig(t, validConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.TelegramBotToken != "bot-token" || file.GroqAPIKey != "groq-key" {
+		t.Errorf("top-level values not expanded: %+v", file)
+	}
+	project := file.Projects[0]
+	if project.GitHubSecret != `s3cr#t: "x"` {
+		t.Errorf("github_secret = %q", project.GitHubSecret)
+	}
+	if project.Schedule == nil || project.Schedule.Timezone != "Europe/Moscow" {
+		t.Errorf("schedule = %+v", project.Schedule)
+	}
+}
+
+func TestLoadFileMissingEnv(t *testing.T) {
+	t.Setenv("TEST_SECRET", "secret")
+
+	_, err := LoadFile(writeConfig(t, validConfig))
+	if err == nil {
+		t.Fatal("LoadFile succeeded with unset variables")
+	}
+	// Перечислены все отсутствующие переменные, по алфавиту
+	if !strings.Contains(err.Error(), "TEST_BOT_TOKEN, TEST_GROQ_KEY") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestLoadFileUnknownField(t *testing.T) {
+	_, err := LoadFile(writeConfig(t, "groq_api_key: key\nunknown_field: 1\n"))
+	if err == nil || !strings.Contains(err.Error(), "unknown_field") {
+		t.Errorf("error = %v, want unknown field error", err)
+	}
+}
+
+func TestFileValidate(t *testing.T) {
+	project := func(name, token string) FileProject {
+		return FileProject{
+			Name:         name,
+			WebhookToken: token,
+			Destinations: []FileDestination{{Name: "news", TelegramChannelID: "@news"}},
+		}
+	}
+	valid := func() File {
+		return File{TelegramBotToken: "bot", GroqAPIKey: "key", Projects: []FileProject{project("api", "")}}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(f *File)
+		want   string
+	}{
+		{"valid", func(f *File) {}, ""},
+		{"no groq key", func(f *File) { f.GroqAPIKey = "" }, "groq_api_key is required"},
+		{"no projects", func(f *File) { f.Projects = nil }, "at least one project is required"},
+		{"no name", func(f *File) { f.Projects[0].Name = "" }, "projects[0]: name is required"},
+		{"duplicate name", func(f *File) { f.Projects = append(f.Projects, project("api", "other")) }, `project "api": duplicate name`},
+		{"two projects without token", func(f *File) { f.Projects = append(f.Projects, project("web", "")) }, "only one project may omit webhook_token"},
+		{"duplicate token", func(f *File) {
+			f.Projects[0].WebhookToken = "token"
+			f.Projects = append(f.Projects, project("web", "token"))
+		}, `webhook_token is already used by "api"`},
+		{"invalid branch filter", func(f *File) { f.Projects[0].BranchFilter = "main, [" }, "branch_filter"},
+		{"invalid schedule", func(f *File) { f.Projects[0].Schedule = &Schedule{Timezone: "Nowhere/City"} }, "schedule"},
+		{"no destinations", func(f *File) { f.Projects[0].Destinations = nil }, "at least one destination is required"},
+		{"no bot token", func(f *File) { f.TelegramBotToken = "" }, "telegram_bot_token is required"},
+		{"destination bot token", func(f *File) {
+			f.TelegramBotToken = ""
+			f.Projects[0].Destinations[0].TelegramBotToken = "bot"
+		}, ""},
+		{"unknown route destination", func(f *File) {
+			f.Projects[0].RoutingRules = []FileRoutingRule{{Destination: "other"}}
+		}, `unknown destination "other"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := valid()
+			tt.modify(&f)
+			err := f.Validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("Validate() = %v, want nil", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("Validate() = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Schedule — окно публикации проекта: посты, пришедшие вне окна, ждут его начала
+type Schedule struct {
+	// Часовой пояс IANA (например, Europe/Moscow); пусто — часовой пояс сервера
+	Timezone string `yaml:"timezone"`
+	// Дни недели: mon, tue, wed, thu, fri, sat, sun; пусто — каждый день
+	Days []string `yaml:"days"`
+	// Часы публикации: "09:00-21:00"; пусто — весь день
+	Hours string `yaml:"hours"`
+}
+
+// Window — разобранное окно публикации
+type Window struct {
+	location *time.Location
+	days     [7]bool
+	from, to time.Duration
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Window разбирает расписание
+func (s Schedule) Window() (*Window, error) {
+	w := &Window{location: time.Local, to: 24 * time.Hour}
+
+	if s.Timezone != "" {
+		location, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q", s.Timezone)
+		}
+		w.location = location
+	}
+
+	if len(s.Days) == 0 {
+		w.days = [7]bool{true, true, true, true, true, true, true}
+	}
+	for _, day := range s.Days {
+		weekday, ok := weekdays[strings.ToLower(strings.TrimSpace(day))]
+		if !ok {
+			return nil, fmt.Errorf("invalid day %q, expected mon..sun", day)
+		}
+		w.days[weekday] = true
+	}
+
+	if s.Hours != "" {
+		from, to, ok := strings.Cut(s.Hours, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid hours %q, expected HH:MM-HH:MM", s.Hours)
+		}
+		var err error
+		if w.from, err = parseClock(from); err != nil {
+			return nil, err
+		}
+		if w.to, err = parseClock(to); err != nil {
+			return nil, err
+		}
+		if w.from >= w.to {
+			return nil, errors.New("hours must end after they start")
+		}
+	}
+
+	return w, nil
+}
+
+// parseClock разбирает время суток HH:MM; 24:00 — конец суток
+func parseClock(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Next возвращает ближайший момент не раньше t, когда можно публиковать.
+// Границы окна — время на часах часового пояса, поэтому в дни перехода на летнее
+// или зимнее время окно по-прежнему начинается, например, в 09:00, а не на час раньше
+func (w *Window) Next(t time.Time) time.Time {
+	local := t.In(w.location)
+	year, month, day := local.Date()
+
+	for i := 0; i <= 7; i++ {
+		// День недели календарной даты не зависит от часового пояса
+		if !w.days[time.Date(year, month, day+i, 12, 0, 0, 0, time.UTC).Weekday()] {
+			continue
+		}
+		start := w.clock(year, month, day+i, w.from)
+		end := w.clock(year, month, day+i, w.to)
+		if !local.Before(end) {
+			continue
+		}
+		if local.Before(start) {
+			return start
+		}
+		return t
+	}
+	return t
+}
+
+// clock возвращает момент, когда на часах часового пояса окна в день day будет
+// время суток clock; 24:00 — полночь следующего дня
+func (w *Window) clock(year int, month time.Month, day int, clock time.Duration) time.Time {
+	hour := int(clock / time.Hour)
+	minute := int(clock % time.Hour / time.Minute)
+	return time.Date(year, month, day, hour, minute, 0, 0, w.location)
+}
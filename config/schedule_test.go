@@ -0,0 +1,105 @@
+package config
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata" // часовые пояса не зависят от системной базы
+)
+
+func mustWindow(t *testing.T, s Schedule) *Window {
+	t.Helper()
+	w, err := s.Window()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func TestWindowNext(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 3, day, hour, minute, 0, 0, moscow)
+	}
+	// 2026-03-16 — понедельник
+	workdays := Schedule{Timezone: "Europe/Moscow", Days: []string{"mon", "tue", "wed", "thu", "fri"}, Hours: "09:00-18:00"}
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		t        time.Time
+		want     time.Time
+	}{
+		{"inside window", workdays, at(16, 12, 0), at(16, 12, 0)},
+		{"before window", workdays, at(16, 7, 30), at(16, 9, 0)},
+		{"window start", workdays, at(16, 9, 0), at(16, 9, 0)},
+		{"window end", workdays, at(16, 18, 0), at(17, 9, 0)},
+		{"friday evening", workdays, at(20, 19, 0), at(23, 9, 0)},
+		{"weekend", workdays, at(21, 12, 0), at(23, 9, 0)},
+		{"whole day", Schedule{Timezone: "Europe/Moscow", Days: []string{"sat"}}, at(16, 12, 0), at(21, 0, 0)},
+		{"until midnight", Schedule{Timezone: "Europe/Moscow", Hours: "20:00-24:00"}, at(16, 23, 59), at(16, 23, 59)},
+		{"after midnight", Schedule{Timezone: "Europe/Moscow", Hours: "20:00-24:00"}, at(17, 0, 0), at(17, 20, 0)},
+		// Момент в другом часовом поясе сравнивается с окном в часовом поясе расписания
+		{"other timezone", workdays, time.Date(2026, 3, 16, 5, 0, 0, 0, time.UTC), at(16, 9, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mustWindow(t, tt.schedule).Next(tt.t)
+			if !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindowNextDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := mustWindow(t, Schedule{Timezone: "Europe/Berlin", Hours: "09:00-18:00"})
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want time.Time
+	}{
+		// 29 марта 2026 часы переводятся с 02:00 на 03:00, 25 октября — с 03:00 на 02:00
+		{"spring forward", time.Date(2026, 3, 29, 5, 0, 0, 0, berlin), time.Date(2026, 3, 29, 9, 0, 0, 0, berlin)},
+		{"fall back", time.Date(2026, 10, 25, 5, 0, 0, 0, berlin), time.Date(2026, 10, 25, 9, 0, 0, 0, berlin)},
+		{"end after spring forward", time.Date(2026, 3, 29, 17, 30, 0, 0, berlin), time.Date(2026, 3, 29, 17, 30, 0, 0, berlin)},
+		{"end after fall back", time.Date(2026, 10, 25, 18, 30, 0, 0, berlin), time.Date(2026, 10, 26, 9, 0, 0, 0, berlin)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := w.Next(tt.t)
+			if !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.t, got.In(berlin), tt.want)
+			}
+			if hour, minute, _ := got.In(berlin).Clock(); !got.Equal(tt.t) && (hour != 9 || minute != 0) {
+				t.Errorf("window opens at %02d:%02d local time, want 09:00", hour, minute)
+			}
+		})
+	}
+}
+
+func TestScheduleWindowErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+	}{
+		{"timezone", Schedule{Timezone: "Mars/Olympus"}},
+		{"day", Schedule{Days: []string{"monday"}}},
+		{"hours format", Schedule{Hours: "09:00"}},
+		{"clock", Schedule{Hours: "9am-18:00"}},
+		{"end before start", Schedule{Hours: "18:00-09:00"}},
+		{"empty window", Schedule{Hours: "09:00-09:00"}},
+	}
+	for _, tt := range tests {
+		if _, err := tt.schedule.Window(); err == nil {
+			t.Errorf("%s: no error for %+v", tt.name, tt.schedule)
+		}
+	}
+}
@@ -9,23 +9,26 @@ import (
 )
 
 type Claims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
+	UserID    uint   `json:"user_id"`
+	Email     string `json:"email"`
+	SessionID uint   `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken создаёт JWT токен для пользователя
-func GenerateToken(userID uint, email string) (string, error) {
+// GenerateToken создаёт короткоживущий access токен, привязанный к сессии
+func GenerateToken(userID uint, email string, sessionID uint) (string, error) {
 	secret := os.Getenv("JWT_SECRET")
 	if secret == "" {
 		return "", fmt.Errorf("JWT_SECRET not set")
 	}
 
 	claims := &Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:    userID,
+		Email:     email,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * 7 * time.Hour)), // 7 дней
+			ID:        RandomToken(16),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL())),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -58,3 +61,58 @@ func ValidateToken(tokenString string) (*Claims, error) {
 
 	return nil, fmt.Errorf("invalid token")
 }
+
+// PurposeClaims — короткоживущий токен для одного шага сценария
+// (OAuth state, подтверждение действия). Не принимается как access токен
+type PurposeClaims struct {
+	Purpose string `json:"purpose"`
+	UserID  uint   `json:"user_id,omitempty"`
+	Nonce   string `json:"nonce,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// GeneratePurposeToken создаёт подписанный токен с назначением и сроком жизни
+func GeneratePurposeToken(purpose string, userID uint, nonce string, ttl time.Duration) (string, error) {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return "", fmt.Errorf("JWT_SECRET not set")
+	}
+
+	claims := &PurposeClaims{
+		Purpose: purpose,
+		UserID:  userID,
+		Nonce:   nonce,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(secret))
+}
+
+// ValidatePurposeToken проверяет токен и его назначение
+func ValidatePurposeToken(tokenString, purpose string) (*PurposeClaims, error) {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return nil, fmt.Errorf("JWT_SECRET not set")
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, &PurposeClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method")
+		}
+		return []byte(secret), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*PurposeClaims)
+	if !ok || !token.Valid || claims.Purpose != purpose {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Заголовки подписи GitHub webhook
+const (
+	SignatureHeader     = "X-Hub-Signature-256"
+	SignatureHeaderSHA1 = "X-Hub-Signature"
+)
+
+// maskedSecretPrefix — начало замаскированного секрета в ответах API
+const maskedSecretPrefix = "********"
+
+var (
+	ErrSignatureMissing = errors.New("signature missing")
+	ErrSignatureInvalid = errors.New("signature invalid")
+)
+
+// VerifyGitHubSignature проверяет HMAC подпись тела webhook. По умолчанию
+// принимается только X-Hub-Signature-256; устаревший SHA-1 заголовок
+// X-Hub-Signature проверяется, только если allowSHA1
+func VerifyGitHubSignature(body []byte, secret string, header http.Header, allowSHA1 bool) error {
+	if signature := header.Get(SignatureHeader); signature != "" {
+		return compareSignature(sha256.New, "sha256=", body, secret, signature)
+	}
+
+	if allowSHA1 {
+		if signature := header.Get(SignatureHeaderSHA1); signature != "" {
+			return compareSignature(sha1.New, "sha1=", body, secret, signature)
+		}
+	}
+
+	return ErrSignatureMissing
+}
+
+// SignGitHubPayload возвращает значение X-Hub-Signature-256 для тела
+func SignGitHubPayload(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func compareSignature(h func() hash.Hash, prefix string, body []byte, secret, signature string) error {
+	if secret == "" || !strings.HasPrefix(signature, prefix) {
+		return ErrSignatureInvalid
+	}
+
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, prefix))
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(expected, mac.Sum(nil)) {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
+
+// AllowSHA1Signatures включает устаревшие SHA-1 подписи (WEBHOOK_ALLOW_SHA1=true)
+func AllowSHA1Signatures() bool {
+	return os.Getenv("WEBHOOK_ALLOW_SHA1") == "true"
+}
+
+// AllowUnsignedWebhooks разрешает в SaaS режиме доставки без подписи для webhook
+// без секрета. Секрет создаётся при регистрации, поэтому без него остаются только
+// аккаунты, созданные раньше: их webhooks работают, пока секрет не задан.
+// WEBHOOK_ALLOW_UNSIGNED=false отклоняет такие доставки
+func AllowUnsignedWebhooks() bool {
+	return os.Getenv("WEBHOOK_ALLOW_UNSIGNED") != "false"
+}
+
+// GenerateWebhookSecret создаёт секрет для подписи GitHub webhook
+func GenerateWebhookSecret() string {
+	return RandomToken(32)
+}
+
+// MaskSecret скрывает секрет, оставляя последние 4 символа
+func MaskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	if len(secret) <= 8 {
+		return maskedSecretPrefix
+	}
+	return maskedSecretPrefix + secret[len(secret)-4:]
+}
+
+// IsMaskedSecret сообщает, что значение получено из MaskSecret
+// (клиент вернул его без изменений)
+func IsMaskedSecret(value string) bool {
+	return strings.HasPrefix(value, maskedSecretPrefix)
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func signSHA1(body []byte, secret string) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write(body)
+	return "sha1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyGitHubSignature(t *testing.T) {
+	body := []byte(`{"ref":"refs/heads/main"}`)
+	const secret = "webhook-secret"
+
+	tests := []struct {
+		name      string
+		header    http.Header
+		secret    string
+		allowSHA1 bool
+		want      error
+	}{
+		{"sha256", http.Header{SignatureHeader: {SignGitHubPayload(body, secret)}}, secret, false, nil},
+		{"sha256 wrong secret", http.Header{SignatureHeader: {SignGitHubPayload(body, "other")}}, secret, false, ErrSignatureInvalid},
+		{"sha256 other body", http.Header{SignatureHeader: {SignGitHubPayload([]byte(`{}`), secret)}}, secret, false, ErrSignatureInvalid},
+		{"sha256 without prefix", http.Header{SignatureHeader: {SignGitHubPayload(body, secret)[len("sha256="):]}}, secret, false, ErrSignatureInvalid},
+		{"sha256 not hex", http.Header{SignatureHeader: {"sha256=zz"}}, secret, false, ErrSignatureInvalid},
+		{"empty secret", http.Header{SignatureHeader: {SignGitHubPayload(body, "")}}, "", false, ErrSignatureInvalid},
+		{"missing", http.Header{}, secret, false, ErrSignatureMissing},
+		{"sha1 not allowed", http.Header{SignatureHeaderSHA1: {signSHA1(body, secret)}}, secret, false, ErrSignatureMissing},
+		{"sha1 allowed", http.Header{SignatureHeaderSHA1: {signSHA1(body, secret)}}, secret, true, nil},
+		{"sha1 allowed wrong secret", http.Header{SignatureHeaderSHA1: {signSHA1(body, "other")}}, secret, true, ErrSignatureInvalid},
+		{"sha1 prefix in sha256 header", http.Header{SignatureHeader: {signSHA1(body, secret)}}, secret, true, ErrSignatureInvalid},
+		// При обоих заголовках проверяется SHA-256, даже если SHA-1 верный
+		{"sha256 takes precedence", http.Header{
+			SignatureHeader:     {SignGitHubPayload(body, "other")},
+			SignatureHeaderSHA1: {signSHA1(body, secret)},
+		}, secret, true, ErrSignatureInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyGitHubSignature(body, tt.secret, tt.header, tt.allowSHA1)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("VerifyGitHubSignature() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaskSecret(t *testing.T) {
+	tests := []struct {
+		secret, want string
+	}{
+		{"", ""},
+		{"short", "********"},
+		{"0123456789abcdef", "********cdef"},
+	}
+	for _, tt := range tests {
+		got := MaskSecret(tt.secret)
+		if got != tt.want {
+			t.Errorf("MaskSecret(%q) = %q, want %q", tt.secret, got, tt.want)
+		}
+		if tt.secret != "" && !IsMaskedSecret(got) {
+			t.Errorf("IsMaskedSecret(%q) = false", got)
+		}
+	}
+	if IsMaskedSecret("0123456789abcdef") {
+		t.Error("IsMaskedSecret reports a real secret as masked")
+	}
+}
+
+func TestAllowUnsignedWebhooks(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"", true},
+		{"true", true},
+		{"false", false},
+	}
+	for _, tt := range tests {
+		t.Setenv("WEBHOOK_ALLOW_UNSIGNED", tt.value)
+		if got := AllowUnsignedWebhooks(); got != tt.want {
+			t.Errorf("WEBHOOK_ALLOW_UNSIGNED=%q: AllowUnsignedWebhooks() = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
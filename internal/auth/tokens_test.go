@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRandomToken(t *testing.T) {
+	a, b := RandomToken(32), RandomToken(32)
+	if len(a) != 64 {
+		t.Errorf("len(RandomToken(32)) = %d, want 64", len(a))
+	}
+	if a == b {
+		t.Error("RandomToken returned the same token twice")
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	// SHA-256 от "abc"
+	const want = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got := HashToken("abc"); got != want {
+		t.Errorf("HashToken(abc) = %s, want %s", got, want)
+	}
+	if HashToken("abc") == HashToken("abd") {
+		t.Error("different tokens have the same hash")
+	}
+}
+
+func TestTokenTTLFromEnv(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 15 * time.Minute},
+		{"1h", time.Hour},
+		{"invalid", 15 * time.Minute},
+		{"-5m", 15 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Setenv("ACCESS_TOKEN_TTL", tt.value)
+		if got := AccessTokenTTL(); got != tt.want {
+			t.Errorf("ACCESS_TOKEN_TTL=%q: AccessTokenTTL() = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	t.Setenv("REFRESH_TOKEN_TTL", "")
+	if got := RefreshTokenTTL(); got != 30*24*time.Hour {
+		t.Errorf("RefreshTokenTTL() = %v, want 720h", got)
+	}
+}
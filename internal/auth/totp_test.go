@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret — ключ "12345678901234567890" из тестовых векторов RFC 6238
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateTOTPVectors(t *testing.T) {
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		step, ok := ValidateTOTP(rfcSecret, tt.code, 0, time.Unix(tt.unix, 0))
+		if !ok {
+			t.Errorf("code %s at %d rejected", tt.code, tt.unix)
+			continue
+		}
+		if want := tt.unix / totpPeriod; step != want {
+			t.Errorf("step = %d, want %d", step, want)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	code := "081804" // интервал now
+	period := time.Duration(totpPeriod) * time.Second
+
+	tests := []struct {
+		name string
+		at   time.Time
+		ok   bool
+	}{
+		{"same step", now, true},
+		{"one step later", now.Add(period), true},
+		{"one step earlier", now.Add(-period), true},
+		{"two steps later", now.Add(2 * period), false},
+		{"two steps earlier", now.Add(-2 * period), false},
+	}
+	for _, tt := range tests {
+		if _, ok := ValidateTOTP(rfcSecret, code, 0, tt.at); ok != tt.ok {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.ok)
+		}
+	}
+}
+
+func TestValidateTOTPReplay(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+
+	step, ok := ValidateTOTP(rfcSecret, "081804", 0, now)
+	if !ok {
+		t.Fatal("valid code rejected")
+	}
+	// Код уже использованного интервала и более ранних не принимается
+	if _, ok := ValidateTOTP(rfcSecret, "081804", step, now); ok {
+		t.Error("used code accepted again")
+	}
+	if _, ok := ValidateTOTP(rfcSecret, "081804", step+1, now); ok {
+		t.Error("code older than the last used step accepted")
+	}
+}
+
+func TestValidateTOTPInvalidInput(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	tests := []struct {
+		name, secret, code string
+	}{
+		{"wrong code", rfcSecret, "000000"},
+		{"short code", rfcSecret, "81804"},
+		{"long code", rfcSecret, "0081804"},
+		{"invalid secret", "not base32!", "081804"},
+	}
+	for _, tt := range tests {
+		if _, ok := ValidateTOTP(tt.secret, tt.code, 0, now); ok {
+			t.Errorf("%s: accepted", tt.name)
+		}
+	}
+
+	// Секрет принимается в нижнем регистре и с пробелами, как его вводят вручную
+	if _, ok := ValidateTOTP(" "+strings.ToLower(rfcSecret)+" ", "081804", 0, now); !ok {
+		t.Error("lowercase secret rejected")
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret := GenerateTOTPSecret()
+	if _, err := totpEncoding.DecodeString(secret); err != nil {
+		t.Fatalf("secret %q is not base32: %v", secret, err)
+	}
+	if secret == GenerateTOTPSecret() {
+		t.Error("secrets repeat")
+	}
+	uri := TOTPURI("CommitCaster", "alice@example.com", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/") || !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("TOTPURI() = %q", uri)
+	}
+}
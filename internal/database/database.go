@@ -1,60 +1,85 @@
 package database
 
 import (
-	"commitcaster/internal/models"
+	"commitcaster/internal/logging"
+	"commitcaster/internal/metrics"
+	"commitcaster/internal/tracing"
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"strings"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-var DB *gorm.DB
-
-// Connect подключается к PostgreSQL и выполняет миграции
-func Connect() error {
+// Connect подключается к БД из DATABASE_URL: sqlite://path — SQLite, иначе PostgreSQL.
+// Миграции применяются отдельно (MigrateUp)
+func Connect() (*gorm.DB, error) {
 	dsn := os.Getenv("DATABASE_URL")
 	if dsn == "" {
-		return fmt.Errorf("DATABASE_URL not set")
+		return nil, fmt.Errorf("DATABASE_URL not set")
+	}
+
+	dialector := postgres.Open(dsn)
+	if strings.HasPrefix(dsn, SQLiteScheme) {
+		var err error
+		if dialector, err = sqliteDialector(dsn); err != nil {
+			return nil, err
+		}
 	}
 
-	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+	db, err := gorm.Open(dialector, &gorm.Config{
+		// Значения параметров не пишем в лог: среди них токены и ключи
+		Logger: logger.NewSlogLogger(slog.Default(), logger.Config{
+			SlowThreshold:             200 * time.Millisecond,
+			LogLevel:                  gormLogLevel(),
+			ParameterizedQueries:      true,
+			IgnoreRecordNotFoundError: true,
+		}),
 	})
 	if err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	log.Println("✅ Connected to PostgreSQL")
+	slog.Info("Connected to database", "driver", db.Dialector.Name())
 
-	// Автоматические миграции
-	if err := AutoMigrate(); err != nil {
-		return err
+	if err := db.Use(metrics.GORMPlugin{}); err != nil {
+		return nil, fmt.Errorf("failed to register metrics plugin: %w", err)
+	}
+	if err := db.Use(tracing.GORMPlugin{}); err != nil {
+		return nil, fmt.Errorf("failed to register tracing plugin: %w", err)
 	}
 
-	return nil
+	return db, nil
 }
 
-// AutoMigrate выполняет автоматические миграции
-func AutoMigrate() error {
-	log.Println("Running database migrations...")
+// gormLogLevel — SQL запросы пишутся только при LOG_LEVEL=debug,
+// медленные запросы и ошибки — всегда
+func gormLogLevel() logger.LogLevel {
+	if logging.Level() <= slog.LevelDebug {
+		return logger.Info
+	}
+	return logger.Warn
+}
 
-	err := DB.AutoMigrate(
-		&models.User{},
-		&models.UserSettings{},
-	)
+// Close закрывает пул соединений с БД
+func Close(db *gorm.DB) error {
+	sqlDB, err := db.DB()
 	if err != nil {
-		return fmt.Errorf("migration failed: %w", err)
+		return fmt.Errorf("failed to get database connection: %w", err)
 	}
-
-	log.Println("✅ Database migrations completed")
-	return nil
+	return sqlDB.Close()
 }
 
-// GetDB возвращает экземпляр БД
-func GetDB() *gorm.DB {
-	return DB
+// Ping проверяет соединение с БД
+func Ping(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %w", err)
+	}
+	return sqlDB.PingContext(ctx)
 }
@@ -0,0 +1,361 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Миграции лежат в migrations/<диалект>/NNNN_name.up.sql и NNNN_name.down.sql
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Ключ advisory lock PostgreSQL: миграции с нескольких экземпляров не выполняются одновременно
+const migrationLockKey = 7243016841
+
+// Migration — версия схемы БД
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus — миграция и время её применения (nil — не применена)
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+}
+
+// SchemaMigration — запись о применённой миграции
+type SchemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// ErrSchemaVersion — версия схемы БД не совпадает с версией, которую ожидает сборка
+var ErrSchemaVersion = errors.New("unexpected database schema version")
+
+// loadMigrations читает встроенные миграции диалекта, отсортированные по версии
+func loadMigrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %s: %w", dialect, err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration file name %s", name)
+		}
+		versionText, title, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(versionText, 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %s", name)
+		}
+
+		content, err := fs.ReadFile(migrationFiles, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		} else if m.Name != title {
+			return nil, fmt.Errorf("migration %d has different names: %s and %s", version, m.Name, title)
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d must have both up and down files", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// LatestVersion возвращает версию схемы, которую ожидает сборка
+func LatestVersion(db *gorm.DB) (int64, error) {
+	migrations, err := loadMigrations(db.Dialector.Name())
+	if err != nil {
+		return 0, err
+	}
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// MigrateUp применяет все неприменённые миграции и возвращает их
+func MigrateUp(ctx context.Context, db *gorm.DB) ([]Migration, error) {
+	migrations, err := loadMigrations(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	err = withMigrationLock(ctx, db, func(tx *gorm.DB) error {
+		done, err := appliedVersions(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if _, ok := done[m.Version]; ok {
+				continue
+			}
+			slog.Info("Applying migration", "version", m.Version, "name", m.Name)
+			if err := tx.Exec(m.Up).Error; err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+			}
+			if err := tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error; err != nil {
+				return fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+			}
+			applied = append(applied, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// MigrateDown откатывает steps последних применённых миграций и возвращает их
+func MigrateDown(ctx context.Context, db *gorm.DB, steps int) ([]Migration, error) {
+	migrations, err := loadMigrations(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[int64]Migration, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+
+	var reverted []Migration
+	err = withMigrationLock(ctx, db, func(tx *gorm.DB) error {
+		var records []SchemaMigration
+		if err := tx.Order("version DESC").Limit(steps).Find(&records).Error; err != nil {
+			return fmt.Errorf("failed to read applied migrations: %w", err)
+		}
+
+		for _, record := range records {
+			m, ok := known[record.Version]
+			if !ok {
+				return fmt.Errorf("migration %d_%s is unknown to this build", record.Version, record.Name)
+			}
+			slog.Info("Reverting migration", "version", m.Version, "name", m.Name)
+			if err := tx.Exec(m.Down).Error; err != nil {
+				return fmt.Errorf("revert of %d_%s failed: %w", m.Version, m.Name, err)
+			}
+			if err := tx.Delete(&SchemaMigration{}, record.Version).Error; err != nil {
+				return fmt.Errorf("failed to delete migration record %d: %w", m.Version, err)
+			}
+			reverted = append(reverted, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reverted, nil
+}
+
+// Migrations возвращает все известные и применённые миграции
+func Migrations(ctx context.Context, db *gorm.DB) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	done, err := appliedVersions(db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if record, ok := done[m.Version]; ok {
+			status.AppliedAt = &record.AppliedAt
+			delete(done, m.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	// Применённые миграции, которых нет в сборке (БД обновлена более новой версией)
+	for _, record := range done {
+		statuses = append(statuses, MigrationStatus{Version: record.Version, Name: record.Name, AppliedAt: &record.AppliedAt})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// CheckSchemaVersion проверяет, что применены ровно миграции этой сборки:
+// сервис не запускается на старой или более новой схеме
+func CheckSchemaVersion(ctx context.Context, db *gorm.DB) error {
+	statuses, err := Migrations(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	latest, err := LatestVersion(db)
+	if err != nil {
+		return err
+	}
+
+	var current int64
+	var pending []string
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending = append(pending, strconv.FormatInt(status.Version, 10))
+			continue
+		}
+		current = max(current, status.Version)
+	}
+
+	switch {
+	case current > latest:
+		return fmt.Errorf("%w: database is at %d, this build expects %d", ErrSchemaVersion, current, latest)
+	case len(pending) > 0:
+		return fmt.Errorf("%w: database is at %d, pending migrations %s (run \"commitcaster migrate up\")",
+			ErrSchemaVersion, current, strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// appliedVersions читает применённые миграции. Таблицы schema_migrations нет до первой миграции
+func appliedVersions(db *gorm.DB) (map[int64]SchemaMigration, error) {
+	done := make(map[int64]SchemaMigration)
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		return done, nil
+	}
+
+	var records []SchemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	for _, record := range records {
+		done[record.Version] = record
+	}
+	return done, nil
+}
+
+// withMigrationLock выполняет fn в транзакции: миграции применяются целиком или не применяются.
+// Схема, созданная AutoMigrate до появления миграций, принимается через adoptLegacySchema
+func withMigrationLock(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == "postgres" {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockKey).Error; err != nil {
+				return fmt.Errorf("failed to acquire migration lock: %w", err)
+			}
+		}
+
+		if tx.Migrator().HasTable(&SchemaMigration{}) {
+			return fn(tx)
+		}
+		legacy := tx.Migrator().HasTable("users")
+		if err := tx.Migrator().CreateTable(&SchemaMigration{}); err != nil {
+			return fmt.Errorf("failed to create schema_migrations: %w", err)
+		}
+		if legacy {
+			if err := adoptLegacySchema(tx); err != nil {
+				return err
+			}
+		}
+		return fn(tx)
+	})
+}
+
+var (
+	createTablePattern = regexp.MustCompile("(?s)CREATE TABLE [`\"]?(\\w+)[`\"]? \\((.*?)\\n\\);")
+	columnPattern      = regexp.MustCompile("(?m)^\\s*[`\"](\\w+)[`\"]")
+	addColumnPattern   = regexp.MustCompile("ALTER TABLE [`\"]?(\\w+)[`\"]? ADD COLUMN [`\"]?(\\w+)[`\"]?")
+)
+
+// schemaObjects возвращает таблицы и колонки, которые создаёт SQL миграции
+func schemaObjects(sql string) map[string][]string {
+	objects := make(map[string][]string)
+	for _, match := range createTablePattern.FindAllStringSubmatch(sql, -1) {
+		for _, column := range columnPattern.FindAllStringSubmatch(match[2], -1) {
+			objects[match[1]] = append(objects[match[1]], column[1])
+		}
+	}
+	for _, match := range addColumnPattern.FindAllStringSubmatch(sql, -1) {
+		objects[match[1]] = append(objects[match[1]], match[2])
+	}
+	return objects
+}
+
+// adoptLegacySchema записывает как применённые миграции, все таблицы и колонки которых
+// уже есть в схеме, созданной AutoMigrate. Остальные миграции применяются как обычно.
+// Схему, в которой объекты миграции есть лишь частично, не трогаем: после неё
+// миграции упали бы или оставили схему, не совпадающую с ожидаемой
+func adoptLegacySchema(tx *gorm.DB) error {
+	migrations, err := loadMigrations(tx.Dialector.Name())
+	if err != nil {
+		return err
+	}
+
+	adopting := true
+	for _, m := range migrations {
+		var present, missing []string
+		for table, columns := range schemaObjects(m.Up) {
+			if !tx.Migrator().HasTable(table) {
+				missing = append(missing, table)
+				continue
+			}
+			for _, column := range columns {
+				if tx.Migrator().HasColumn(table, column) {
+					present = append(present, table+"."+column)
+				} else {
+					missing = append(missing, table+"."+column)
+				}
+			}
+		}
+		sort.Strings(missing)
+
+		switch {
+		case len(present) == 0:
+			adopting = false
+		case adopting && len(missing) == 0:
+			slog.Info("Existing schema created by AutoMigrate recorded as applied", "version", m.Version, "name", m.Name)
+			if err := tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error; err != nil {
+				return fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+			}
+		case adopting:
+			return fmt.Errorf("existing schema partially matches migration %d_%s, missing %s; migrate it manually",
+				m.Version, m.Name, strings.Join(missing, ", "))
+		default:
+			return fmt.Errorf("existing schema has objects of migration %d_%s but not of earlier migrations; migrate it manually",
+				m.Version, m.Name)
+		}
+	}
+	return nil
+}
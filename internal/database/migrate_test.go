@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// openSQLite открывает пустую базу SQLite во временном каталоге
+func openSQLite(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	t.Setenv("DATABASE_URL", SQLiteScheme+filepath.Join(t.TempDir(), "test.db"))
+	db, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Close(db) })
+	return db
+}
+
+func TestMigrationsMatchAcrossDialects(t *testing.T) {
+	postgres, err := loadMigrations("postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite, err := loadMigrations("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(postgres) != len(sqlite) {
+		t.Fatalf("postgres has %d migrations, sqlite has %d", len(postgres), len(sqlite))
+	}
+	for i := range postgres {
+		if postgres[i].Version != sqlite[i].Version || postgres[i].Name != sqlite[i].Name {
+			t.Errorf("migration %d: postgres %d_%s, sqlite %d_%s", i,
+				postgres[i].Version, postgres[i].Name, sqlite[i].Version, sqlite[i].Name)
+		}
+	}
+}
+
+func TestMigrateUpDown(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+
+	latest, err := LatestVersion(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckSchemaVersion(ctx, db); !errors.Is(err, ErrSchemaVersion) {
+		t.Fatalf("CheckSchemaVersion on empty database = %v, want ErrSchemaVersion", err)
+	}
+
+	applied, err := MigrateUp(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(applied)) != latest {
+		t.Fatalf("applied %d migrations, want %d", len(applied), latest)
+	}
+	if err := CheckSchemaVersion(ctx, db); err != nil {
+		t.Fatalf("CheckSchemaVersion after up: %v", err)
+	}
+	if !db.Migrator().HasColumn("deliveries", "post") {
+		t.Fatal("deliveries.post missing after up")
+	}
+
+	// Повторный запуск ничего не применяет
+	if applied, err := MigrateUp(ctx, db); err != nil || len(applied) != 0 {
+		t.Fatalf("second MigrateUp applied %d migrations, err %v", len(applied), err)
+	}
+
+	reverted, err := MigrateDown(ctx, db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted) != 1 || reverted[0].Version != latest {
+		t.Fatalf("reverted %+v, want only version %d", reverted, latest)
+	}
+	if db.Migrator().HasColumn("deliveries", "post") {
+		t.Error("deliveries.post left after down")
+	}
+
+	statuses, err := Migrations(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if pending := status.AppliedAt == nil; pending != (status.Version == latest) {
+			t.Errorf("migration %d: pending = %v", status.Version, pending)
+		}
+	}
+	if err := CheckSchemaVersion(ctx, db); !errors.Is(err, ErrSchemaVersion) {
+		t.Errorf("CheckSchemaVersion with pending migration = %v, want ErrSchemaVersion", err)
+	}
+
+	// Полный откат удаляет все таблицы, после чего схема снова применяется
+	if reverted, err := MigrateDown(ctx, db, int(latest)); err != nil || int64(len(reverted)) != latest-1 {
+		t.Fatalf("full MigrateDown reverted %d migrations, err %v", len(reverted), err)
+	}
+	if db.Migrator().HasTable("users") {
+		t.Error("users table left after full down")
+	}
+	if _, err := MigrateUp(ctx, db); err != nil {
+		t.Fatalf("MigrateUp after full down: %v", err)
+	}
+	if err := CheckSchemaVersion(ctx, db); err != nil {
+		t.Fatalf("CheckSchemaVersion after re-up: %v", err)
+	}
+}
+
+func TestCheckSchemaVersionNewerDatabase(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+	if _, err := MigrateUp(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	latest, err := LatestVersion(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// БД обновлена более новой сборкой
+	if err := db.Create(&SchemaMigration{Version: latest + 1, Name: "future"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckSchemaVersion(ctx, db); !errors.Is(err, ErrSchemaVersion) {
+		t.Fatalf("CheckSchemaVersion = %v, want ErrSchemaVersion", err)
+	}
+	if _, err := MigrateDown(ctx, db, 1); err == nil {
+		t.Error("MigrateDown reverted a migration unknown to this build")
+	}
+}
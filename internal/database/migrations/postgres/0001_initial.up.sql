@@ -0,0 +1,36 @@
+-- Начальная схема: соответствует схеме, которую создавал GORM AutoMigrate
+-- до появления проектов (только users и user_settings)
+
+CREATE TABLE "users" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "email" text NOT NULL,
+    "password_hash" text NOT NULL,
+    "name" text,
+    "webhook_token" text NOT NULL,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_users_webhook_token" ON "users" ("webhook_token");
+CREATE UNIQUE INDEX "idx_users_email" ON "users" ("email");
+CREATE INDEX "idx_users_deleted_at" ON "users" ("deleted_at");
+
+CREATE TABLE "user_settings" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "telegram_bot_token" text,
+    "telegram_channel_id" text,
+    "groq_api_key" text,
+    "git_hub_secret" text,
+    "is_active" boolean DEFAULT true,
+    "ai_model" text DEFAULT 'llama-3.3-70b-versatile',
+    "post_language" text DEFAULT 'ru',
+    "max_commits" bigint DEFAULT 5,
+    "custom_prompt" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_users_settings" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX "idx_user_settings_user_id" ON "user_settings" ("user_id");
@@ -0,0 +1,30 @@
+DROP TABLE IF EXISTS "usage_counters";
+DROP TABLE IF EXISTS "audit_logs";
+DROP TABLE IF EXISTS "posts";
+DROP TABLE IF EXISTS "deliveries";
+DROP TABLE IF EXISTS "rate_limit_buckets";
+DROP TABLE IF EXISTS "recovery_codes";
+DROP TABLE IF EXISTS "user_tokens";
+DROP TABLE IF EXISTS "personal_access_tokens";
+DROP TABLE IF EXISTS "refresh_tokens";
+DROP TABLE IF EXISTS "sessions";
+DROP TABLE IF EXISTS "memberships";
+DROP TABLE IF EXISTS "organizations";
+DROP TABLE IF EXISTS "routing_rules";
+DROP TABLE IF EXISTS "destinations";
+DROP TABLE IF EXISTS "projects";
+DROP INDEX IF EXISTS "idx_users_previous_webhook_token";
+DROP INDEX IF EXISTS "idx_users_git_hub_id";
+ALTER TABLE "users" DROP COLUMN "previous_webhook_token_expires_at";
+ALTER TABLE "users" DROP COLUMN "previous_webhook_token";
+ALTER TABLE "users" DROP COLUMN "locked_until";
+ALTER TABLE "users" DROP COLUMN "failed_logins";
+ALTER TABLE "users" DROP COLUMN "totp_last_step";
+ALTER TABLE "users" DROP COLUMN "totp_enabled";
+ALTER TABLE "users" DROP COLUMN "totp_secret";
+ALTER TABLE "users" DROP COLUMN "github_login";
+ALTER TABLE "users" DROP COLUMN "github_id";
+ALTER TABLE "users" DROP COLUMN "email_verified_at";
+ALTER TABLE "users" DROP COLUMN "deactivated_at";
+ALTER TABLE "users" DROP COLUMN "plan";
+ALTER TABLE "users" DROP COLUMN "role";
@@ -0,0 +1,237 @@
+-- Проекты, организации, сессии, токены, доставки, аудит и учёт использования
+
+ALTER TABLE "users" ADD COLUMN "role" text NOT NULL DEFAULT 'user';
+ALTER TABLE "users" ADD COLUMN "plan" text;
+ALTER TABLE "users" ADD COLUMN "deactivated_at" timestamptz;
+ALTER TABLE "users" ADD COLUMN "email_verified_at" timestamptz;
+ALTER TABLE "users" ADD COLUMN "github_id" bigint;
+ALTER TABLE "users" ADD COLUMN "github_login" text;
+ALTER TABLE "users" ADD COLUMN "totp_secret" text;
+ALTER TABLE "users" ADD COLUMN "totp_enabled" boolean;
+ALTER TABLE "users" ADD COLUMN "totp_last_step" bigint;
+ALTER TABLE "users" ADD COLUMN "failed_logins" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "users" ADD COLUMN "locked_until" timestamptz;
+ALTER TABLE "users" ADD COLUMN "previous_webhook_token" text;
+ALTER TABLE "users" ADD COLUMN "previous_webhook_token_expires_at" timestamptz;
+CREATE INDEX "idx_users_previous_webhook_token" ON "users" ("previous_webhook_token");
+CREATE UNIQUE INDEX "idx_users_git_hub_id" ON "users" ("github_id");
+
+CREATE TABLE "projects" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "name" text NOT NULL,
+    "organization_id" bigint,
+    "webhook_token" text NOT NULL,
+    "git_hub_secret" text,
+    "previous_webhook_token" text,
+    "previous_webhook_token_expires_at" timestamptz,
+    "is_active" boolean,
+    "post_language" text,
+    "max_commits" bigint,
+    "custom_prompt" text,
+    "branch_filter" text,
+    "skip_pattern" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_projects_previous_webhook_token" ON "projects" ("previous_webhook_token");
+CREATE UNIQUE INDEX "idx_projects_webhook_token" ON "projects" ("webhook_token");
+CREATE INDEX "idx_projects_organization_id" ON "projects" ("organization_id");
+CREATE INDEX "idx_projects_user_id" ON "projects" ("user_id");
+CREATE INDEX "idx_projects_deleted_at" ON "projects" ("deleted_at");
+
+CREATE TABLE "destinations" (
+    "id" bigserial,
+    "project_id" bigint NOT NULL,
+    "name" text,
+    "telegram_bot_token" text,
+    "telegram_channel_id" text NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_projects_destinations" FOREIGN KEY ("project_id") REFERENCES "projects"("id") ON DELETE CASCADE
+);
+CREATE INDEX "idx_destinations_project_id" ON "destinations" ("project_id");
+
+CREATE TABLE "routing_rules" (
+    "id" bigserial,
+    "project_id" bigint NOT NULL,
+    "position" bigint NOT NULL DEFAULT 0,
+    "repository_pattern" text,
+    "ref_pattern" text,
+    "destination" text,
+    "post_language" text,
+    "custom_prompt" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_projects_routing_rules" FOREIGN KEY ("project_id") REFERENCES "projects"("id") ON DELETE CASCADE
+);
+CREATE INDEX "idx_routing_rules_project_id" ON "routing_rules" ("project_id");
+
+CREATE TABLE "organizations" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "name" text NOT NULL,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_organizations_deleted_at" ON "organizations" ("deleted_at");
+
+CREATE TABLE "memberships" (
+    "id" bigserial,
+    "organization_id" bigint NOT NULL,
+    "user_id" bigint NOT NULL,
+    "role" text NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_organizations_members" FOREIGN KEY ("organization_id") REFERENCES "organizations"("id") ON DELETE CASCADE
+);
+CREATE INDEX "idx_memberships_user_id" ON "memberships" ("user_id");
+CREATE UNIQUE INDEX "idx_membership_org_user" ON "memberships" ("organization_id","user_id");
+
+CREATE TABLE "sessions" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "user_agent" text,
+    "ip" text,
+    "expires_at" timestamptz NOT NULL,
+    "revoked_at" timestamptz,
+    "impersonator_id" bigint,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_sessions_impersonator_id" ON "sessions" ("impersonator_id");
+CREATE INDEX "idx_sessions_user_id" ON "sessions" ("user_id");
+
+CREATE TABLE "refresh_tokens" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "session_id" bigint NOT NULL,
+    "token_hash" text NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "used_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_sessions_refresh_tokens" FOREIGN KEY ("session_id") REFERENCES "sessions"("id") ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX "idx_refresh_tokens_token_hash" ON "refresh_tokens" ("token_hash");
+CREATE INDEX "idx_refresh_tokens_session_id" ON "refresh_tokens" ("session_id");
+
+CREATE TABLE "personal_access_tokens" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "name" text NOT NULL,
+    "token_hash" text NOT NULL,
+    "prefix" text,
+    "scopes" text NOT NULL,
+    "last_used_at" timestamptz,
+    "expires_at" timestamptz,
+    "revoked_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_personal_access_tokens_token_hash" ON "personal_access_tokens" ("token_hash");
+CREATE INDEX "idx_personal_access_tokens_user_id" ON "personal_access_tokens" ("user_id");
+
+CREATE TABLE "user_tokens" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "purpose" text NOT NULL,
+    "token_hash" text NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "used_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_user_tokens_token_hash" ON "user_tokens" ("token_hash");
+CREATE INDEX "idx_user_tokens_purpose" ON "user_tokens" ("purpose");
+CREATE INDEX "idx_user_tokens_user_id" ON "user_tokens" ("user_id");
+
+CREATE TABLE "recovery_codes" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "code_hash" text NOT NULL,
+    "used_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_recovery_codes_user_id" ON "recovery_codes" ("user_id");
+
+CREATE TABLE "rate_limit_buckets" (
+    "key" text,
+    "tokens" decimal NOT NULL,
+    "allowed" boolean NOT NULL,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("key")
+);
+
+CREATE TABLE "deliveries" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    "project_id" bigint,
+    "repository" text,
+    "ref" text,
+    "commit_count" bigint,
+    "status" text NOT NULL,
+    "error" text,
+    "payload" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_deliveries_status" ON "deliveries" ("status");
+CREATE INDEX "idx_deliveries_project_id" ON "deliveries" ("project_id");
+CREATE INDEX "idx_deliveries_user_id" ON "deliveries" ("user_id");
+
+CREATE TABLE "posts" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "delivery_id" bigint NOT NULL,
+    "user_id" bigint NOT NULL,
+    "telegram_channel_id" text,
+    "content" text,
+    "status" text NOT NULL,
+    "error" text,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_deliveries_posts" FOREIGN KEY ("delivery_id") REFERENCES "deliveries"("id") ON DELETE CASCADE
+);
+CREATE INDEX "idx_posts_user_id" ON "posts" ("user_id");
+CREATE INDEX "idx_posts_delivery_id" ON "posts" ("delivery_id");
+
+CREATE TABLE "audit_logs" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "actor_id" bigint,
+    "action" text NOT NULL,
+    "target_type" text,
+    "target_id" bigint,
+    "ip" text,
+    "user_agent" text,
+    "details" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_audit_logs_target_id" ON "audit_logs" ("target_id");
+CREATE INDEX "idx_audit_logs_action" ON "audit_logs" ("action");
+CREATE INDEX "idx_audit_logs_actor_id" ON "audit_logs" ("actor_id");
+CREATE INDEX "idx_audit_logs_created_at" ON "audit_logs" ("created_at");
+
+CREATE TABLE "usage_counters" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "period" text NOT NULL,
+    "period_start" timestamptz NOT NULL,
+    "posts" bigint NOT NULL DEFAULT 0,
+    "ai_requests" bigint NOT NULL DEFAULT 0,
+    "prompt_tokens" bigint NOT NULL DEFAULT 0,
+    "completion_tokens" bigint NOT NULL DEFAULT 0,
+    "telegram_sends" bigint NOT NULL DEFAULT 0,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_usage_user_period" ON "usage_counters" ("user_id","period","period_start");
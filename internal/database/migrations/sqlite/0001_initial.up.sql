@@ -0,0 +1,33 @@
+-- Начальная схема для SQLite: соответствует postgres/0001_initial.up.sql
+
+CREATE TABLE `users` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    `email` text NOT NULL,
+    `password_hash` text NOT NULL,
+    `name` text,
+    `webhook_token` text NOT NULL
+);
+CREATE UNIQUE INDEX `idx_users_webhook_token` ON `users`(`webhook_token`);
+CREATE UNIQUE INDEX `idx_users_email` ON `users`(`email`);
+CREATE INDEX `idx_users_deleted_at` ON `users`(`deleted_at`);
+
+CREATE TABLE `user_settings` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` integer NOT NULL,
+    `telegram_bot_token` text,
+    `telegram_channel_id` text,
+    `groq_api_key` text,
+    `git_hub_secret` text,
+    `is_active` numeric DEFAULT true,
+    `ai_model` text DEFAULT 'llama-3.3-70b-versatile',
+    `post_language` text DEFAULT 'ru',
+    `max_commits` integer DEFAULT 5,
+    `custom_prompt` text,
+    `created_at` datetime,
+    `updated_at` datetime,
+    CONSTRAINT `fk_users_settings` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX `idx_user_settings_user_id` ON `user_settings`(`user_id`);
@@ -0,0 +1,224 @@
+-- Проекты, организации, сессии, токены, доставки, аудит и учёт использования:
+-- соответствует postgres/0002_saas.up.sql
+
+ALTER TABLE `users` ADD COLUMN `role` text NOT NULL DEFAULT 'user';
+ALTER TABLE `users` ADD COLUMN `plan` text;
+ALTER TABLE `users` ADD COLUMN `deactivated_at` datetime;
+ALTER TABLE `users` ADD COLUMN `email_verified_at` datetime;
+ALTER TABLE `users` ADD COLUMN `github_id` integer;
+ALTER TABLE `users` ADD COLUMN `github_login` text;
+ALTER TABLE `users` ADD COLUMN `totp_secret` text;
+ALTER TABLE `users` ADD COLUMN `totp_enabled` numeric;
+ALTER TABLE `users` ADD COLUMN `totp_last_step` integer;
+ALTER TABLE `users` ADD COLUMN `failed_logins` integer NOT NULL DEFAULT 0;
+ALTER TABLE `users` ADD COLUMN `locked_until` datetime;
+ALTER TABLE `users` ADD COLUMN `previous_webhook_token` text;
+ALTER TABLE `users` ADD COLUMN `previous_webhook_token_expires_at` datetime;
+CREATE INDEX `idx_users_previous_webhook_token` ON `users`(`previous_webhook_token`);
+CREATE UNIQUE INDEX `idx_users_git_hub_id` ON `users`(`github_id`);
+
+CREATE TABLE `projects` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    `user_id` integer NOT NULL,
+    `name` text NOT NULL,
+    `organization_id` integer,
+    `webhook_token` text NOT NULL,
+    `git_hub_secret` text,
+    `previous_webhook_token` text,
+    `previous_webhook_token_expires_at` datetime,
+    `is_active` numeric,
+    `post_language` text,
+    `max_commits` integer,
+    `custom_prompt` text,
+    `branch_filter` text,
+    `skip_pattern` text
+);
+CREATE INDEX `idx_projects_previous_webhook_token` ON `projects`(`previous_webhook_token`);
+CREATE UNIQUE INDEX `idx_projects_webhook_token` ON `projects`(`webhook_token`);
+CREATE INDEX `idx_projects_organization_id` ON `projects`(`organization_id`);
+CREATE INDEX `idx_projects_user_id` ON `projects`(`user_id`);
+CREATE INDEX `idx_projects_deleted_at` ON `projects`(`deleted_at`);
+
+CREATE TABLE `destinations` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `project_id` integer NOT NULL,
+    `name` text,
+    `telegram_bot_token` text,
+    `telegram_channel_id` text NOT NULL,
+    `created_at` datetime,
+    `updated_at` datetime,
+    CONSTRAINT `fk_projects_destinations` FOREIGN KEY (`project_id`) REFERENCES `projects`(`id`) ON DELETE CASCADE
+);
+CREATE INDEX `idx_destinations_project_id` ON `destinations`(`project_id`);
+
+CREATE TABLE `routing_rules` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `project_id` integer NOT NULL,
+    `position` integer NOT NULL DEFAULT 0,
+    `repository_pattern` text,
+    `ref_pattern` text,
+    `destination` text,
+    `post_language` text,
+    `custom_prompt` text,
+    `created_at` datetime,
+    `updated_at` datetime,
+    CONSTRAINT `fk_projects_routing_rules` FOREIGN KEY (`project_id`) REFERENCES `projects`(`id`) ON DELETE CASCADE
+);
+CREATE INDEX `idx_routing_rules_project_id` ON `routing_rules`(`project_id`);
+
+CREATE TABLE `organizations` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    `name` text NOT NULL
+);
+CREATE INDEX `idx_organizations_deleted_at` ON `organizations`(`deleted_at`);
+
+CREATE TABLE `memberships` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `organization_id` integer NOT NULL,
+    `user_id` integer NOT NULL,
+    `role` text NOT NULL,
+    `created_at` datetime,
+    `updated_at` datetime,
+    CONSTRAINT `fk_organizations_members` FOREIGN KEY (`organization_id`) REFERENCES `organizations`(`id`) ON DELETE CASCADE
+);
+CREATE INDEX `idx_memberships_user_id` ON `memberships`(`user_id`);
+CREATE UNIQUE INDEX `idx_membership_org_user` ON `memberships`(`organization_id`,`user_id`);
+
+CREATE TABLE `sessions` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `user_id` integer NOT NULL,
+    `user_agent` text,
+    `ip` text,
+    `expires_at` datetime NOT NULL,
+    `revoked_at` datetime,
+    `impersonator_id` integer
+);
+CREATE INDEX `idx_sessions_impersonator_id` ON `sessions`(`impersonator_id`);
+CREATE INDEX `idx_sessions_user_id` ON `sessions`(`user_id`);
+
+CREATE TABLE `refresh_tokens` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `session_id` integer NOT NULL,
+    `token_hash` text NOT NULL,
+    `expires_at` datetime NOT NULL,
+    `used_at` datetime,
+    CONSTRAINT `fk_sessions_refresh_tokens` FOREIGN KEY (`session_id`) REFERENCES `sessions`(`id`) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX `idx_refresh_tokens_token_hash` ON `refresh_tokens`(`token_hash`);
+CREATE INDEX `idx_refresh_tokens_session_id` ON `refresh_tokens`(`session_id`);
+
+CREATE TABLE `personal_access_tokens` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `user_id` integer NOT NULL,
+    `name` text NOT NULL,
+    `token_hash` text NOT NULL,
+    `prefix` text,
+    `scopes` text NOT NULL,
+    `last_used_at` datetime,
+    `expires_at` datetime,
+    `revoked_at` datetime
+);
+CREATE UNIQUE INDEX `idx_personal_access_tokens_token_hash` ON `personal_access_tokens`(`token_hash`);
+CREATE INDEX `idx_personal_access_tokens_user_id` ON `personal_access_tokens`(`user_id`);
+
+CREATE TABLE `user_tokens` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `user_id` integer NOT NULL,
+    `purpose` text NOT NULL,
+    `token_hash` text NOT NULL,
+    `expires_at` datetime NOT NULL,
+    `used_at` datetime
+);
+CREATE UNIQUE INDEX `idx_user_tokens_token_hash` ON `user_tokens`(`token_hash`);
+CREATE INDEX `idx_user_tokens_purpose` ON `user_tokens`(`purpose`);
+CREATE INDEX `idx_user_tokens_user_id` ON `user_tokens`(`user_id`);
+
+CREATE TABLE `recovery_codes` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `user_id` integer NOT NULL,
+    `code_hash` text NOT NULL,
+    `used_at` datetime
+);
+CREATE INDEX `idx_recovery_codes_user_id` ON `recovery_codes`(`user_id`);
+
+CREATE TABLE `rate_limit_buckets` (
+    `key` text,
+    `tokens` real NOT NULL,
+    `allowed` numeric NOT NULL,
+    `updated_at` datetime,
+    PRIMARY KEY (`key`)
+);
+
+CREATE TABLE `deliveries` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `user_id` integer NOT NULL,
+    `project_id` integer,
+    `repository` text,
+    `ref` text,
+    `commit_count` integer,
+    `status` text NOT NULL,
+    `error` text,
+    `payload` text
+);
+CREATE INDEX `idx_deliveries_status` ON `deliveries`(`status`);
+CREATE INDEX `idx_deliveries_project_id` ON `deliveries`(`project_id`);
+CREATE INDEX `idx_deliveries_user_id` ON `deliveries`(`user_id`);
+
+CREATE TABLE `posts` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `delivery_id` integer NOT NULL,
+    `user_id` integer NOT NULL,
+    `telegram_channel_id` text,
+    `content` text,
+    `status` text NOT NULL,
+    `error` text,
+    CONSTRAINT `fk_deliveries_posts` FOREIGN KEY (`delivery_id`) REFERENCES `deliveries`(`id`) ON DELETE CASCADE
+);
+CREATE INDEX `idx_posts_user_id` ON `posts`(`user_id`);
+CREATE INDEX `idx_posts_delivery_id` ON `posts`(`delivery_id`);
+
+CREATE TABLE `audit_logs` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `actor_id` integer,
+    `action` text NOT NULL,
+    `target_type` text,
+    `target_id` integer,
+    `ip` text,
+    `user_agent` text,
+    `details` text
+);
+CREATE INDEX `idx_audit_logs_target_id` ON `audit_logs`(`target_id`);
+CREATE INDEX `idx_audit_logs_action` ON `audit_logs`(`action`);
+CREATE INDEX `idx_audit_logs_actor_id` ON `audit_logs`(`actor_id`);
+CREATE INDEX `idx_audit_logs_created_at` ON `audit_logs`(`created_at`);
+
+CREATE TABLE `usage_counters` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` integer NOT NULL,
+    `period` text NOT NULL,
+    `period_start` datetime NOT NULL,
+    `posts` integer NOT NULL DEFAULT 0,
+    `ai_requests` integer NOT NULL DEFAULT 0,
+    `prompt_tokens` integer NOT NULL DEFAULT 0,
+    `completion_tokens` integer NOT NULL DEFAULT 0,
+    `telegram_sends` integer NOT NULL DEFAULT 0,
+    `updated_at` datetime
+);
+CREATE UNIQUE INDEX `idx_usage_user_period` ON `usage_counters`(`user_id`,`period`,`period_start`);
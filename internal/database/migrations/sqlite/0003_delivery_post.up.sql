@@ -0,0 +1,3 @@
+-- Сгенерированный пост прерванной доставки: продолжение не вызывает AI повторно
+
+ALTER TABLE `deliveries` ADD COLUMN `post` text;
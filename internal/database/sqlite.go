@@ -0,0 +1,108 @@
+package database
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// SQLiteScheme — префикс DATABASE_URL для SQLite: sqlite://data/commitcaster.db
+const SQLiteScheme = "sqlite://"
+
+// sqliteUTCDriver — драйвер SQLite, который пишет время в UTC (см. utcConn)
+const sqliteUTCDriver = "sqlite-utc"
+
+var registerSQLiteUTC sync.Once
+
+// sqliteDialector открывает файл SQLite в режиме WAL: чтение не блокирует запись,
+// а параллельные записи (обработка нескольких webhook) ждут блокировку до busy_timeout,
+// а не падают с SQLITE_BUSY. Транзакции сразу берут блокировку записи (_txlock=immediate),
+// иначе две транзакции, начавшие с чтения, не смогут продолжить запись
+func sqliteDialector(databaseURL string) (gorm.Dialector, error) {
+	path, extra, _ := strings.Cut(strings.TrimPrefix(databaseURL, SQLiteScheme), "?")
+	if path == "" {
+		return nil, errors.New("sqlite database path not set")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	params := url.Values{}
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "synchronous(NORMAL)")
+	params.Set("_txlock", "immediate")
+
+	dsn := path + "?" + params.Encode()
+	if extra != "" {
+		dsn += "&" + extra
+	}
+
+	registerSQLiteUTC.Do(func() {
+		db, err := sql.Open(sqlite.DriverName, "")
+		if err != nil {
+			return
+		}
+		sql.Register(sqliteUTCDriver, utcDriver{db.Driver()})
+		db.Close()
+	})
+
+	return &sqlite.Dialector{DriverName: sqliteUTCDriver, DSN: dsn}, nil
+}
+
+// utcDriver открывает соединения SQLite, обёрнутые в utcConn
+type utcDriver struct {
+	driver.Driver
+}
+
+func (d utcDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	c, ok := conn.(sqliteConn)
+	if !ok {
+		return conn, nil
+	}
+	return utcConn{c}, nil
+}
+
+// sqliteConn — интерфейсы, которые реализует соединение драйвера SQLite
+type sqliteConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+}
+
+// utcConn переводит параметры запросов со временем в UTC. SQLite хранит время строкой
+// со смещением и сравнивает строки: без этого смена часового пояса сервера или переход
+// на летнее время ломали бы сравнение сроков (expires_at > ?)
+type utcConn struct {
+	sqliteConn
+}
+
+func (c utcConn) CheckNamedValue(nv *driver.NamedValue) error {
+	value, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	if err != nil {
+		return err
+	}
+	if t, ok := value.(time.Time); ok {
+		value = t.UTC()
+	}
+	nv.Value = value
+	return nil
+}
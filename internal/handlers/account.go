@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"commitcaster/internal/auth"
+	"commitcaster/internal/models"
+	"commitcaster/internal/store"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	accountDeletePurpose = "account_delete"
+	accountDeleteTTL     = 10 * time.Minute
+)
+
+// AccountExport — все данные пользователя. Секреты (токены, ключи) замаскированы
+type AccountExport struct {
+	ExportedAt           time.Time                    `json:"exported_at"`
+	User                 models.User                  `json:"user"`
+	Projects             []models.Project             `json:"projects"`
+	Memberships          []models.Membership          `json:"memberships"`
+	Sessions             []models.Session             `json:"sessions"`
+	PersonalAccessTokens []models.PersonalAccessToken `json:"personal_access_tokens"`
+	Deliveries           []models.Delivery            `json:"deliveries"`
+	Usage                []models.UsageCounter        `json:"usage"`
+	AuditLog             []AuditLogResponse           `json:"audit_log"`
+}
+
+type DeleteAccountRequest struct {
+	// Второй шаг: токен из ответа на первый шаг
+	ConfirmationToken string `json:"confirmation_token"`
+
+	// Первый шаг: пароль (если задан) и код 2FA (если включена)
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type DeleteAccountConfirmation struct {
+	ConfirmationToken string `json:"confirmation_token"`
+	ExpiresIn         int    `json:"expires_in"`
+}
+
+// ExportAccount выгружает данные пользователя
+// @Summary Экспорт данных аккаунта
+// @Description Возвращает профиль, настройки, проекты, членство в организациях, сессии, personal access токены, доставки с постами и журнал аудита. Секреты замаскированы. format=zip — архив с отдельным JSON файлом на раздел
+// @Tags account
+// @Security BearerAuth
+// @Produce json
+// @Produce application/zip
+// @Param format query string false "json (default) or zip"
+// @Success 200 {object} AccountExport
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /account/export [get]
+func (h *APIHandler) ExportAccount(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "zip" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or zip"})
+		return
+	}
+
+	export, err := buildAccountExport(c.Request.Context(), h.store, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export account"})
+		return
+	}
+	h.recordAudit(c, models.AuditAccountExport, "user", userID, gin.H{"format": format})
+
+	filename := fmt.Sprintf("commitcaster-export-%d-%s", userID, export.ExportedAt.Format("20060102"))
+	if format == "json" {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+		c.JSON(http.StatusOK, export)
+		return
+	}
+
+	archive, err := accountExportZip(export)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export account"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, filename))
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+// DeleteAccount удаляет аккаунт в два шага
+// @Summary Удаление аккаунта
+// @Description Первый шаг: пароль (и код 2FA, если включена) — в ответ 202 с confirmation_token на 10 минут. Второй шаг: confirmation_token — аккаунт и все его данные удаляются безвозвратно, записи журнала аудита обезличиваются. Проекты организаций, где остаются другие участники, передаются владельцу организации
+// @Tags account
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body DeleteAccountRequest true "Credentials or confirmation token"
+// @Success 202 {object} DeleteAccountConfirmation
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /account [delete]
+func (h *APIHandler) DeleteAccount(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	sessionID := c.MustGet("session_id").(uint)
+
+	var req DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	user, err := h.store.Users.Get(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	// Токен подтверждения привязан к пользователю и текущей сессии
+	nonce := strconv.FormatUint(uint64(sessionID), 10)
+
+	if req.ConfirmationToken == "" {
+		h.confirmAccountDeletion(c, &user, req, nonce)
+		return
+	}
+
+	claims, err := auth.ValidatePurposeToken(req.ConfirmationToken, accountDeletePurpose)
+	if err != nil || claims.UserID != user.ID || claims.Nonce != nonce {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired confirmation token"})
+		return
+	}
+
+	err = h.store.Transaction(ctx, func(tx *store.Store) error {
+		return deleteUserData(ctx, tx, user.ID)
+	})
+	var blocked *errOrganizationOwner
+	if errors.As(err, &blocked) {
+		c.JSON(http.StatusConflict, gin.H{"error": blocked.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// confirmAccountDeletion проверяет учётные данные и выдаёт токен подтверждения удаления
+func (h *APIHandler) confirmAccountDeletion(c *gin.Context, user *models.User, req DeleteAccountRequest, nonce string) {
+	ctx := c.Request.Context()
+
+	// У аккаунтов, созданных через GitHub, пароля может не быть
+	if user.PasswordHash != "" && !user.CheckPassword(req.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+		return
+	}
+
+	if user.TOTPEnabled {
+		err := h.store.Transaction(ctx, func(tx *store.Store) error {
+			return verifySecondFactor(ctx, tx, user, req.Code, req.RecoveryCode)
+		})
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+			return
+		}
+	}
+
+	// Проверяем заранее, чтобы не выдавать токен, который нельзя использовать
+	if err := checkOrganizationOwnership(ctx, h.store.Organizations, user.ID); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := auth.GeneratePurposeToken(accountDeletePurpose, user.ID, nonce, accountDeleteTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, DeleteAccountConfirmation{
+		ConfirmationToken: token,
+		ExpiresIn:         int(accountDeleteTTL.Seconds()),
+	})
+}
+
+// errOrganizationOwner — пользователь единственный owner организации с другими участниками
+type errOrganizationOwner struct {
+	name string
+}
+
+func (e *errOrganizationOwner) Error() string {
+	return fmt.Sprintf("Transfer ownership of organization %q or remove its members first", e.name)
+}
+
+// checkOrganizationOwnership не даёт удалить единственного owner организации,
+// в которой есть другие участники
+func checkOrganizationOwnership(ctx context.Context, orgs store.OrganizationStore, userID uint) error {
+	memberships, err := orgs.ListMemberships(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, membership := range memberships {
+		if membership.Role != models.RoleOwner {
+			continue
+		}
+		owners, _ := orgs.CountMembers(ctx, membership.OrganizationID, models.RoleOwner)
+		members, _ := orgs.CountMembers(ctx, membership.OrganizationID, "")
+		if owners <= 1 && members > 1 {
+			org, _ := orgs.Get(ctx, membership.OrganizationID)
+			return &errOrganizationOwner{name: org.Name}
+		}
+	}
+	return nil
+}
+
+// deleteUserData безвозвратно удаляет пользователя и все его данные.
+// Организации, где он был единственным участником, удаляются вместе с проектами;
+// его проекты в остальных организациях передаются owner организации
+func deleteUserData(ctx context.Context, tx *store.Store, userID uint) error {
+	if err := checkOrganizationOwnership(ctx, tx.Organizations, userID); err != nil {
+		return err
+	}
+
+	memberships, err := tx.Organizations.ListMemberships(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, membership := range memberships {
+		members, err := tx.Organizations.CountMembers(ctx, membership.OrganizationID, "")
+		if err != nil {
+			return err
+		}
+
+		if members <= 1 {
+			if err := tx.Organizations.Purge(ctx, membership.OrganizationID); err != nil {
+				return err
+			}
+			continue
+		}
+
+		owner, err := tx.Organizations.FirstOwner(ctx, membership.OrganizationID, userID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Projects.TransferOwnership(ctx, membership.OrganizationID, userID, owner.UserID); err != nil {
+			return err
+		}
+	}
+
+	// Журнал аудита сохраняется, но без данных, по которым можно узнать человека
+	if err := tx.Users.Purge(ctx, userID); err != nil {
+		return err
+	}
+	return tx.Audit.Create(ctx, &models.AuditLog{
+		ActorID:    &userID,
+		Action:     models.AuditAccountDelete,
+		TargetType: "user",
+		TargetID:   &userID,
+	})
+}
+
+// buildAccountExport собирает данные пользователя для выгрузки
+func buildAccountExport(ctx context.Context, st *store.Store, userID uint) (AccountExport, error) {
+	export := AccountExport{ExportedAt: time.Now().UTC()}
+
+	var err error
+	if export.User, err = st.Users.Get(ctx, userID); err != nil {
+		return export, err
+	}
+	if export.User.Settings, err = st.Settings.GetByUser(ctx, userID); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return export, err
+	}
+	maskUserSecrets(&export.User)
+
+	if export.Projects, err = st.Projects.ListByUser(ctx, userID); err != nil {
+		return export, err
+	}
+	if export.Memberships, err = st.Organizations.ListMemberships(ctx, userID); err != nil {
+		return export, err
+	}
+	if export.Sessions, err = st.Sessions.ListByUser(ctx, userID); err != nil {
+		return export, err
+	}
+	if export.PersonalAccessTokens, err = st.Tokens.ListByUser(ctx, userID); err != nil {
+		return export, err
+	}
+	if export.Deliveries, err = st.Deliveries.ListByUser(ctx, userID); err != nil {
+		return export, err
+	}
+	if export.Usage, err = st.Usage.List(ctx, userID, "", time.Time{}); err != nil {
+		return export, err
+	}
+
+	auditLog, _, err := st.Audit.List(ctx, store.AuditFilter{Subject: userID, OldestFirst: true})
+	if err != nil {
+		return export, err
+	}
+	hideAuditClients(auditLog, userID)
+	export.AuditLog = auditLogResponses(auditLog)
+
+	for i := range export.Projects {
+		maskProjectSecrets(&export.Projects[i])
+	}
+
+	return export, nil
+}
+
+// accountExportZip упаковывает выгрузку в ZIP: по JSON файлу на раздел
+func accountExportZip(export AccountExport) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"user.json", export.User},
+		{"projects.json", export.Projects},
+		{"memberships.json", export.Memberships},
+		{"sessions.json", export.Sessions},
+		{"personal_access_tokens.json", export.PersonalAccessTokens},
+		{"deliveries.json", export.Deliveries},
+		{"usage.json", export.Usage},
+		{"audit_log.json", export.AuditLog},
+	}
+	for _, file := range files {
+		w, err := archive.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: export.ExportedAt})
+		if err != nil {
+			return nil, err
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// maskUserSecrets скрывает токены и ключи пользователя и его настроек
+func maskUserSecrets(user *models.User) {
+	user.WebhookToken = auth.MaskSecret(user.WebhookToken)
+	user.Settings = maskSettings(user.Settings)
+}
+
+// maskProjectSecrets скрывает токены и ключи проекта и его каналов
+func maskProjectSecrets(project *models.Project) {
+	project.WebhookToken = auth.MaskSecret(project.WebhookToken)
+	project.GitHubSecret = auth.MaskSecret(project.GitHubSecret)
+	for i := range project.Destinations {
+		project.Destinations[i].TelegramBotToken = auth.MaskSecret(project.Destinations[i].TelegramBotToken)
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"commitcaster/internal/models"
+	"commitcaster/internal/store"
+	"commitcaster/internal/usage"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// deleteAccount проходит оба шага удаления аккаунта
+func deleteAccount(s *testServer, session string, req DeleteAccountRequest) {
+	s.t.Helper()
+
+	w := s.do(http.MethodDelete, "/api/account", session, req)
+	expectStatus(s.t, w, http.StatusAccepted)
+	confirmation := decode[DeleteAccountConfirmation](s.t, w)
+
+	w = s.do(http.MethodDelete, "/api/account", session, DeleteAccountRequest{ConfirmationToken: confirmation.ConfirmationToken})
+	expectStatus(s.t, w, http.StatusNoContent)
+}
+
+func TestDeleteAccountPurgesData(t *testing.T) {
+	s := newTestServer(t)
+	registered := s.register("alice@example.com", "password123")
+	other := s.register("bob@example.com", "password123")
+	user := s.user("alice@example.com")
+	ctx := t.Context()
+
+	createToken(s, registered.Token, models.ScopeSettingsRead)
+	project := models.Project{UserID: user.ID, Name: "api", WebhookToken: "project-token"}
+	if err := s.store.Projects.Create(ctx, &project); err != nil {
+		t.Fatal(err)
+	}
+	delivery := models.Delivery{UserID: user.ID, ProjectID: &project.ID, Status: models.DeliverySucceeded}
+	if err := s.store.Deliveries.Create(ctx, &delivery); err != nil {
+		t.Fatal(err)
+	}
+	if err := usage.Record(ctx, s.store.Usage, user.ID, models.UsageCounts{Posts: 1}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	deleteAccount(s, registered.Token, DeleteAccountRequest{Password: "password123"})
+
+	if _, err := s.store.Users.Get(ctx, user.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("user still exists: %v", err)
+	}
+	if _, err := s.store.Projects.GetByWebhookToken(ctx, project.WebhookToken, time.Now()); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("project still exists: %v", err)
+	}
+	if tokens, _ := s.store.Tokens.ListByUser(ctx, user.ID); len(tokens) != 0 {
+		t.Errorf("personal access tokens left: %d", len(tokens))
+	}
+	if deliveries, _ := s.store.Deliveries.ListByUser(ctx, user.ID); len(deliveries) != 0 {
+		t.Errorf("deliveries left: %d", len(deliveries))
+	}
+	if counters, _ := s.store.Usage.List(ctx, user.ID, "", time.Time{}); len(counters) != 0 {
+		t.Errorf("usage counters left: %d", len(counters))
+	}
+
+	// Сессии удалены: выданные токены больше не работают
+	expectStatus(t, s.do(http.MethodGet, "/api/settings", registered.Token, nil), http.StatusUnauthorized)
+	expectStatus(t, s.do(http.MethodPost, "/api/auth/refresh", "", RefreshRequest{RefreshToken: registered.RefreshToken}), http.StatusUnauthorized)
+	expectStatus(t, s.do(http.MethodPost, "/api/auth/login", "", LoginRequest{Email: "alice@example.com", Password: "password123"}), http.StatusUnauthorized)
+
+	// Записи аудита остаются, но без IP и User-Agent удалённого пользователя
+	entries, _, err := s.store.Audit.List(ctx, store.AuditFilter{Subject: user.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) == 0 {
+		t.Fatal("audit log of the deleted user is empty")
+	}
+	for _, entry := range entries {
+		if entry.IP != "" || entry.UserAgent != "" {
+			t.Errorf("audit entry %d (%s) keeps client data: ip=%q user_agent=%q", entry.ID, entry.Action, entry.IP, entry.UserAgent)
+		}
+	}
+
+	// Данные других пользователей не затронуты
+	expectStatus(t, s.do(http.MethodGet, "/api/settings", other.Token, nil), http.StatusOK)
+}
+
+func TestDeleteAccountRequiresPassword(t *testing.T) {
+	s := newTestServer(t)
+	registered := s.register("alice@example.com", "password123")
+
+	w := s.do(http.MethodDelete, "/api/account", registered.Token, DeleteAccountRequest{Password: "wrong-password"})
+	expectStatus(t, w, http.StatusUnauthorized)
+
+	if _, err := s.store.Users.GetByEmail(t.Context(), "alice@example.com"); err != nil {
+		t.Fatalf("user deleted after wrong password: %v", err)
+	}
+}
+
+func TestDeleteAccountConfirmationBoundToSession(t *testing.T) {
+	s := newTestServer(t)
+	registered := s.register("alice@example.com", "password123")
+
+	w := s.do(http.MethodPost, "/api/auth/login", "", LoginRequest{Email: "alice@example.com", Password: "password123"})
+	expectStatus(t, w, http.StatusOK)
+	other := decode[AuthResponse](t, w)
+
+	w = s.do(http.MethodDelete, "/api/account", registered.Token, DeleteAccountRequest{Password: "password123"})
+	expectStatus(t, w, http.StatusAccepted)
+	confirmation := decode[DeleteAccountConfirmation](t, w)
+
+	// Токен подтверждения другой сессии не принимается
+	w = s.do(http.MethodDelete, "/api/account", other.Token, DeleteAccountRequest{ConfirmationToken: confirmation.ConfirmationToken})
+	expectStatus(t, w, http.StatusBadRequest)
+}
@@ -0,0 +1,587 @@
+package handlers
+
+import (
+	"commitcaster/internal/auth"
+	"commitcaster/internal/models"
+	"commitcaster/internal/store"
+	"commitcaster/internal/usage"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	adminDefaultPerPage = 50
+	adminMaxPerPage     = 200
+	adminRecentLimit    = 20
+)
+
+// AdminUserResponse — пользователь с показателями активности для администратора
+type AdminUserResponse struct {
+	ID              uint       `json:"id"`
+	Email           string     `json:"email"`
+	Name            string     `json:"name"`
+	Role            string     `json:"role"`
+	Plan            string     `json:"plan"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	TOTPEnabled     bool       `json:"totp_enabled"`
+	DeactivatedAt   *time.Time `json:"deactivated_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+
+	// Неудачные попытки входа подряд и блокировка входа (null — вход не заблокирован)
+	FailedLogins int        `json:"failed_logins"`
+	LockedUntil  *time.Time `json:"locked_until"`
+
+	LastLoginAt      *time.Time `json:"last_login_at"`
+	DeliveryCount    int64      `json:"delivery_count"`
+	FailedDeliveries int64      `json:"failed_deliveries"`
+}
+
+type AdminUserListResponse struct {
+	Users   []AdminUserResponse `json:"users"`
+	Total   int64               `json:"total"`
+	Page    int                 `json:"page"`
+	PerPage int                 `json:"per_page"`
+}
+
+type AdminUserDetailResponse struct {
+	AdminUserResponse
+	ActiveSessions   int64             `json:"active_sessions"`
+	ProjectCount     int64             `json:"project_count"`
+	RecentDeliveries []models.Delivery `json:"recent_deliveries"`
+}
+
+type AdminRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=user admin"`
+}
+
+type AdminPlanRequest struct {
+	Plan string `json:"plan" binding:"required"`
+}
+
+type AdminReasonRequest struct {
+	Reason string `json:"reason"`
+}
+
+type ImpersonateRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+type ImpersonateResponse struct {
+	Token     string `json:"token"`
+	ExpiresIn int    `json:"expires_in"`
+	UserID    uint   `json:"user_id"`
+}
+
+type RotateTokensResponse struct {
+	RevokedSessions       int64 `json:"revoked_sessions"`
+	RevokedPersonalTokens int64 `json:"revoked_personal_tokens"`
+	RotatedWebhooks       int64 `json:"rotated_webhooks"`
+}
+
+// AdminListUsers возвращает пользователей с поиском и пагинацией
+// @Summary Список пользователей (admin)
+// @Description Поиск пользователей по email и имени с показателями активности: последний вход, число доставок и ошибок
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param q query string false "Email or name substring"
+// @Param role query string false "user or admin"
+// @Param status query string false "active or deactivated"
+// @Param page query int false "Page number (from 1)"
+// @Param per_page query int false "Page size (max 200)"
+// @Success 200 {object} AdminUserListResponse
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/users [get]
+func (h *APIHandler) AdminListUsers(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", strconv.Itoa(adminDefaultPerPage)))
+	if perPage < 1 || perPage > adminMaxPerPage {
+		perPage = adminDefaultPerPage
+	}
+
+	filter := store.UserFilter{
+		Query:  strings.TrimSpace(c.Query("q")),
+		Role:   c.Query("role"),
+		Offset: (page - 1) * perPage,
+		Limit:  perPage,
+	}
+	switch c.Query("status") {
+	case "active":
+		deactivated := false
+		filter.Deactivated = &deactivated
+	case "deactivated":
+		deactivated := true
+		filter.Deactivated = &deactivated
+	}
+
+	users, total, err := h.store.Users.List(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list users"})
+		return
+	}
+
+	response, err := h.adminUserResponses(ctx, users)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user activity"})
+		return
+	}
+
+	c.JSON(http.StatusOK, AdminUserListResponse{
+		Users:   response,
+		Total:   total,
+		Page:    page,
+		PerPage: perPage,
+	})
+}
+
+// AdminGetUser возвращает пользователя с активностью и последними доставками
+// @Summary Пользователь (admin)
+// @Description Возвращает пользователя, число активных сессий и проектов и последние доставки webhook
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} AdminUserDetailResponse
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/users/{id} [get]
+func (h *APIHandler) AdminGetUser(c *gin.Context) {
+	user, ok := h.findAdminTarget(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	summaries, err := h.adminUserResponses(ctx, []models.User{user})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user activity"})
+		return
+	}
+
+	response := AdminUserDetailResponse{AdminUserResponse: summaries[0]}
+	response.ActiveSessions, _ = h.store.Sessions.CountActive(ctx, user.ID, time.Now())
+	if projects, err := h.store.Projects.ListByUser(ctx, user.ID); err == nil {
+		response.ProjectCount = int64(len(projects))
+	}
+
+	if response.RecentDeliveries, err = h.store.Deliveries.ListRecent(ctx, user.ID, adminRecentLimit); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// AdminSetRole назначает роль пользователю
+// @Summary Изменить роль (admin)
+// @Description Назначает роль user или admin. Снять роль admin с себя нельзя
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param request body AdminRoleRequest true "Role"
+// @Success 200 {object} AdminUserResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/users/{id}/role [put]
+func (h *APIHandler) AdminSetRole(c *gin.Context) {
+	user, ok := h.findAdminTarget(c)
+	if !ok {
+		return
+	}
+
+	var req AdminRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if user.ID == c.MustGet("user_id").(uint) && req.Role != models.UserRoleAdmin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot remove your own admin role"})
+		return
+	}
+
+	previous := user.Role
+	if err := h.store.Users.SetRole(c.Request.Context(), user.ID, req.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+	h.recordAudit(c, models.AuditAdminRoleChange, "user", user.ID, gin.H{"from": previous, "to": req.Role})
+
+	h.respondAdminUser(c, user)
+}
+
+// AdminSetPlan назначает тариф пользователю
+// @Summary Изменить тариф (admin)
+// @Description Назначает тариф с квотами использования (см. GET /usage/plans). Приостановленная генерация возобновляется, если новые квоты не исчерпаны
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param request body AdminPlanRequest true "Plan"
+// @Success 200 {object} AdminUserResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/users/{id}/plan [put]
+func (h *APIHandler) AdminSetPlan(c *gin.Context) {
+	user, ok := h.findAdminTarget(c)
+	if !ok {
+		return
+	}
+
+	var req AdminPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, ok := usage.Lookup(req.Plan); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown plan"})
+		return
+	}
+
+	previous := usage.PlanFor(user.Plan).Name
+	if err := h.store.Users.SetPlan(c.Request.Context(), user.ID, req.Plan); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update plan"})
+		return
+	}
+	h.recordAudit(c, models.AuditAdminPlanChange, "user", user.ID, gin.H{"from": previous, "to": req.Plan})
+
+	h.respondAdminUser(c, user)
+}
+
+// AdminDeactivateUser отключает аккаунт
+// @Summary Деактивировать пользователя (admin)
+// @Description Запрещает вход, отзывает сессии и останавливает публикацию постов. Токены и данные сохраняются
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param request body AdminReasonRequest false "Reason"
+// @Success 200 {object} AdminUserResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/users/{id}/deactivate [post]
+func (h *APIHandler) AdminDeactivateUser(c *gin.Context) {
+	user, ok := h.findAdminTarget(c)
+	if !ok {
+		return
+	}
+
+	// Тело с причиной необязательно, но некорректный JSON отклоняется
+	var req AdminReasonRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if user.ID == c.MustGet("user_id").(uint) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot deactivate your own account"})
+		return
+	}
+	if user.IsDeactivated() {
+		c.JSON(http.StatusConflict, gin.H{"error": "User already deactivated"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	err := h.store.Transaction(ctx, func(tx *store.Store) error {
+		now := time.Now()
+		if err := tx.Users.SetDeactivatedAt(ctx, user.ID, &now); err != nil {
+			return err
+		}
+		_, err := tx.Sessions.RevokeUser(ctx, user.ID, now)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate user"})
+		return
+	}
+	h.recordAudit(c, models.AuditAdminDeactivate, "user", user.ID, gin.H{"reason": req.Reason})
+
+	h.respondAdminUser(c, user)
+}
+
+// AdminReactivateUser снова включает аккаунт
+// @Summary Реактивировать пользователя (admin)
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} AdminUserResponse
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/users/{id}/reactivate [post]
+func (h *APIHandler) AdminReactivateUser(c *gin.Context) {
+	user, ok := h.findAdminTarget(c)
+	if !ok {
+		return
+	}
+	if !user.IsDeactivated() {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is active"})
+		return
+	}
+
+	if err := h.store.Users.SetDeactivatedAt(c.Request.Context(), user.ID, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reactivate user"})
+		return
+	}
+	h.recordAudit(c, models.AuditAdminReactivate, "user", user.ID, nil)
+
+	h.respondAdminUser(c, user)
+}
+
+// AdminUnlockUser снимает блокировку входа после неудачных попыток и сбрасывает их счётчик
+// @Summary Снять блокировку входа (admin)
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} AdminUserResponse
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /admin/users/{id}/unlock [post]
+func (h *APIHandler) AdminUnlockUser(c *gin.Context) {
+	user, ok := h.findAdminTarget(c)
+	if !ok {
+		return
+	}
+	if user.FailedLogins == 0 && !user.IsLocked(time.Now()) {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is not locked"})
+		return
+	}
+
+	if err := h.store.Users.SetLoginFailures(c.Request.Context(), user.ID, 0, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+		return
+	}
+	h.recordAudit(c, models.AuditAdminUnlock, "user", user.ID, gin.H{"failed_logins": user.FailedLogins, "locked_until": user.LockedUntil})
+
+	h.respondAdminUser(c, user)
+}
+
+// AdminImpersonateUser открывает сессию от имени пользователя для поддержки
+// @Summary Войти как пользователь (admin)
+// @Description Выдаёт access токен сессии пользователя без refresh токена. Сессия помечается администратором, действие записывается в журнал аудита. Нельзя войти как администратор или отключённый пользователь
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param request body ImpersonateRequest true "Reason"
+// @Success 200 {object} ImpersonateResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/users/{id}/impersonate [post]
+func (h *APIHandler) AdminImpersonateUser(c *gin.Context) {
+	user, ok := h.findAdminTarget(c)
+	if !ok {
+		return
+	}
+
+	var req ImpersonateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if user.IsAdmin() || user.IsDeactivated() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot impersonate this user"})
+		return
+	}
+
+	adminID := c.MustGet("user_id").(uint)
+	session := models.Session{
+		UserID:         user.ID,
+		UserAgent:      c.Request.UserAgent(),
+		IP:             c.ClientIP(),
+		ExpiresAt:      time.Now().Add(auth.AccessTokenTTL()),
+		ImpersonatorID: &adminID,
+	}
+	if err := h.store.Sessions.Create(c.Request.Context(), &session); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+
+	token, err := auth.GenerateToken(user.ID, user.Email, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	h.recordAudit(c, models.AuditAdminImpersonate, "user", user.ID, gin.H{"reason": req.Reason, "session_id": session.ID})
+
+	c.JSON(http.StatusOK, ImpersonateResponse{
+		Token:     token,
+		ExpiresIn: int(auth.AccessTokenTTL().Seconds()),
+		UserID:    user.ID,
+	})
+}
+
+// AdminRotateUserTokens принудительно заменяет все учётные данные пользователя
+// @Summary Принудительная ротация токенов (admin)
+// @Description Отзывает сессии и personal access токены, сразу заменяет webhook токены пользователя и его личных проектов (без grace period)
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} RotateTokensResponse
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/users/{id}/rotate-tokens [post]
+func (h *APIHandler) AdminRotateUserTokens(c *gin.Context) {
+	user, ok := h.findAdminTarget(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	var response RotateTokensResponse
+	err := h.store.Transaction(ctx, func(tx *store.Store) error {
+		now := time.Now()
+
+		var err error
+		if response.RevokedSessions, err = tx.Sessions.RevokeUser(ctx, user.ID, now); err != nil {
+			return err
+		}
+		if response.RevokedPersonalTokens, err = tx.Tokens.RevokeUser(ctx, user.ID, now); err != nil {
+			return err
+		}
+
+		if err := tx.Users.RotateWebhookToken(ctx, user.ID, webhookRotation(user.WebhookToken, 0)); err != nil {
+			return err
+		}
+		response.RotatedWebhooks++
+
+		projects, err := tx.Projects.ListByUser(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		for _, project := range projects {
+			if project.OrganizationID != nil {
+				continue
+			}
+			if err := tx.Projects.RotateWebhookToken(ctx, project.ID, webhookRotation(project.WebhookToken, 0)); err != nil {
+				return err
+			}
+			response.RotatedWebhooks++
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate tokens"})
+		return
+	}
+	h.recordAudit(c, models.AuditAdminRotateTokens, "user", user.ID, gin.H{
+		"revoked_sessions":        response.RevokedSessions,
+		"revoked_personal_tokens": response.RevokedPersonalTokens,
+		"rotated_webhooks":        response.RotatedWebhooks,
+	})
+
+	c.JSON(http.StatusOK, response)
+}
+
+// findAdminTarget загружает пользователя по :id. При ошибке пишет ответ и возвращает false
+func (h *APIHandler) findAdminTarget(c *gin.Context) (models.User, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+		return models.User{}, false
+	}
+
+	user, err := h.store.Users.Get(c.Request.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+		}
+		return user, false
+	}
+	return user, true
+}
+
+// respondAdminUser перечитывает пользователя и отвечает его сводкой
+func (h *APIHandler) respondAdminUser(c *gin.Context, user models.User) {
+	ctx := c.Request.Context()
+	if fresh, err := h.store.Users.Get(ctx, user.ID); err == nil {
+		user = fresh
+	}
+
+	summaries, err := h.adminUserResponses(ctx, []models.User{user})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user activity"})
+		return
+	}
+	c.JSON(http.StatusOK, summaries[0])
+}
+
+// adminUserResponses добавляет к пользователям последний вход и статистику доставок
+func (h *APIHandler) adminUserResponses(ctx context.Context, users []models.User) ([]AdminUserResponse, error) {
+	response := make([]AdminUserResponse, 0, len(users))
+	if len(users) == 0 {
+		return response, nil
+	}
+
+	ids := make([]uint, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+
+	lastLogin, err := h.store.Sessions.LastLogins(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := h.store.Deliveries.Stats(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	for _, user := range users {
+		item := AdminUserResponse{
+			ID:              user.ID,
+			Email:           user.Email,
+			Name:            user.Name,
+			Role:            user.Role,
+			Plan:            usage.PlanFor(user.Plan).Name,
+			EmailVerifiedAt: user.EmailVerifiedAt,
+			TOTPEnabled:     user.TOTPEnabled,
+			DeactivatedAt:   user.DeactivatedAt,
+			CreatedAt:       user.CreatedAt,
+			FailedLogins:    user.FailedLogins,
+		}
+		if user.IsLocked(now) {
+			item.LockedUntil = user.LockedUntil
+		}
+		if t, ok := lastLogin[user.ID]; ok {
+			item.LastLoginAt = &t
+		}
+		if s, ok := stats[user.ID]; ok {
+			item.DeliveryCount = s.Total
+			item.FailedDeliveries = s.Failed
+		}
+		response = append(response, item)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"commitcaster/internal/models"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newAdmin регистрирует администратора и возвращает его access токен
+func newAdmin(s *testServer, email string) string {
+	s.t.Helper()
+
+	registered := s.register(email, "password123")
+	if err := s.store.Users.SetRole(s.t.Context(), s.user(email).ID, models.UserRoleAdmin); err != nil {
+		s.t.Fatal(err)
+	}
+	return registered.Token
+}
+
+func TestAdminDeactivateUserBody(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"empty body", "", http.StatusOK},
+		{"reason", `{"reason":"spam"}`, http.StatusOK},
+		{"invalid JSON", `{"reason":`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			admin := newAdmin(s, "admin@example.com")
+			s.register("alice@example.com", "password123")
+			user := s.user("alice@example.com")
+
+			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/admin/users/%d/deactivate", user.ID), strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+admin)
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, req)
+			expectStatus(t, w, tt.status)
+
+			user = s.user("alice@example.com")
+			if deactivated := user.IsDeactivated(); deactivated != (tt.status == http.StatusOK) {
+				t.Errorf("deactivated = %v after status %d", deactivated, w.Code)
+			}
+		})
+	}
+}
+
+func TestAdminSeesAndClearsLockout(t *testing.T) {
+	t.Setenv("LOGIN_MAX_ATTEMPTS", "2")
+	s := newTestServer(t)
+	admin := newAdmin(s, "admin@example.com")
+	s.register("alice@example.com", "password123")
+	user := s.user("alice@example.com")
+	path := fmt.Sprintf("/api/admin/users/%d", user.ID)
+
+	wrong := LoginRequest{Email: "alice@example.com", Password: "wrong-password"}
+	expectStatus(t, s.do(http.MethodPost, "/api/auth/login", "", wrong), http.StatusUnauthorized)
+
+	w := s.do(http.MethodGet, path, admin, nil)
+	expectStatus(t, w, http.StatusOK)
+	if got := decode[AdminUserDetailResponse](t, w); got.FailedLogins != 1 || got.LockedUntil != nil {
+		t.Errorf("after one failure: failed_logins=%d locked_until=%v", got.FailedLogins, got.LockedUntil)
+	}
+
+	expectStatus(t, s.do(http.MethodPost, "/api/auth/login", "", wrong), http.StatusUnauthorized)
+	w = s.do(http.MethodGet, path, admin, nil)
+	expectStatus(t, w, http.StatusOK)
+	if got := decode[AdminUserDetailResponse](t, w); got.LockedUntil == nil {
+		t.Fatal("locked user has no locked_until")
+	}
+
+	w = s.do(http.MethodPost, path+"/unlock", admin, nil)
+	expectStatus(t, w, http.StatusOK)
+	if got := decode[AdminUserResponse](t, w); got.FailedLogins != 0 || got.LockedUntil != nil {
+		t.Errorf("after unlock: failed_logins=%d locked_until=%v", got.FailedLogins, got.LockedUntil)
+	}
+	expectStatus(t, s.do(http.MethodPost, "/api/auth/login", "", LoginRequest{Email: "alice@example.com", Password: "password123"}), http.StatusOK)
+
+	// Снимать нечего
+	expectStatus(t, s.do(http.MethodPost, path+"/unlock", admin, nil), http.StatusConflict)
+}
@@ -2,8 +2,12 @@ package handlers
 
 import (
 	"commitcaster/internal/auth"
-	"commitcaster/internal/database"
+	"commitcaster/internal/jobs"
+	"commitcaster/internal/mailer"
 	"commitcaster/internal/models"
+	"commitcaster/internal/services"
+	"commitcaster/internal/store"
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
@@ -13,10 +17,16 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-type APIHandler struct{}
+type APIHandler struct {
+	store  *store.Store
+	mailer mailer.Mailer
+	github *services.GitHubOAuthService
+	// jobs выполняет работу, которую запрос не ждёт (письмо сброса пароля)
+	jobs *jobs.Tracker
+}
 
-func NewAPIHandler() *APIHandler {
-	return &APIHandler{}
+func NewAPIHandler(st *store.Store, m mailer.Mailer, github *services.GitHubOAuthService, tracker *jobs.Tracker) *APIHandler {
+	return &APIHandler{store: st, mailer: m, github: github, jobs: tracker}
 }
 
 // Структуры для запросов/ответов
@@ -34,8 +44,13 @@ type LoginRequest struct {
 
 type AuthResponse struct {
 	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
 	WebhookToken string `json:"webhook_token"`
 	WebhookURL   string `json:"webhook_url"`
+
+	// Секрет для подписи webhook; возвращается только при регистрации
+	GitHubSecret string `json:"github_secret,omitempty"`
 }
 
 type SettingsRequest struct {
@@ -49,9 +64,17 @@ type SettingsRequest struct {
 	CustomPrompt      string `json:"custom_prompt"`
 }
 
+type SettingsResponse struct {
+	models.UserSettings
+	// WebhookSecretMissing — у webhook нет секрета (аккаунт создан до автоматических
+	// секретов): доставки принимаются без подписи, пока секрет не создан через
+	// POST /api/webhook/secret, или отклоняются при WEBHOOK_ALLOW_UNSIGNED=false
+	WebhookSecretMissing bool `json:"webhook_secret_missing,omitempty"`
+}
+
 // Register регистрирует нового пользователя
 // @Summary Регистрация нового пользователя
-// @Description Создаёт нового пользователя и возвращает JWT токен
+// @Description Создаёт нового пользователя и возвращает access и refresh токены, а также секрет для подписи webhook (показывается один раз)
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -67,11 +90,10 @@ func (h *APIHandler) Register(c *gin.Context) {
 		return
 	}
 
-	db := database.GetDB()
+	ctx := c.Request.Context()
 
 	// Проверяем существование пользователя
-	var existingUser models.User
-	if err := db.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
+	if _, err := h.store.Users.GetByEmail(ctx, req.Email); err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
 		return
 	}
@@ -89,43 +111,28 @@ func (h *APIHandler) Register(c *gin.Context) {
 	}
 
 	// Создаём с настройками по умолчанию
-	if err := db.Create(&user).Error; err != nil {
+	if err := createUser(ctx, h.store, &user); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
 
-	// Создаём пустые настройки
-	settings := models.UserSettings{
-		UserID:   user.ID,
-		IsActive: false, // Неактивен пока не заполнены токены
-	}
-	if err := db.Create(&settings).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create settings"})
-		return
-	}
+	// Письмо для подтверждения email; ошибка отправки не мешает регистрации
+	h.sendVerificationEmail(ctx, &user)
 
-	// Генерируем JWT
-	token, err := auth.GenerateToken(user.ID, user.Email)
+	// Создаём сессию и генерируем JWT
+	response, err := h.issueTokens(c, &user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
+	response.GitHubSecret = user.Settings.GitHubSecret
 
-	baseURL := os.Getenv("BASE_URL")
-	if baseURL == "" {
-		baseURL = "http://localhost:8080"
-	}
-
-	c.JSON(http.StatusCreated, AuthResponse{
-		Token:        token,
-		WebhookToken: user.WebhookToken,
-		WebhookURL:   fmt.Sprintf("%s/webhook/github/%s", baseURL, user.WebhookToken),
-	})
+	c.JSON(http.StatusCreated, response)
 }
 
 // Login авторизует пользователя
 // @Summary Авторизация пользователя
-// @Description Авторизует пользователя и возвращает JWT токен
+// @Description Авторизует пользователя и возвращает access и refresh токены. Если включена 2FA, возвращает challenge токен для /auth/2fa/verify
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -133,6 +140,7 @@ func (h *APIHandler) Register(c *gin.Context) {
 // @Success 200 {object} AuthResponse
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]interface{}
 // @Router /auth/login [post]
 func (h *APIHandler) Login(c *gin.Context) {
 	var req LoginRequest
@@ -141,35 +149,27 @@ func (h *APIHandler) Login(c *gin.Context) {
 		return
 	}
 
-	db := database.GetDB()
-
-	var user models.User
-	if err := db.Where("email = ?", req.Email).First(&user).Error; err != nil {
+	user, err := h.store.Users.GetByEmail(c.Request.Context(), req.Email)
+	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	if !user.CheckPassword(req.Password) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	if rejectLockedUser(c, &user) {
 		return
 	}
 
-	token, err := auth.GenerateToken(user.ID, user.Email)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+	if !user.CheckPassword(req.Password) {
+		h.recordLoginFailure(c, &user, "invalid_password")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	baseURL := os.Getenv("BASE_URL")
-	if baseURL == "" {
-		baseURL = "http://localhost:8080"
+	// С включённой 2FA счётчик сбрасывается только после проверки кода
+	if !user.TOTPEnabled {
+		h.resetLoginFailures(c.Request.Context(), &user)
 	}
-
-	c.JSON(http.StatusOK, AuthResponse{
-		Token:        token,
-		WebhookToken: user.WebhookToken,
-		WebhookURL:   fmt.Sprintf("%s/webhook/github/%s", baseURL, user.WebhookToken),
-	})
+	h.completeLogin(c, &user, "password")
 }
 
 // GetSettings получает настройки текущего пользователя
@@ -178,21 +178,20 @@ func (h *APIHandler) Login(c *gin.Context) {
 // @Tags settings
 // @Security BearerAuth
 // @Produce json
-// @Success 200 {object} models.UserSettings
+// @Success 200 {object} SettingsResponse
 // @Failure 401 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Router /settings [get]
 func (h *APIHandler) GetSettings(c *gin.Context) {
 	userID := c.MustGet("user_id").(uint)
-	db := database.GetDB()
 
-	var settings models.UserSettings
-	if err := db.Where("user_id = ?", userID).First(&settings).Error; err != nil {
+	settings, err := h.store.Settings.GetByUser(c.Request.Context(), userID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Settings not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, settings)
+	c.JSON(http.StatusOK, newSettingsResponse(settings))
 }
 
 // UpdateSettings обновляет настройки текущего пользователя
@@ -203,7 +202,7 @@ func (h *APIHandler) GetSettings(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param request body SettingsRequest true "Settings to update"
-// @Success 200 {object} models.UserSettings
+// @Success 200 {object} SettingsResponse
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
 // @Failure 404 {object} map[string]string
@@ -217,25 +216,25 @@ func (h *APIHandler) UpdateSettings(c *gin.Context) {
 		return
 	}
 
-	db := database.GetDB()
-
-	var settings models.UserSettings
-	if err := db.Where("user_id = ?", userID).First(&settings).Error; err != nil {
+	settings, err := h.store.Settings.GetByUser(c.Request.Context(), userID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Settings not found"})
 		return
 	}
+	before := auditSnapshot(settings)
 
-	// Обновляем поля
-	if req.TelegramBotToken != "" {
+	// Обновляем поля. Замаскированные токены и секреты из GET /settings
+	// не перезаписывают настоящие
+	if req.TelegramBotToken != "" && !auth.IsMaskedSecret(req.TelegramBotToken) {
 		settings.TelegramBotToken = req.TelegramBotToken
 	}
 	if req.TelegramChannelID != "" {
 		settings.TelegramChannelID = req.TelegramChannelID
 	}
-	if req.GroqAPIKey != "" {
+	if req.GroqAPIKey != "" && !auth.IsMaskedSecret(req.GroqAPIKey) {
 		settings.GroqAPIKey = req.GroqAPIKey
 	}
-	if req.GitHubSecret != "" {
+	if req.GitHubSecret != "" && !auth.IsMaskedSecret(req.GitHubSecret) {
 		settings.GitHubSecret = req.GitHubSecret
 	}
 	if req.AIModel != "" {
@@ -256,43 +255,83 @@ func (h *APIHandler) UpdateSettings(c *gin.Context) {
 		settings.IsActive = true
 	}
 
-	if err := db.Save(&settings).Error; err != nil {
+	if err := h.store.Settings.Save(c.Request.Context(), &settings); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update settings"})
 		return
 	}
+	if changes := auditChanges(before, settings); len(changes) > 0 {
+		h.recordAudit(c, models.AuditSettingsUpdate, "user", userID, gin.H{"changes": changes})
+	}
 
-	c.JSON(http.StatusOK, settings)
+	c.JSON(http.StatusOK, newSettingsResponse(settings))
 }
 
 // GetWebhookInfo возвращает информацию о webhook URL
 // @Summary Получить webhook URL
-// @Description Возвращает уникальный webhook URL пользователя для настройки GitHub
+// @Description Возвращает уникальный webhook URL пользователя для настройки GitHub, а после ротации — и прежний токен со сроком действия
 // @Tags webhook
 // @Security BearerAuth
 // @Produce json
-// @Success 200 {object} map[string]string
+// @Success 200 {object} WebhookInfoResponse
 // @Failure 401 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Router /webhook [get]
 func (h *APIHandler) GetWebhookInfo(c *gin.Context) {
 	userID := c.MustGet("user_id").(uint)
-	db := database.GetDB()
 
-	var user models.User
-	if err := db.First(&user, userID).Error; err != nil {
+	user, err := h.store.Users.Get(c.Request.Context(), userID)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
+	c.JSON(http.StatusOK, newWebhookInfo(user.WebhookToken, user.PreviousWebhookToken, user.PreviousWebhookTokenExpiresAt))
+}
+
+// createUser создаёт пользователя вместе с пустыми настройками
+func createUser(ctx context.Context, st *store.Store, user *models.User) error {
+	return st.Transaction(ctx, func(tx *store.Store) error {
+		if err := tx.Users.Create(ctx, user); err != nil {
+			return err
+		}
+
+		// Создаём пустые настройки с новым секретом для подписи webhook
+		settings := models.UserSettings{
+			UserID:       user.ID,
+			GitHubSecret: auth.GenerateWebhookSecret(),
+			IsActive:     false, // Неактивен пока не заполнены токены
+		}
+		if err := tx.Settings.Create(ctx, &settings); err != nil {
+			return err
+		}
+		user.Settings = settings
+		return nil
+	})
+}
+
+// maskSettings скрывает токен бота, ключ AI и секрет webhook в ответе
+func maskSettings(settings models.UserSettings) models.UserSettings {
+	settings.TelegramBotToken = auth.MaskSecret(settings.TelegramBotToken)
+	settings.GroqAPIKey = auth.MaskSecret(settings.GroqAPIKey)
+	settings.GitHubSecret = auth.MaskSecret(settings.GitHubSecret)
+	return settings
+}
+
+// newSettingsResponse — настройки для ответа API с замаскированными секретами
+func newSettingsResponse(settings models.UserSettings) SettingsResponse {
+	return SettingsResponse{
+		UserSettings:         maskSettings(settings),
+		WebhookSecretMissing: settings.GitHubSecret == "",
+	}
+}
+
+// webhookURL возвращает публичный URL webhook для токена
+func webhookURL(token string) string {
 	baseURL := os.Getenv("BASE_URL")
 	if baseURL == "" {
 		baseURL = "http://localhost:8080"
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"webhook_token": user.WebhookToken,
-		"webhook_url":   fmt.Sprintf("%s/webhook/github/%s", baseURL, user.WebhookToken),
-	})
+	return fmt.Sprintf("%s/webhook/github/%s", baseURL, token)
 }
 
 // generateToken генерирует случайный токен для webhook
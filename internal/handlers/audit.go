@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"commitcaster/internal/models"
+	"commitcaster/internal/store"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditRedacted заменяет значения секретов в журнале аудита
+const auditRedacted = "[redacted]"
+
+// auditSecretFields — поля, значения которых не попадают в журнал
+var auditSecretFields = map[string]bool{
+	"telegram_bot_token":     true,
+	"groq_api_key":           true,
+	"github_secret":          true,
+	"webhook_token":          true,
+	"previous_webhook_token": true,
+	"totp_secret":            true,
+	"password":               true,
+	"token":                  true,
+}
+
+// auditIgnoredFields — служебные поля, которые не считаются изменениями
+var auditIgnoredFields = map[string]bool{
+	"id":         true,
+	"created_at": true,
+	"updated_at": true,
+	"project_id": true,
+}
+
+// AuditLogResponse — запись журнала аудита с разобранными details
+type AuditLogResponse struct {
+	models.AuditLog
+	Details json.RawMessage `json:"details,omitempty" swaggertype:"object"`
+}
+
+type AuditLogListResponse struct {
+	Entries []AuditLogResponse `json:"entries"`
+	Total   int64              `json:"total"`
+	Page    int                `json:"page"`
+	PerPage int                `json:"per_page"`
+}
+
+// ListAuditLog возвращает журнал аудита текущего пользователя
+// @Summary Журнал аудита
+// @Description Действия пользователя и действия над ним, его проектами и организациями: входы, изменения настроек и проектов (с изменёнными полями, секреты скрыты), ротации токенов, участники организаций. IP и User-Agent видны только в собственных действиях пользователя и попытках входа в его аккаунт. Новые записи первыми
+// @Tags audit
+// @Security BearerAuth
+// @Produce json
+// @Param action query string false "Action, e.g. settings.update"
+// @Param target_type query string false "user, project, organization or token"
+// @Param target_id query int false "Target ID"
+// @Param since query string false "RFC3339 time"
+// @Param until query string false "RFC3339 time"
+// @Param page query int false "Page number (from 1)"
+// @Param per_page query int false "Page size (max 200)"
+// @Success 200 {object} AuditLogListResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /audit [get]
+func (h *APIHandler) ListAuditLog(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	h.listAuditLog(c, store.AuditFilter{VisibleTo: userID}, userID)
+}
+
+// AdminListAuditLog возвращает весь журнал аудита
+// @Summary Журнал аудита (admin)
+// @Description Все записи журнала аудита с фильтрами. Новые записи первыми
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param actor_id query int false "Actor user ID"
+// @Param action query string false "Action, e.g. auth.login_failed"
+// @Param target_type query string false "user, project, organization or token"
+// @Param target_id query int false "Target ID"
+// @Param since query string false "RFC3339 time"
+// @Param until query string false "RFC3339 time"
+// @Param page query int false "Page number (from 1)"
+// @Param per_page query int false "Page size (max 200)"
+// @Success 200 {object} AuditLogListResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/audit [get]
+func (h *APIHandler) AdminListAuditLog(c *gin.Context) {
+	var filter store.AuditFilter
+	if actorID := c.Query("actor_id"); actorID != "" {
+		id, err := strconv.ParseUint(actorID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid actor_id"})
+			return
+		}
+		actor := uint(id)
+		filter.ActorID = &actor
+	}
+	h.listAuditLog(c, filter, 0)
+}
+
+// listAuditLog дополняет filter общими фильтрами и пагинацией из запроса.
+// viewerID — пользователь, который смотрит журнал: IP и User-Agent других участников
+// организаций ему не показываются; 0 — администратор сервиса, видит всё
+func (h *APIHandler) listAuditLog(c *gin.Context, filter store.AuditFilter, viewerID uint) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", strconv.Itoa(adminDefaultPerPage)))
+	if perPage < 1 || perPage > adminMaxPerPage {
+		perPage = adminDefaultPerPage
+	}
+
+	filter.Action = c.Query("action")
+	filter.TargetType = c.Query("target_type")
+	if targetID := c.Query("target_id"); targetID != "" {
+		id, err := strconv.ParseUint(targetID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target_id"})
+			return
+		}
+		target := uint(id)
+		filter.TargetID = &target
+	}
+	for param, bound := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + ", expected RFC3339"})
+			return
+		}
+		*bound = &t
+	}
+	filter.Offset = (page - 1) * perPage
+	filter.Limit = perPage
+
+	entries, total, err := h.store.Audit.List(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load audit log"})
+		return
+	}
+
+	if viewerID != 0 {
+		hideAuditClients(entries, viewerID)
+	}
+
+	c.JSON(http.StatusOK, AuditLogListResponse{
+		Entries: auditLogResponses(entries),
+		Total:   total,
+		Page:    page,
+		PerPage: perPage,
+	})
+}
+
+// hideAuditClients убирает IP и User-Agent из записей, которые не являются действиями
+// пользователя или анонимными попытками входа в его аккаунт
+func hideAuditClients(entries []models.AuditLog, userID uint) {
+	for i, entry := range entries {
+		if entry.ActorID != nil && *entry.ActorID == userID {
+			continue
+		}
+		if entry.ActorID == nil && entry.TargetType == "user" && entry.TargetID != nil && *entry.TargetID == userID {
+			continue
+		}
+		entries[i].IP = ""
+		entries[i].UserAgent = ""
+	}
+}
+
+// auditLogResponses отдаёт details как JSON объект, а не строку
+func auditLogResponses(entries []models.AuditLog) []AuditLogResponse {
+	response := make([]AuditLogResponse, 0, len(entries))
+	for _, entry := range entries {
+		item := AuditLogResponse{AuditLog: entry}
+		if entry.Details != "" {
+			item.Details = json.RawMessage(entry.Details)
+		}
+		response = append(response, item)
+	}
+	return response
+}
+
+// recordAudit добавляет запись в журнал аудита от имени текущего пользователя.
+// Ошибка записи логируется и не прерывает запрос
+func (h *APIHandler) recordAudit(c *gin.Context, action, targetType string, targetID uint, details gin.H) {
+	var actor *uint
+	if actorID, ok := c.Get("user_id"); ok {
+		id := actorID.(uint)
+		actor = &id
+	}
+	h.writeAudit(c, actor, action, targetType, targetID, details)
+}
+
+// recordAuditAs добавляет запись от имени пользователя, который ещё не аутентифицирован
+// в запросе (вход, сброс пароля). actorID = 0 — анонимный запрос
+func (h *APIHandler) recordAuditAs(c *gin.Context, actorID uint, action, targetType string, targetID uint, details gin.H) {
+	var actor *uint
+	if actorID != 0 {
+		actor = &actorID
+	}
+	h.writeAudit(c, actor, action, targetType, targetID, details)
+}
+
+func (h *APIHandler) writeAudit(c *gin.Context, actorID *uint, action, targetType string, targetID uint, details gin.H) {
+	entry := models.AuditLog{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: targetType,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+	if targetID != 0 {
+		entry.TargetID = &targetID
+	}
+
+	// Действия в режиме impersonation помечаются администратором
+	if impersonatorID, ok := c.Get("impersonator_id"); ok {
+		if details == nil {
+			details = gin.H{}
+		}
+		details["impersonator_id"] = impersonatorID
+	}
+	if len(details) > 0 {
+		if data, err := json.Marshal(details); err == nil {
+			entry.Details = string(data)
+		}
+	}
+
+	if err := h.store.Audit.Create(c.Request.Context(), &entry); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to write audit log", "action", action, "error", err)
+	}
+}
+
+// auditChanges сравнивает JSON представления объекта до и после изменения
+// и возвращает изменённые поля в виде {"поле": {"from": ..., "to": ...}}.
+// Значения секретов заменяются на [redacted], но факт изменения виден
+func auditChanges(before, after interface{}) gin.H {
+	from, to := auditSnapshot(before), auditSnapshot(after)
+
+	changes := gin.H{}
+	for key := range mergeKeys(from, to) {
+		if auditIgnoredFields[key] || reflect.DeepEqual(from[key], to[key]) {
+			continue
+		}
+		changes[key] = gin.H{"from": redactAuditValue(key, from[key]), "to": redactAuditValue(key, to[key])}
+	}
+	return changes
+}
+
+// auditSnapshot — JSON снимок объекта для auditChanges. Снимок нужно делать
+// до изменения объекта: вложенные срезы могут изменяться на месте
+func auditSnapshot(value interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if value == nil {
+		return fields
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fields
+	}
+	json.Unmarshal(data, &fields)
+	return stripAuditFields(fields).(map[string]interface{})
+}
+
+// stripAuditFields убирает служебные поля во вложенных объектах (каналы, правила),
+// чтобы пересоздание записей без изменений не считалось изменением
+func stripAuditFields(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if auditIgnoredFields[key] {
+				delete(v, key)
+				continue
+			}
+			v[key] = stripAuditFields(item)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = stripAuditFields(v[i])
+		}
+	}
+	return value
+}
+
+func mergeKeys(a, b map[string]interface{}) map[string]bool {
+	keys := map[string]bool{}
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	return keys
+}
+
+// redactAuditValue скрывает значения секретов, в том числе во вложенных объектах
+func redactAuditValue(key string, value interface{}) interface{} {
+	if auditSecretFields[key] {
+		if value == nil || value == "" {
+			return value
+		}
+		return auditRedacted
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			redacted[k] = redactAuditValue(k, item)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = redactAuditValue("", item)
+		}
+		return redacted
+	}
+	return value
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"bytes"
+	"commitcaster/internal/jobs"
+	"commitcaster/internal/mailer"
+	"commitcaster/internal/middleware"
+	"commitcaster/internal/models"
+	"commitcaster/internal/ratelimit"
+	"commitcaster/internal/store"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testServer — маршруты SaaS режима поверх хранилища в памяти
+type testServer struct {
+	t      *testing.T
+	store  *store.Store
+	router *gin.Engine
+	jobs   *jobs.Tracker
+	mailer *testMailer
+}
+
+// testMailer запоминает отправленные письма
+type testMailer struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+}
+
+func (m *testMailer) Send(msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func (m *testMailer) messages() []mailer.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]mailer.Message(nil), m.sent...)
+}
+
+// newTestServer собирает маршруты так же, как cmd/bot/main.go, но без rate limiting по IP
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	st := store.NewMemory()
+	mail := &testMailer{}
+	tracker := jobs.NewTracker()
+	api := NewAPIHandler(st, mail, nil, tracker)
+	webhooks := NewMultiUserWebhookHandler(st, tracker, ratelimit.NewMemoryLimiter(), ratelimit.Rule{Limit: 10, Period: time.Minute})
+
+	r := gin.New()
+
+	authRoutes := r.Group("/api/auth")
+	authRoutes.POST("/register", api.Register)
+	authRoutes.POST("/login", api.Login)
+	authRoutes.POST("/refresh", api.RefreshToken)
+	authRoutes.POST("/2fa/verify", api.VerifyTwoFactor)
+	authRoutes.POST("/forgot", api.ForgotPassword)
+
+	protected := r.Group("/api")
+	protected.Use(middleware.AuthMiddleware(st))
+
+	session := protected.Group("")
+	session.Use(middleware.RequireSession())
+	session.GET("/tokens", api.ListTokens)
+
+	own := session.Group("")
+	own.Use(middleware.RequireOwnSession())
+	own.POST("/auth/2fa/enroll", api.EnrollTwoFactor)
+	own.POST("/auth/2fa/confirm", api.ConfirmTwoFactor)
+	own.POST("/auth/2fa/disable", api.DisableTwoFactor)
+	own.POST("/auth/2fa/recovery-codes", api.RegenerateRecoveryCodes)
+	own.POST("/tokens", api.CreateToken)
+	own.DELETE("/account", api.DeleteAccount)
+
+	protected.GET("/settings", middleware.RequireScope(models.ScopeSettingsRead), api.GetSettings)
+	protected.PUT("/settings", middleware.RequireScope(models.ScopeSettingsWrite), api.UpdateSettings)
+	protected.POST("/webhook/secret", middleware.RequireScope(models.ScopeWebhookWrite), api.RegenerateWebhookSecret)
+	protected.GET("/projects", middleware.RequireScope(models.ScopeProjectsRead), api.ListProjects)
+
+	admin := r.Group("/api/admin")
+	admin.Use(middleware.AuthMiddleware(st), middleware.RequireAdmin(st))
+	admin.GET("/users/:id", api.AdminGetUser)
+	admin.POST("/users/:id/deactivate", api.AdminDeactivateUser)
+	admin.POST("/users/:id/unlock", api.AdminUnlockUser)
+
+	r.POST("/webhook/github/:token", webhooks.HandleGitHubWebhook)
+
+	return &testServer{t: t, store: st, router: r, jobs: tracker, mailer: mail}
+}
+
+// do выполняет запрос; body кодируется в JSON, token передаётся как Bearer
+func (s *testServer) do(method, path, token string, body any) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatalf("marshal request: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// register создаёт пользователя и возвращает ответ с токенами
+func (s *testServer) register(email, password string) AuthResponse {
+	s.t.Helper()
+
+	w := s.do(http.MethodPost, "/api/auth/register", "", RegisterRequest{Email: email, Password: password, Name: "Test"})
+	expectStatus(s.t, w, http.StatusCreated)
+	return decode[AuthResponse](s.t, w)
+}
+
+// user загружает пользователя из хранилища по email
+func (s *testServer) user(email string) models.User {
+	s.t.Helper()
+
+	user, err := s.store.Users.GetByEmail(s.t.Context(), email)
+	if err != nil {
+		s.t.Fatalf("load user %s: %v", email, err)
+	}
+	return user
+}
+
+func expectStatus(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("status = %d, want %d; body: %s", w.Code, status, w.Body.String())
+	}
+}
+
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+
+	var v T
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decode response %s: %v", w.Body.String(), err)
+	}
+	return v
+}
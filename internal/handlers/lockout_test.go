@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoginLockout(t *testing.T) {
+	t.Setenv("LOGIN_MAX_ATTEMPTS", "3")
+	s := newTestServer(t)
+	s.register("alice@example.com", "password123")
+
+	wrong := LoginRequest{Email: "alice@example.com", Password: "wrong-password"}
+	for i := 0; i < 3; i++ {
+		expectStatus(t, s.do(http.MethodPost, "/api/auth/login", "", wrong), http.StatusUnauthorized)
+	}
+
+	// Пока вход заблокирован, не проходит и верный пароль
+	w := s.do(http.MethodPost, "/api/auth/login", "", LoginRequest{Email: "alice@example.com", Password: "password123"})
+	expectStatus(t, w, http.StatusTooManyRequests)
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("locked login response has no Retry-After header")
+	}
+
+	// После окончания блокировки вход снова возможен и счётчик сбрасывается
+	user := s.user("alice@example.com")
+	expired := time.Now().Add(-time.Minute)
+	if err := s.store.Users.SetLoginFailures(t.Context(), user.ID, 0, &expired); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, s.do(http.MethodPost, "/api/auth/login", "", LoginRequest{Email: "alice@example.com", Password: "password123"}), http.StatusOK)
+	if user := s.user("alice@example.com"); user.FailedLogins != 0 || user.LockedUntil != nil {
+		t.Errorf("failures not reset: failed_logins=%d locked_until=%v", user.FailedLogins, user.LockedUntil)
+	}
+}
+
+func TestLoginLockoutConcurrentAttempts(t *testing.T) {
+	t.Setenv("LOGIN_MAX_ATTEMPTS", "5")
+	s := newTestServer(t)
+	s.register("alice@example.com", "password123")
+
+	// Параллельные попытки не должны обходить лимит
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.do(http.MethodPost, "/api/auth/login", "", LoginRequest{Email: "alice@example.com", Password: "wrong-password"})
+		}()
+	}
+	wg.Wait()
+
+	w := s.do(http.MethodPost, "/api/auth/login", "", LoginRequest{Email: "alice@example.com", Password: "password123"})
+	expectStatus(t, w, http.StatusTooManyRequests)
+}
+
+func TestLoginLockoutCountsTwoFactorFailures(t *testing.T) {
+	t.Setenv("LOGIN_MAX_ATTEMPTS", "2")
+	s := newTestServer(t)
+	registered := s.register("alice@example.com", "password123")
+	enableTwoFactor(s, registered.Token)
+
+	for i := 0; i < 2; i++ {
+		w := s.do(http.MethodPost, "/api/auth/2fa/verify", "", TwoFactorVerifyRequest{
+			ChallengeToken: challenge(s, "alice@example.com", "password123"),
+			Code:           "000000",
+		})
+		expectStatus(t, w, http.StatusUnauthorized)
+	}
+
+	w := s.do(http.MethodPost, "/api/auth/login", "", LoginRequest{Email: "alice@example.com", Password: "password123"})
+	expectStatus(t, w, http.StatusTooManyRequests)
+}
+
+func TestLockoutCountsTwoFactorManagementFailures(t *testing.T) {
+	t.Setenv("LOGIN_MAX_ATTEMPTS", "2")
+	s := newTestServer(t)
+	registered := s.register("alice@example.com", "password123")
+	secret, _ := enableTwoFactor(s, registered.Token)
+
+	expectStatus(t, s.do(http.MethodPost, "/api/auth/2fa/disable", registered.Token, TwoFactorCodeRequest{Code: "000000"}), http.StatusBadRequest)
+	expectStatus(t, s.do(http.MethodPost, "/api/auth/2fa/recovery-codes", registered.Token, TwoFactorCodeRequest{Code: "000000"}), http.StatusBadRequest)
+
+	// Пока вход заблокирован, не проверяется и верный код
+	code := totpAt(t, secret, time.Now().Unix()/30)
+	expectStatus(t, s.do(http.MethodPost, "/api/auth/2fa/disable", registered.Token, TwoFactorCodeRequest{Code: code}), http.StatusTooManyRequests)
+	expectStatus(t, s.do(http.MethodPost, "/api/auth/2fa/recovery-codes", registered.Token, TwoFactorCodeRequest{Code: code}), http.StatusTooManyRequests)
+	expectStatus(t, s.do(http.MethodPost, "/api/auth/login", "", LoginRequest{Email: "alice@example.com", Password: "password123"}), http.StatusTooManyRequests)
+
+	user := s.user("alice@example.com")
+	if !user.TOTPEnabled {
+		t.Fatal("two-factor authentication disabled while locked")
+	}
+
+	// После блокировки верный код отключает 2FA и сбрасывает счётчик
+	expired := time.Now().Add(-time.Minute)
+	if err := s.store.Users.SetLoginFailures(t.Context(), user.ID, 1, &expired); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, s.do(http.MethodPost, "/api/auth/2fa/disable", registered.Token, TwoFactorCodeRequest{Code: code}), http.StatusOK)
+	if user := s.user("alice@example.com"); user.TOTPEnabled || user.FailedLogins != 0 || user.LockedUntil != nil {
+		t.Errorf("after disable: totp_enabled=%v failed_logins=%d locked_until=%v", user.TOTPEnabled, user.FailedLogins, user.LockedUntil)
+	}
+}
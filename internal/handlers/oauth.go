@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"commitcaster/internal/auth"
+	"commitcaster/internal/models"
+	"commitcaster/internal/services"
+	"commitcaster/internal/store"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	oauthStatePurpose = "github_oauth"
+	oauthStateCookie  = "oauth_state"
+	oauthStateTTL     = 10 * time.Minute
+)
+
+// GitHubLogin начинает вход через GitHub
+// @Summary Вход через GitHub
+// @Description Перенаправляет на страницу авторизации GitHub. С redirect=false возвращает адрес авторизации в JSON
+// @Tags auth
+// @Produce json
+// @Param redirect query bool false "false — вернуть authorization_url вместо редиректа"
+// @Success 200 {object} map[string]string
+// @Success 302
+// @Failure 404 {object} map[string]string
+// @Router /auth/github [get]
+func (h *APIHandler) GitHubLogin(c *gin.Context) {
+	if !h.github.Enabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "GitHub login is not configured"})
+		return
+	}
+
+	// state подписан и привязан к браузеру через cookie с nonce
+	nonce := auth.RandomToken(16)
+	state, err := auth.GeneratePurposeToken(oauthStatePurpose, 0, nonce, oauthStateTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start GitHub login"})
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, nonce, int(oauthStateTTL.Seconds()), "/api/auth/github", "", isSecureRequest(c), true)
+
+	authorizationURL := h.github.AuthorizationURL(state)
+	if c.Query("redirect") == "false" {
+		c.JSON(http.StatusOK, gin.H{"authorization_url": authorizationURL})
+		return
+	}
+
+	c.Redirect(http.StatusFound, authorizationURL)
+}
+
+// GitHubCallback завершает вход через GitHub
+// @Summary Callback GitHub OAuth
+// @Description Обменивает код на профиль GitHub, находит или создаёт пользователя (привязка по подтверждённому email) и возвращает те же токены или 2FA challenge, что и login
+// @Tags auth
+// @Produce json
+// @Param code query string true "Authorization code"
+// @Param state query string true "OAuth state"
+// @Success 200 {object} AuthResponse
+// @Failure 400 {object} map[string]string
+// @Failure 502 {object} map[string]string
+// @Router /auth/github/callback [get]
+func (h *APIHandler) GitHubCallback(c *gin.Context) {
+	if !h.github.Enabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "GitHub login is not configured"})
+		return
+	}
+
+	if oauthErr := c.Query("error"); oauthErr != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "GitHub authorization failed: " + oauthErr})
+		return
+	}
+
+	claims, err := auth.ValidatePurposeToken(c.Query("state"), oauthStatePurpose)
+	nonce, _ := c.Cookie(oauthStateCookie)
+	if err != nil || nonce == "" || subtle.ConstantTimeCompare([]byte(nonce), []byte(claims.Nonce)) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid OAuth state"})
+		return
+	}
+	c.SetCookie(oauthStateCookie, "", -1, "/api/auth/github", "", isSecureRequest(c), true)
+
+	code := c.Query("code")
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Authorization code required"})
+		return
+	}
+
+	accessToken, err := h.github.Exchange(code)
+	if err != nil {
+		slog.WarnContext(c.Request.Context(), "GitHub OAuth exchange failed", "error", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to authenticate with GitHub"})
+		return
+	}
+
+	githubUser, err := h.github.FetchUser(accessToken)
+	if err != nil {
+		slog.WarnContext(c.Request.Context(), "GitHub user fetch failed", "error", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to load GitHub profile"})
+		return
+	}
+	if githubUser.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "GitHub account has no verified primary email"})
+		return
+	}
+
+	user, err := linkGitHubUser(c.Request.Context(), h.store, githubUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in with GitHub"})
+		return
+	}
+
+	h.completeLogin(c, &user, "github")
+}
+
+// linkGitHubUser находит пользователя по GitHub ID, привязывает GitHub к аккаунту
+// с тем же email или создаёт нового пользователя
+func linkGitHubUser(ctx context.Context, st *store.Store, githubUser *services.GitHubUser) (models.User, error) {
+	var user models.User
+
+	err := st.Transaction(ctx, func(tx *store.Store) error {
+		var err error
+		user, err = tx.Users.GetByGitHubID(ctx, githubUser.ID)
+		if err == nil {
+			user.GitHubLogin = githubUser.Login
+			return tx.Users.Save(ctx, &user)
+		}
+		if !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+
+		now := time.Now()
+
+		user, err = tx.Users.GetByEmail(ctx, githubUser.Email)
+		if err == nil {
+			// GitHub подтвердил владение адресом. Если локальный email не был
+			// подтверждён, пароль мог задать кто угодно — сбрасываем его и
+			// завершаем все сессии и токены этого аккаунта
+			if user.EmailVerifiedAt == nil {
+				user.PasswordHash = ""
+				user.EmailVerifiedAt = &now
+				if _, err := tx.Sessions.RevokeUser(ctx, user.ID, now); err != nil {
+					return err
+				}
+				if _, err := tx.Tokens.RevokeUser(ctx, user.ID, now); err != nil {
+					return err
+				}
+			}
+			user.GitHubID = &githubUser.ID
+			user.GitHubLogin = githubUser.Login
+			return tx.Users.Save(ctx, &user)
+		}
+		if !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+
+		name := githubUser.Name
+		if name == "" {
+			name = githubUser.Login
+		}
+		user = models.User{
+			Email:           githubUser.Email,
+			Name:            name,
+			WebhookToken:    generateToken(),
+			EmailVerifiedAt: &now,
+			GitHubID:        &githubUser.ID,
+			GitHubLogin:     githubUser.Login,
+		}
+		return createUser(ctx, tx, &user)
+	})
+
+	return user, err
+}
+
+// isSecureRequest проверяет, что запрос пришёл по HTTPS (в том числе через прокси)
+func isSecureRequest(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
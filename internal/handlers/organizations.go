@@ -0,0 +1,471 @@
+package handlers
+
+import (
+	"commitcaster/internal/models"
+	"commitcaster/internal/store"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OrganizationRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type MemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required"`
+}
+
+type MemberRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type OrganizationResponse struct {
+	models.Organization
+	Role string `json:"role"`
+}
+
+type MemberResponse struct {
+	UserID    uint      `json:"user_id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListOrganizations возвращает организации, в которых состоит пользователь
+// @Summary Список организаций
+// @Description Возвращает организации текущего пользователя и его роль в каждой
+// @Tags organizations
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} OrganizationResponse
+// @Failure 401 {object} map[string]string
+// @Router /organizations [get]
+func (h *APIHandler) ListOrganizations(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	ctx := c.Request.Context()
+
+	memberships, err := h.store.Organizations.ListMemberships(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load organizations"})
+		return
+	}
+
+	response := make([]OrganizationResponse, 0, len(memberships))
+	for _, membership := range memberships {
+		org, err := h.store.Organizations.Get(ctx, membership.OrganizationID)
+		if err != nil {
+			continue
+		}
+		response = append(response, OrganizationResponse{Organization: org, Role: membership.Role})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateOrganization создаёт организацию, создатель становится владельцем
+// @Summary Создать организацию
+// @Description Создаёт организацию; текущий пользователь становится owner
+// @Tags organizations
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body OrganizationRequest true "Organization data"
+// @Success 201 {object} OrganizationResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /organizations [post]
+func (h *APIHandler) CreateOrganization(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	var req OrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	org := models.Organization{
+		Name:    req.Name,
+		Members: []models.Membership{{UserID: userID, Role: models.RoleOwner}},
+	}
+	if err := h.store.Organizations.Create(c.Request.Context(), &org); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create organization"})
+		return
+	}
+	org.Members = nil
+
+	c.JSON(http.StatusCreated, OrganizationResponse{Organization: org, Role: models.RoleOwner})
+}
+
+// GetOrganization возвращает организацию
+// @Summary Получить организацию
+// @Description Возвращает организацию (доступно любому участнику)
+// @Tags organizations
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Success 200 {object} OrganizationResponse
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /organizations/{id} [get]
+func (h *APIHandler) GetOrganization(c *gin.Context) {
+	org, membership, ok := h.findOrganization(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, OrganizationResponse{Organization: org, Role: membership.Role})
+}
+
+// UpdateOrganization переименовывает организацию
+// @Summary Обновить организацию
+// @Description Обновляет название организации (admin и выше)
+// @Tags organizations
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Param request body OrganizationRequest true "Organization data"
+// @Success 200 {object} OrganizationResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /organizations/{id} [put]
+func (h *APIHandler) UpdateOrganization(c *gin.Context) {
+	org, membership, ok := h.findOrganization(c, models.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	var req OrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	org.Name = req.Name
+	if err := h.store.Organizations.Save(c.Request.Context(), &org); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update organization"})
+		return
+	}
+
+	c.JSON(http.StatusOK, OrganizationResponse{Organization: org, Role: membership.Role})
+}
+
+// DeleteOrganization удаляет организацию вместе с её проектами
+// @Summary Удалить организацию
+// @Description Удаляет организацию, её проекты и участников (только owner)
+// @Tags organizations
+// @Security BearerAuth
+// @Param id path int true "Organization ID"
+// @Success 204
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /organizations/{id} [delete]
+func (h *APIHandler) DeleteOrganization(c *gin.Context) {
+	org, _, ok := h.findOrganization(c, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	if err := h.store.Organizations.Delete(c.Request.Context(), org.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete organization"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListMembers возвращает участников организации
+// @Summary Участники организации
+// @Description Возвращает участников организации и их роли
+// @Tags organizations
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Success 200 {array} MemberResponse
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /organizations/{id}/members [get]
+func (h *APIHandler) ListMembers(c *gin.Context) {
+	org, _, ok := h.findOrganization(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	members, err := loadMembers(c.Request.Context(), h.store.Organizations, org.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load members"})
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// AddMember добавляет пользователя в организацию
+// @Summary Добавить участника
+// @Description Добавляет зарегистрированного пользователя по email (admin и выше, роль owner может выдать только owner)
+// @Tags organizations
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Param request body MemberRequest true "Member data"
+// @Success 201 {object} MemberResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /organizations/{id}/members [post]
+func (h *APIHandler) AddMember(c *gin.Context) {
+	org, current, ok := h.findOrganization(c, models.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	var req MemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.ValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+	if req.Role == models.RoleOwner && current.Role != models.RoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owners can grant the owner role"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	user, err := h.store.Users.GetByEmail(ctx, req.Email)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if _, err := h.store.Organizations.GetMembership(ctx, org.ID, user.ID); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already a member"})
+		return
+	}
+
+	membership := models.Membership{OrganizationID: org.ID, UserID: user.ID, Role: req.Role}
+	if err := h.store.Organizations.CreateMembership(ctx, &membership); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add member"})
+		return
+	}
+	h.recordAudit(c, models.AuditMemberAdd, "organization", org.ID, gin.H{"user_id": user.ID, "role": membership.Role})
+
+	c.JSON(http.StatusCreated, MemberResponse{
+		UserID:    user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+		Role:      membership.Role,
+		CreatedAt: membership.CreatedAt,
+	})
+}
+
+// UpdateMember меняет роль участника
+// @Summary Изменить роль участника
+// @Description Меняет роль участника (admin и выше; роли owner меняет только owner)
+// @Tags organizations
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Param user_id path int true "User ID"
+// @Param request body MemberRoleRequest true "New role"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /organizations/{id}/members/{user_id} [put]
+func (h *APIHandler) UpdateMember(c *gin.Context) {
+	org, current, ok := h.findOrganization(c, models.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	var req MemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.ValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	target, ok := h.findMember(c, org.ID)
+	if !ok {
+		return
+	}
+
+	if (req.Role == models.RoleOwner || target.Role == models.RoleOwner) && current.Role != models.RoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owners can change the owner role"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	previous := target.Role
+	err := h.store.Transaction(ctx, func(tx *store.Store) error {
+		if target.Role == models.RoleOwner && req.Role != models.RoleOwner {
+			if err := ensureAnotherOwner(ctx, tx.Organizations, org.ID); err != nil {
+				return err
+			}
+		}
+		target.Role = req.Role
+		return tx.Organizations.SaveMembership(ctx, &target)
+	})
+	if errors.Is(err, errLastOwner) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Organization must have at least one owner"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+		return
+	}
+	if previous != target.Role {
+		h.recordAudit(c, models.AuditMemberRole, "organization", org.ID, gin.H{"user_id": target.UserID, "from": previous, "to": target.Role})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role updated"})
+}
+
+// RemoveMember удаляет участника из организации
+// @Summary Удалить участника
+// @Description Удаляет участника (admin и выше) или выход из организации (любой участник)
+// @Tags organizations
+// @Security BearerAuth
+// @Param id path int true "Organization ID"
+// @Param user_id path int true "User ID"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /organizations/{id}/members/{user_id} [delete]
+func (h *APIHandler) RemoveMember(c *gin.Context) {
+	org, current, ok := h.findOrganization(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	target, ok := h.findMember(c, org.ID)
+	if !ok {
+		return
+	}
+
+	self := target.UserID == current.UserID
+	if !self && !models.RoleAtLeast(current.Role, models.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient role"})
+		return
+	}
+	if !self && target.Role == models.RoleOwner && current.Role != models.RoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owners can remove owners"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	err := h.store.Transaction(ctx, func(tx *store.Store) error {
+		if target.Role == models.RoleOwner {
+			if err := ensureAnotherOwner(ctx, tx.Organizations, org.ID); err != nil {
+				return err
+			}
+		}
+		return tx.Organizations.DeleteMembership(ctx, target.ID)
+	})
+	if errors.Is(err, errLastOwner) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Organization must have at least one owner"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+	h.recordAudit(c, models.AuditMemberRemove, "organization", org.ID, gin.H{"user_id": target.UserID, "role": target.Role})
+
+	c.Status(http.StatusNoContent)
+}
+
+var errLastOwner = errors.New("organization must have at least one owner")
+
+// ensureAnotherOwner проверяет, что в организации останется хотя бы один owner
+func ensureAnotherOwner(ctx context.Context, orgs store.OrganizationStore, orgID uint) error {
+	owners, err := orgs.CountMembers(ctx, orgID, models.RoleOwner)
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return errLastOwner
+	}
+	return nil
+}
+
+// findOrganization загружает организацию по :id и проверяет роль текущего пользователя.
+// Не-участникам отвечает 404, участникам с недостаточной ролью — 403
+func (h *APIHandler) findOrganization(c *gin.Context, required string) (models.Organization, models.Membership, bool) {
+	userID := c.MustGet("user_id").(uint)
+	ctx := c.Request.Context()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization id"})
+		return models.Organization{}, models.Membership{}, false
+	}
+
+	org, err := h.store.Organizations.Get(ctx, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return org, models.Membership{}, false
+	}
+	membership, err := h.store.Organizations.GetMembership(ctx, org.ID, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return org, membership, false
+	}
+
+	if !models.RoleAtLeast(membership.Role, required) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient role"})
+		return org, membership, false
+	}
+
+	return org, membership, true
+}
+
+// findMember загружает участника организации по :user_id
+func (h *APIHandler) findMember(c *gin.Context, orgID uint) (models.Membership, bool) {
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+		return models.Membership{}, false
+	}
+
+	membership, err := h.store.Organizations.GetMembership(c.Request.Context(), orgID, uint(userID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return membership, false
+	}
+
+	return membership, true
+}
+
+// loadMembers возвращает участников организации с email и именем
+func loadMembers(ctx context.Context, orgs store.OrganizationStore, orgID uint) ([]MemberResponse, error) {
+	members, err := orgs.ListMembers(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]MemberResponse, 0, len(members))
+	for _, m := range members {
+		response = append(response, MemberResponse(m))
+	}
+	return response, nil
+}
@@ -0,0 +1,408 @@
+package handlers
+
+import (
+	"commitcaster/internal/auth"
+	"commitcaster/internal/models"
+	"commitcaster/internal/store"
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ProjectRequest struct {
+	Name           string               `json:"name"`
+	OrganizationID *uint                `json:"organization_id"`
+	GitHubSecret   string               `json:"github_secret"`
+	IsActive       *bool                `json:"is_active"`
+	PostLanguage   *string              `json:"post_language"`
+	MaxCommits     *int                 `json:"max_commits" binding:"omitempty,min=0"`
+	CustomPrompt   *string              `json:"custom_prompt"`
+	BranchFilter   *string              `json:"branch_filter"`
+	SkipPattern    *string              `json:"skip_pattern"`
+	Destinations   []DestinationRequest `json:"destinations" binding:"omitempty,dive"`
+	RoutingRules   []RoutingRuleRequest `json:"routing_rules"`
+}
+
+type DestinationRequest struct {
+	Name              string `json:"name"`
+	TelegramBotToken  string `json:"telegram_bot_token"`
+	TelegramChannelID string `json:"telegram_channel_id" binding:"required"`
+}
+
+type RoutingRuleRequest struct {
+	RepositoryPattern string `json:"repository_pattern"`
+	RefPattern        string `json:"ref_pattern"`
+	Destination       string `json:"destination"`
+	PostLanguage      string `json:"post_language"`
+	CustomPrompt      string `json:"custom_prompt"`
+}
+
+type ProjectResponse struct {
+	models.Project
+	WebhookURL string `json:"webhook_url"`
+
+	PreviousWebhookToken string     `json:"previous_webhook_token,omitempty"`
+	PreviousWebhookURL   string     `json:"previous_webhook_url,omitempty"`
+	PreviousExpiresAt    *time.Time `json:"previous_expires_at,omitempty"`
+}
+
+// newProjectResponse формирует ответ с замаскированными секретом webhook и токенами ботов
+func newProjectResponse(project models.Project) ProjectResponse {
+	project.GitHubSecret = auth.MaskSecret(project.GitHubSecret)
+	destinations := make([]models.Destination, len(project.Destinations))
+	for i, d := range project.Destinations {
+		d.TelegramBotToken = auth.MaskSecret(d.TelegramBotToken)
+		destinations[i] = d
+	}
+	project.Destinations = destinations
+	info := newWebhookInfo(project.WebhookToken, project.PreviousWebhookToken, project.PreviousWebhookTokenExpiresAt)
+	return ProjectResponse{
+		Project:              project,
+		WebhookURL:           info.WebhookURL,
+		PreviousWebhookToken: info.PreviousWebhookToken,
+		PreviousWebhookURL:   info.PreviousWebhookURL,
+		PreviousExpiresAt:    info.PreviousExpiresAt,
+	}
+}
+
+// ListProjects возвращает проекты текущего пользователя
+// @Summary Список проектов
+// @Description Возвращает личные проекты пользователя и проекты его организаций
+// @Tags projects
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} ProjectResponse
+// @Failure 401 {object} map[string]string
+// @Router /projects [get]
+func (h *APIHandler) ListProjects(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	projects, err := h.store.Projects.ListForUser(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load projects"})
+		return
+	}
+
+	response := make([]ProjectResponse, 0, len(projects))
+	for _, project := range projects {
+		response = append(response, newProjectResponse(project))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateProject создаёт новый проект со своим webhook
+// @Summary Создать проект
+// @Description Создаёт личный проект или проект организации (editor и выше) с отдельным webhook токеном, каналами и промптом. Если github_secret не указан, он генерируется и показывается только в этом ответе
+// @Tags projects
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body ProjectRequest true "Project data"
+// @Success 201 {object} ProjectResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /projects [post]
+func (h *APIHandler) CreateProject(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	var req ProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Project name is required"})
+		return
+	}
+
+	if req.OrganizationID != nil {
+		role, err := organizationRole(c.Request.Context(), h.store.Organizations, userID, *req.OrganizationID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+			return
+		}
+		if !models.RoleAtLeast(role, models.RoleEditor) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient role"})
+			return
+		}
+	}
+
+	project := models.Project{
+		UserID:         userID,
+		OrganizationID: req.OrganizationID,
+		WebhookToken:   generateToken(),
+		GitHubSecret:   auth.GenerateWebhookSecret(),
+		IsActive:       true,
+	}
+	applyProjectRequest(&project, req)
+	if err := validateRoutingRules(project); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.store.Projects.Create(c.Request.Context(), &project); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create project"})
+		return
+	}
+	h.recordAudit(c, models.AuditProjectCreate, "project", project.ID, gin.H{"name": project.Name, "organization_id": project.OrganizationID})
+
+	// Секрет webhook показывается полностью только при создании
+	response := newProjectResponse(project)
+	response.GitHubSecret = project.GitHubSecret
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetProject возвращает проект по ID
+// @Summary Получить проект
+// @Description Возвращает проект (для проектов организации — viewer и выше)
+// @Tags projects
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Project ID"
+// @Success 200 {object} ProjectResponse
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /projects/{id} [get]
+func (h *APIHandler) GetProject(c *gin.Context) {
+	project, ok := h.findProject(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, newProjectResponse(project))
+}
+
+// UpdateProject обновляет проект
+// @Summary Обновить проект
+// @Description Обновляет проект (editor и выше; все поля опциональные, destinations и routing_rules заменяются целиком; замаскированный telegram_bot_token канала сохраняет прежний токен; пустые post_language и custom_prompt и max_commits = 0 возвращают значения из настроек)
+// @Tags projects
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param request body ProjectRequest true "Project fields to update"
+// @Success 200 {object} ProjectResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /projects/{id} [put]
+func (h *APIHandler) UpdateProject(c *gin.Context) {
+	project, ok := h.findProject(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	var req ProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before := auditSnapshot(project)
+	// Каналы и правила из запроса заменяют прежние: у новых записей нет id
+	applyProjectRequest(&project, req)
+	// Правила проверяются вместе с каналами: переименование канала не должно
+	// оставить правило, которое ссылается на несуществующий канал
+	if err := validateRoutingRules(project); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.store.Projects.Save(c.Request.Context(), &project); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project"})
+		return
+	}
+	if changes := auditChanges(before, project); len(changes) > 0 {
+		h.recordAudit(c, models.AuditProjectUpdate, "project", project.ID, gin.H{"changes": changes})
+	}
+
+	c.JSON(http.StatusOK, newProjectResponse(project))
+}
+
+// DeleteProject удаляет проект
+// @Summary Удалить проект
+// @Description Удаляет проект и его webhook (для проектов организации — admin и выше)
+// @Tags projects
+// @Security BearerAuth
+// @Param id path int true "Project ID"
+// @Success 204
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /projects/{id} [delete]
+func (h *APIHandler) DeleteProject(c *gin.Context) {
+	project, ok := h.findProject(c, models.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	if err := h.store.Projects.Delete(c.Request.Context(), project.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete project"})
+		return
+	}
+	h.recordAudit(c, models.AuditProjectDelete, "project", project.ID, gin.H{"name": project.Name, "organization_id": project.OrganizationID})
+
+	c.Status(http.StatusNoContent)
+}
+
+// validateRoutingRules проверяет glob-шаблоны правил маршрутизации проекта
+// и что каждое правило ссылается на существующий канал
+func validateRoutingRules(project models.Project) error {
+	destinations := make(map[string]bool, len(project.Destinations))
+	for _, d := range project.Destinations {
+		if d.Name != "" {
+			destinations[d.Name] = true
+		}
+	}
+
+	for i, rule := range project.RoutingRules {
+		for _, pattern := range []string{rule.RepositoryPattern, rule.RefPattern} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("routing rule %d: invalid pattern %q", i+1, pattern)
+			}
+		}
+		if rule.Destination != "" && !destinations[rule.Destination] {
+			return fmt.Errorf("routing rule %d: unknown destination %q", i+1, rule.Destination)
+		}
+	}
+	return nil
+}
+
+// findProject загружает проект по :id и проверяет роль текущего пользователя.
+// Проекты, к которым нет доступа, отвечают 404, недостаточная роль — 403.
+// При ошибке пишет ответ и возвращает false
+func (h *APIHandler) findProject(c *gin.Context, required string) (models.Project, bool) {
+	userID := c.MustGet("user_id").(uint)
+	ctx := c.Request.Context()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project id"})
+		return models.Project{}, false
+	}
+
+	project, err := h.store.Projects.Get(ctx, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return project, false
+	}
+
+	role, err := projectRole(ctx, h.store.Organizations, userID, &project)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return project, false
+	}
+	if !models.RoleAtLeast(role, required) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient role"})
+		return project, false
+	}
+
+	return project, true
+}
+
+// projectRole возвращает роль пользователя в проекте: владелец личного проекта
+// имеет все права, для проекта организации берётся роль участника
+func projectRole(ctx context.Context, orgs store.OrganizationStore, userID uint, project *models.Project) (string, error) {
+	if project.OrganizationID == nil {
+		if project.UserID != userID {
+			return "", store.ErrNotFound
+		}
+		return models.RoleOwner, nil
+	}
+	return organizationRole(ctx, orgs, userID, *project.OrganizationID)
+}
+
+// organizationRole возвращает роль пользователя в организации
+func organizationRole(ctx context.Context, orgs store.OrganizationStore, userID, orgID uint) (string, error) {
+	membership, err := orgs.GetMembership(ctx, orgID, userID)
+	if err != nil {
+		return "", err
+	}
+	return membership.Role, nil
+}
+
+// applyProjectRequest переносит заполненные поля запроса в проект
+func applyProjectRequest(project *models.Project, req ProjectRequest) {
+	if req.Name != "" {
+		project.Name = req.Name
+	}
+	if req.GitHubSecret != "" && !auth.IsMaskedSecret(req.GitHubSecret) {
+		project.GitHubSecret = req.GitHubSecret
+	}
+	if req.IsActive != nil {
+		project.IsActive = *req.IsActive
+	}
+	// Пустые post_language и custom_prompt и max_commits = 0 снимают переопределение:
+	// проект снова берёт значение из настроек пользователя
+	if req.PostLanguage != nil {
+		project.PostLanguage = *req.PostLanguage
+	}
+	if req.MaxCommits != nil {
+		project.MaxCommits = *req.MaxCommits
+	}
+	if req.CustomPrompt != nil {
+		project.CustomPrompt = *req.CustomPrompt
+	}
+	if req.BranchFilter != nil {
+		project.BranchFilter = *req.BranchFilter
+	}
+	if req.SkipPattern != nil {
+		project.SkipPattern = *req.SkipPattern
+	}
+	if req.Destinations != nil {
+		previous := project.Destinations
+		project.Destinations = make([]models.Destination, 0, len(req.Destinations))
+		for _, d := range req.Destinations {
+			token := d.TelegramBotToken
+			if auth.IsMaskedSecret(token) {
+				token = storedBotToken(previous, d.Name, token)
+			}
+			project.Destinations = append(project.Destinations, models.Destination{
+				Name:              d.Name,
+				TelegramBotToken:  token,
+				TelegramChannelID: d.TelegramChannelID,
+			})
+		}
+	}
+	if req.RoutingRules != nil {
+		project.RoutingRules = make([]models.RoutingRule, 0, len(req.RoutingRules))
+		for i, r := range req.RoutingRules {
+			project.RoutingRules = append(project.RoutingRules, models.RoutingRule{
+				Position:          i,
+				RepositoryPattern: r.RepositoryPattern,
+				RefPattern:        r.RefPattern,
+				Destination:       r.Destination,
+				PostLanguage:      r.PostLanguage,
+				CustomPrompt:      r.CustomPrompt,
+			})
+		}
+	}
+}
+
+// storedBotToken возвращает сохранённый токен бота, который клиент прислал
+// замаскированным. Сначала ищется канал с тем же именем; если такого токена нет,
+// канал использует токен бота из настроек
+func storedBotToken(destinations []models.Destination, name, masked string) string {
+	token := ""
+	for _, d := range destinations {
+		if d.TelegramBotToken == "" || auth.MaskSecret(d.TelegramBotToken) != masked {
+			continue
+		}
+		if d.Name == name {
+			return d.TelegramBotToken
+		}
+		if token == "" {
+			token = d.TelegramBotToken
+		}
+	}
+	return token
+}
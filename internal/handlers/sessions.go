@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"commitcaster/internal/auth"
+	"commitcaster/internal/models"
+	"commitcaster/internal/store"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+var (
+	errInvalidRefreshToken = errors.New("invalid refresh token")
+	errRefreshTokenReused  = errors.New("refresh token reused")
+)
+
+// RefreshToken выдаёт новую пару токенов по refresh токену
+// @Summary Обновить токены
+// @Description Обменивает refresh токен на новую пару access/refresh токенов. Refresh токен одноразовый: повторное использование отзывает всю сессию
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body RefreshRequest true "Refresh token"
+// @Success 200 {object} AuthResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /auth/refresh [post]
+func (h *APIHandler) RefreshToken(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	now := time.Now()
+
+	var session models.Session
+	var refreshToken string
+
+	err := h.store.Transaction(ctx, func(tx *store.Store) error {
+		current, err := tx.Sessions.GetRefreshToken(ctx, auth.HashToken(req.RefreshToken))
+		if err != nil {
+			return errInvalidRefreshToken
+		}
+		if session, err = tx.Sessions.Get(ctx, current.SessionID); err != nil {
+			return errInvalidRefreshToken
+		}
+		if !session.IsActive() || now.After(current.ExpiresAt) {
+			return errInvalidRefreshToken
+		}
+
+		// Помечаем токен использованным; если он уже был использован —
+		// токен утёк, отзываем всю сессию
+		used, err := tx.Sessions.UseRefreshToken(ctx, current.ID, now)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return errRefreshTokenReused
+		}
+
+		refreshToken, err = createRefreshToken(ctx, tx, &session)
+		return err
+	})
+	if errors.Is(err, errRefreshTokenReused) {
+		h.store.Sessions.Revoke(ctx, session.ID, time.Now())
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+	if errors.Is(err, errInvalidRefreshToken) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	user, err := h.store.Users.Get(ctx, session.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	token, err := auth.GenerateToken(user.ID, user.Email, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newAuthResponse(&user, token, refreshToken))
+}
+
+// Logout завершает текущую сессию
+// @Summary Выйти
+// @Description Отзывает текущую сессию: её access и refresh токены перестают работать
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /auth/logout [post]
+func (h *APIHandler) Logout(c *gin.Context) {
+	sessionID := c.MustGet("session_id").(uint)
+
+	if err := h.store.Sessions.Revoke(c.Request.Context(), sessionID, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+	h.recordAudit(c, models.AuditLogout, "user", c.MustGet("user_id").(uint), gin.H{"session_id": sessionID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// LogoutAll завершает все сессии пользователя
+// @Summary Выйти со всех устройств
+// @Description Отзывает все сессии текущего пользователя, включая текущую
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /auth/logout-all [post]
+func (h *APIHandler) LogoutAll(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	if _, err := h.store.Sessions.RevokeUser(c.Request.Context(), userID, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+	h.recordAudit(c, models.AuditLogoutAll, "user", userID, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "All sessions logged out"})
+}
+
+// issueTokens создаёт новую сессию и выдаёт пару access/refresh токенов
+func (h *APIHandler) issueTokens(c *gin.Context, user *models.User) (AuthResponse, error) {
+	ctx := c.Request.Context()
+	var response AuthResponse
+
+	err := h.store.Transaction(ctx, func(tx *store.Store) error {
+		session := models.Session{
+			UserID:    user.ID,
+			UserAgent: c.Request.UserAgent(),
+			IP:        c.ClientIP(),
+		}
+		if err := tx.Sessions.Create(ctx, &session); err != nil {
+			return err
+		}
+
+		refreshToken, err := createRefreshToken(ctx, tx, &session)
+		if err != nil {
+			return err
+		}
+
+		token, err := auth.GenerateToken(user.ID, user.Email, session.ID)
+		if err != nil {
+			return err
+		}
+
+		response = newAuthResponse(user, token, refreshToken)
+		return nil
+	})
+
+	return response, err
+}
+
+// createRefreshToken выдаёт новый refresh токен сессии и продлевает её
+func createRefreshToken(ctx context.Context, tx *store.Store, session *models.Session) (string, error) {
+	token := auth.RandomToken(32)
+	expiresAt := time.Now().Add(auth.RefreshTokenTTL())
+
+	session.ExpiresAt = expiresAt
+	if err := tx.Sessions.Save(ctx, session); err != nil {
+		return "", err
+	}
+
+	refreshToken := models.RefreshToken{
+		SessionID: session.ID,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: expiresAt,
+	}
+	if err := tx.Sessions.CreateRefreshToken(ctx, &refreshToken); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func newAuthResponse(user *models.User, token, refreshToken string) AuthResponse {
+	return AuthResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(auth.AccessTokenTTL().Seconds()),
+		WebhookToken: user.WebhookToken,
+		WebhookURL:   webhookURL(user.WebhookToken),
+	}
+}
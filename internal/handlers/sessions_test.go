@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRefreshTokenRotation(t *testing.T) {
+	s := newTestServer(t)
+	registered := s.register("alice@example.com", "password123")
+
+	w := s.do(http.MethodPost, "/api/auth/refresh", "", RefreshRequest{RefreshToken: registered.RefreshToken})
+	expectStatus(t, w, http.StatusOK)
+	rotated := decode[AuthResponse](t, w)
+
+	if rotated.RefreshToken == registered.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+	expectStatus(t, s.do(http.MethodGet, "/api/settings", rotated.Token, nil), http.StatusOK)
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	s := newTestServer(t)
+	registered := s.register("alice@example.com", "password123")
+
+	w := s.do(http.MethodPost, "/api/auth/refresh", "", RefreshRequest{RefreshToken: registered.RefreshToken})
+	expectStatus(t, w, http.StatusOK)
+	rotated := decode[AuthResponse](t, w)
+
+	// Повторное использование старого refresh токена означает утечку
+	w = s.do(http.MethodPost, "/api/auth/refresh", "", RefreshRequest{RefreshToken: registered.RefreshToken})
+	expectStatus(t, w, http.StatusUnauthorized)
+
+	// Отозвана вся сессия: и новый refresh токен, и выданные access токены
+	w = s.do(http.MethodPost, "/api/auth/refresh", "", RefreshRequest{RefreshToken: rotated.RefreshToken})
+	expectStatus(t, w, http.StatusUnauthorized)
+	expectStatus(t, s.do(http.MethodGet, "/api/settings", rotated.Token, nil), http.StatusUnauthorized)
+	expectStatus(t, s.do(http.MethodGet, "/api/settings", registered.Token, nil), http.StatusUnauthorized)
+}
+
+func TestRefreshTokenReuseKeepsOtherSessions(t *testing.T) {
+	s := newTestServer(t)
+	registered := s.register("alice@example.com", "password123")
+
+	w := s.do(http.MethodPost, "/api/auth/login", "", LoginRequest{Email: "alice@example.com", Password: "password123"})
+	expectStatus(t, w, http.StatusOK)
+	other := decode[AuthResponse](t, w)
+
+	expectStatus(t, s.do(http.MethodPost, "/api/auth/refresh", "", RefreshRequest{RefreshToken: registered.RefreshToken}), http.StatusOK)
+	expectStatus(t, s.do(http.MethodPost, "/api/auth/refresh", "", RefreshRequest{RefreshToken: registered.RefreshToken}), http.StatusUnauthorized)
+
+	expectStatus(t, s.do(http.MethodGet, "/api/settings", other.Token, nil), http.StatusOK)
+	expectStatus(t, s.do(http.MethodPost, "/api/auth/refresh", "", RefreshRequest{RefreshToken: other.RefreshToken}), http.StatusOK)
+}
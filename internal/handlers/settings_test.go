@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"commitcaster/internal/auth"
+	"net/http"
+	"testing"
+)
+
+func TestSettingsMaskSecrets(t *testing.T) {
+	s := newTestServer(t)
+	_, secret := readyWebhookUser(s, "alice@example.com")
+	session := s.do(http.MethodPost, "/api/auth/login", "", LoginRequest{Email: "alice@example.com", Password: "password123"})
+	expectStatus(t, session, http.StatusOK)
+	access := decode[AuthResponse](t, session).Token
+
+	w := s.do(http.MethodGet, "/api/settings", access, nil)
+	expectStatus(t, w, http.StatusOK)
+	got := decode[SettingsResponse](t, w)
+	for name, value := range map[string]string{
+		"telegram_bot_token": got.TelegramBotToken,
+		"groq_api_key":       got.GroqAPIKey,
+		"github_secret":      got.GitHubSecret,
+	} {
+		if !auth.IsMaskedSecret(value) {
+			t.Errorf("%s = %q, want masked", name, value)
+		}
+	}
+
+	// Клиент отправляет обратно настройки из GET: замаскированные значения не сохраняются
+	w = s.do(http.MethodPut, "/api/settings", access, SettingsRequest{
+		TelegramBotToken:  got.TelegramBotToken,
+		TelegramChannelID: "@other",
+		GroqAPIKey:        got.GroqAPIKey,
+		GitHubSecret:      got.GitHubSecret,
+	})
+	expectStatus(t, w, http.StatusOK)
+
+	settings, err := s.store.Settings.GetByUser(t.Context(), s.user("alice@example.com").ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.TelegramBotToken != "123:bot-token" || settings.GroqAPIKey != "ai-key" || settings.GitHubSecret != secret {
+		t.Errorf("stored secrets overwritten by masked values: %+v", settings)
+	}
+	if settings.TelegramChannelID != "@other" {
+		t.Errorf("channel = %q, want @other", settings.TelegramChannelID)
+	}
+
+	// Новое значение сохраняется
+	w = s.do(http.MethodPut, "/api/settings", access, SettingsRequest{TelegramBotToken: "456:new-token"})
+	expectStatus(t, w, http.StatusOK)
+	if settings, _ = s.store.Settings.GetByUser(t.Context(), s.user("alice@example.com").ID); settings.TelegramBotToken != "456:new-token" {
+		t.Errorf("bot token = %q, want the new token", settings.TelegramBotToken)
+	}
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"commitcaster/internal/auth"
+	"commitcaster/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateTokenRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"min=0"`
+}
+
+type CreateTokenResponse struct {
+	models.PersonalAccessToken
+	Token string `json:"token"`
+}
+
+// ListTokens возвращает personal access токены пользователя
+// @Summary Список personal access токенов
+// @Description Возвращает токены пользователя (без самих значений токенов)
+// @Tags tokens
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.PersonalAccessToken
+// @Failure 401 {object} map[string]string
+// @Router /tokens [get]
+func (h *APIHandler) ListTokens(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	tokens, err := h.store.Tokens.ListByUser(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// CreateToken создаёт personal access токен
+// @Summary Создать personal access токен
+// @Description Создаёт токен с указанными областями доступа. Значение токена возвращается только один раз
+// @Tags tokens
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body CreateTokenRequest true "Token data"
+// @Success 201 {object} CreateTokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /tokens [post]
+func (h *APIHandler) CreateToken(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	var req CreateTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !models.ValidScope(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope: " + scope})
+			return
+		}
+	}
+
+	token := models.PersonalTokenPrefix + auth.RandomToken(20)
+	pat := models.PersonalAccessToken{
+		UserID:    userID,
+		Name:      req.Name,
+		TokenHash: auth.HashToken(token),
+		Prefix:    token[:len(models.PersonalTokenPrefix)+8],
+		Scopes:    strings.Join(req.Scopes, ","),
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		pat.ExpiresAt = &expiresAt
+	}
+
+	if err := h.store.Tokens.Create(c.Request.Context(), &pat); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		return
+	}
+	h.recordAudit(c, models.AuditTokenCreate, "token", pat.ID, gin.H{"name": pat.Name, "prefix": pat.Prefix, "scopes": pat.Scopes})
+
+	c.JSON(http.StatusCreated, CreateTokenResponse{PersonalAccessToken: pat, Token: token})
+}
+
+// RevokeToken отзывает personal access токен
+// @Summary Отозвать personal access токен
+// @Description Отзывает токен; запросы с ним перестают проходить аутентификацию
+// @Tags tokens
+// @Security BearerAuth
+// @Param id path int true "Token ID"
+// @Success 204
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /tokens/{id} [delete]
+func (h *APIHandler) RevokeToken(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	ctx := c.Request.Context()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token id"})
+		return
+	}
+
+	pat, err := h.store.Tokens.Get(ctx, uint(id))
+	if err != nil || pat.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		return
+	}
+
+	if pat.RevokedAt == nil {
+		now := time.Now()
+		pat.RevokedAt = &now
+		if err := h.store.Tokens.Revoke(ctx, pat.ID, now); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
+			return
+		}
+		h.recordAudit(c, models.AuditTokenRevoke, "token", pat.ID, gin.H{"name": pat.Name, "prefix": pat.Prefix})
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"commitcaster/internal/models"
+	"net/http"
+	"testing"
+)
+
+// createToken создаёт personal access токен с областями доступа scopes
+func createToken(s *testServer, session string, scopes ...string) string {
+	s.t.Helper()
+
+	w := s.do(http.MethodPost, "/api/tokens", session, CreateTokenRequest{Name: "ci", Scopes: scopes})
+	expectStatus(s.t, w, http.StatusCreated)
+	return decode[CreateTokenResponse](s.t, w).Token
+}
+
+func TestPersonalAccessTokenScopes(t *testing.T) {
+	s := newTestServer(t)
+	registered := s.register("alice@example.com", "password123")
+	token := createToken(s, registered.Token, models.ScopeSettingsRead)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   any
+		status int
+	}{
+		{"granted scope", http.MethodGet, "/api/settings", nil, http.StatusOK},
+		{"write without write scope", http.MethodPut, "/api/settings", SettingsRequest{}, http.StatusForbidden},
+		{"other resource", http.MethodGet, "/api/projects", nil, http.StatusForbidden},
+		{"session-only route", http.MethodGet, "/api/tokens", nil, http.StatusForbidden},
+		{"token cannot create tokens", http.MethodPost, "/api/tokens", CreateTokenRequest{Name: "x", Scopes: []string{models.ScopeSettingsWrite}}, http.StatusForbidden},
+		{"token cannot delete account", http.MethodDelete, "/api/account", DeleteAccountRequest{Password: "password123"}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectStatus(t, s.do(tt.method, tt.path, token, tt.body), tt.status)
+		})
+	}
+}
+
+func TestPersonalAccessTokenSessionHasAllScopes(t *testing.T) {
+	s := newTestServer(t)
+	registered := s.register("alice@example.com", "password123")
+
+	// Области доступа ограничивают только personal access токены
+	expectStatus(t, s.do(http.MethodGet, "/api/projects", registered.Token, nil), http.StatusOK)
+	expectStatus(t, s.do(http.MethodGet, "/api/tokens", registered.Token, nil), http.StatusOK)
+}
+
+func TestCreateTokenRejectsUnknownScope(t *testing.T) {
+	s := newTestServer(t)
+	registered := s.register("alice@example.com", "password123")
+
+	w := s.do(http.MethodPost, "/api/tokens", registered.Token, CreateTokenRequest{Name: "ci", Scopes: []string{"admin"}})
+	expectStatus(t, w, http.StatusBadRequest)
+}
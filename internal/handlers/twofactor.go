@@ -0,0 +1,442 @@
+package handlers
+
+import (
+	"commitcaster/internal/auth"
+	"commitcaster/internal/models"
+	"commitcaster/internal/store"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	twoFactorPurpose  = "2fa_challenge"
+	twoFactorTTL      = 5 * time.Minute
+	recoveryCodeCount = 10
+	totpIssuer        = "CommitCaster"
+)
+
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int    `json:"expires_in"`
+}
+
+type TwoFactorVerifyRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type TwoFactorEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+var errInvalidSecondFactor = errors.New("invalid two-factor code")
+
+// VerifyTwoFactor завершает вход вторым фактором
+// @Summary Второй шаг входа (2FA)
+// @Description Проверяет TOTP код или код восстановления по challenge токену из login и возвращает access и refresh токены
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorVerifyRequest true "Challenge token and code"
+// @Success 200 {object} AuthResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]interface{}
+// @Router /auth/2fa/verify [post]
+func (h *APIHandler) VerifyTwoFactor(c *gin.Context) {
+	var req TwoFactorVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claims, err := auth.ValidatePurposeToken(req.ChallengeToken, twoFactorPurpose)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	user, err := h.store.Users.Get(ctx, claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired challenge"})
+		return
+	}
+
+	// Неверные коды считаются вместе с неверными паролями
+	if rejectLockedUser(c, &user) {
+		return
+	}
+	if user.IsDeactivated() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account deactivated"})
+		return
+	}
+
+	err = h.store.Transaction(ctx, func(tx *store.Store) error {
+		return verifySecondFactor(ctx, tx, &user, req.Code, req.RecoveryCode)
+	})
+	if errors.Is(err, errInvalidSecondFactor) {
+		h.recordLoginFailure(c, &user, "invalid_2fa_code")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	h.resetLoginFailures(ctx, &user)
+
+	response, err := h.issueTokens(c, &user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	h.recordAuditAs(c, user.ID, models.AuditLogin, "user", user.ID, gin.H{"method": "2fa", "recovery_code": req.RecoveryCode != ""})
+
+	c.JSON(http.StatusOK, response)
+}
+
+// EnrollTwoFactor создаёт TOTP секрет для подключения 2FA
+// @Summary Подключить 2FA
+// @Description Создаёт TOTP секрет и otpauth URI для приложения-аутентификатора. 2FA включается после подтверждения кодом
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} TwoFactorEnrollResponse
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /auth/2fa/enroll [post]
+func (h *APIHandler) EnrollTwoFactor(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	ctx := c.Request.Context()
+
+	user, err := h.store.Users.Get(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication already enabled"})
+		return
+	}
+
+	user.TOTPSecret = auth.GenerateTOTPSecret()
+	user.TOTPLastStep = 0
+	if err := h.store.Users.Save(ctx, &user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enroll"})
+		return
+	}
+
+	c.JSON(http.StatusOK, TwoFactorEnrollResponse{
+		Secret:     user.TOTPSecret,
+		OTPAuthURI: auth.TOTPURI(totpIssuer, user.Email, user.TOTPSecret),
+	})
+}
+
+// ConfirmTwoFactor включает 2FA после проверки кода
+// @Summary Подтвердить 2FA
+// @Description Проверяет код из приложения, включает 2FA и возвращает коды восстановления (показываются один раз)
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorCodeRequest true "TOTP code"
+// @Success 200 {object} RecoveryCodesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /auth/2fa/confirm [post]
+func (h *APIHandler) ConfirmTwoFactor(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	var codes []string
+	err := h.store.Transaction(ctx, func(tx *store.Store) error {
+		user, err := tx.Users.Get(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if user.TOTPEnabled || user.TOTPSecret == "" {
+			return errInvalidSecondFactor
+		}
+
+		step, ok := auth.ValidateTOTP(user.TOTPSecret, req.Code, user.TOTPLastStep, time.Now())
+		if !ok {
+			return errInvalidSecondFactor
+		}
+		user.TOTPEnabled = true
+		user.TOTPLastStep = step
+		if err := tx.Users.Save(ctx, &user); err != nil {
+			return err
+		}
+
+		codes, err = replaceRecoveryCodes(ctx, tx, user.ID)
+		return err
+	})
+	if errors.Is(err, errInvalidSecondFactor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code or two-factor enrollment not started"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+	h.recordAudit(c, models.AuditTwoFactorEnable, "user", userID, nil)
+
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTwoFactor отключает 2FA
+// @Summary Отключить 2FA
+// @Description Отключает 2FA после проверки TOTP кода или кода восстановления
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorCodeRequest true "TOTP or recovery code"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]interface{}
+// @Router /auth/2fa/disable [post]
+func (h *APIHandler) DisableTwoFactor(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	user, ok := h.loadSecondFactorUser(c, userID)
+	if !ok {
+		return
+	}
+
+	err := h.store.Transaction(ctx, func(tx *store.Store) error {
+		if err := verifySecondFactor(ctx, tx, &user, req.Code, req.RecoveryCode); err != nil {
+			return err
+		}
+
+		user.TOTPEnabled = false
+		user.TOTPSecret = ""
+		user.TOTPLastStep = 0
+		if err := tx.Users.Save(ctx, &user); err != nil {
+			return err
+		}
+		return tx.RecoveryCodes.DeleteByUser(ctx, user.ID)
+	})
+	if errors.Is(err, errInvalidSecondFactor) {
+		h.recordLoginFailure(c, &user, "invalid_2fa_code")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+	h.resetLoginFailures(ctx, &user)
+	h.recordAudit(c, models.AuditTwoFactorOff, "user", userID, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes выдаёт новые коды восстановления
+// @Summary Новые коды восстановления
+// @Description Заменяет коды восстановления новыми после проверки TOTP кода
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorCodeRequest true "TOTP code"
+// @Success 200 {object} RecoveryCodesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]interface{}
+// @Router /auth/2fa/recovery-codes [post]
+func (h *APIHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	user, ok := h.loadSecondFactorUser(c, userID)
+	if !ok {
+		return
+	}
+
+	var codes []string
+	err := h.store.Transaction(ctx, func(tx *store.Store) error {
+		if err := verifySecondFactor(ctx, tx, &user, req.Code, ""); err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(ctx, tx, user.ID)
+		return err
+	})
+	if errors.Is(err, errInvalidSecondFactor) {
+		h.recordLoginFailure(c, &user, "invalid_2fa_code")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate recovery codes"})
+		return
+	}
+	h.resetLoginFailures(ctx, &user)
+	h.recordAudit(c, models.AuditRecoveryCodes, "user", userID, nil)
+
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// loadSecondFactorUser загружает пользователя для операции, подтверждаемой кодом 2FA.
+// Неверные коды считаются вместе с неверными паролями, поэтому при блокировке входа
+// код не проверяется. false — ответ уже отправлен
+func (h *APIHandler) loadSecondFactorUser(c *gin.Context, userID uint) (models.User, bool) {
+	user, err := h.store.Users.Get(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return user, false
+	}
+	if rejectLockedUser(c, &user) {
+		return user, false
+	}
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return user, false
+	}
+	return user, true
+}
+
+// completeLogin выдаёт токены после проверки пароля (или OAuth).
+// Если у пользователя включена 2FA, вместо токенов возвращается challenge.
+// method (password, github) записывается в журнал аудита
+func (h *APIHandler) completeLogin(c *gin.Context, user *models.User, method string) {
+	if user.IsDeactivated() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account deactivated"})
+		return
+	}
+
+	if user.TOTPEnabled {
+		challenge, err := auth.GeneratePurposeToken(twoFactorPurpose, user.ID, "", twoFactorTTL)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+
+		c.JSON(http.StatusOK, TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+			ExpiresIn:         int(twoFactorTTL.Seconds()),
+		})
+		return
+	}
+
+	response, err := h.issueTokens(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	h.recordAuditAs(c, user.ID, models.AuditLogin, "user", user.ID, gin.H{"method": method})
+
+	c.JSON(http.StatusOK, response)
+}
+
+// verifySecondFactor проверяет TOTP код или одноразовый код восстановления
+func verifySecondFactor(ctx context.Context, tx *store.Store, user *models.User, code, recoveryCode string) error {
+	if !user.TOTPEnabled {
+		return errInvalidSecondFactor
+	}
+
+	if code != "" {
+		step, ok := auth.ValidateTOTP(user.TOTPSecret, code, user.TOTPLastStep, time.Now())
+		if !ok {
+			return errInvalidSecondFactor
+		}
+		// Шаг сравнивается и записывается одним запросом: из двух параллельных
+		// входов с одним кодом пройдёт только один
+		advanced, err := tx.Users.AdvanceTOTPStep(ctx, user.ID, step)
+		if err != nil {
+			return err
+		}
+		if !advanced {
+			return errInvalidSecondFactor
+		}
+		user.TOTPLastStep = step
+		return nil
+	}
+
+	if recoveryCode != "" {
+		used, err := tx.RecoveryCodes.Use(ctx, user.ID, auth.HashToken(normalizeRecoveryCode(recoveryCode)), time.Now())
+		if err != nil {
+			return err
+		}
+		if !used {
+			return errInvalidSecondFactor
+		}
+		return nil
+	}
+
+	return errInvalidSecondFactor
+}
+
+// replaceRecoveryCodes удаляет старые коды восстановления и создаёт новые
+func replaceRecoveryCodes(ctx context.Context, tx *store.Store, userID uint) ([]string, error) {
+	if err := tx.RecoveryCodes.DeleteByUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := auth.RandomToken(5)
+		code := raw[:5] + "-" + raw[5:]
+		codes = append(codes, code)
+
+		recoveryCode := models.RecoveryCode{UserID: userID, CodeHash: auth.HashToken(normalizeRecoveryCode(code))}
+		if err := tx.RecoveryCodes.Create(ctx, &recoveryCode); err != nil {
+			return nil, err
+		}
+	}
+
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
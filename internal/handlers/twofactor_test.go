@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// totpAt вычисляет TOTP код секрета для интервала step (RFC 6238)
+func totpAt(t *testing.T, secret string, step int64) string {
+	t.Helper()
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatalf("decode TOTP secret: %v", err)
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+// enableTwoFactor включает 2FA кодом предыдущего интервала и возвращает секрет
+// и коды восстановления; коды текущего интервала остаются неиспользованными
+func enableTwoFactor(s *testServer, token string) (string, []string) {
+	s.t.Helper()
+
+	w := s.do(http.MethodPost, "/api/auth/2fa/enroll", token, nil)
+	expectStatus(s.t, w, http.StatusOK)
+	secret := decode[TwoFactorEnrollResponse](s.t, w).Secret
+
+	step := time.Now().Unix()/30 - 1
+	w = s.do(http.MethodPost, "/api/auth/2fa/confirm", token, TwoFactorCodeRequest{Code: totpAt(s.t, secret, step)})
+	expectStatus(s.t, w, http.StatusOK)
+	return secret, decode[RecoveryCodesResponse](s.t, w).RecoveryCodes
+}
+
+// challenge выполняет первый шаг входа и возвращает challenge токен 2FA
+func challenge(s *testServer, email, password string) string {
+	s.t.Helper()
+
+	w := s.do(http.MethodPost, "/api/auth/login", "", LoginRequest{Email: email, Password: password})
+	expectStatus(s.t, w, http.StatusOK)
+	response := decode[TwoFactorChallengeResponse](s.t, w)
+	if !response.TwoFactorRequired || response.ChallengeToken == "" {
+		s.t.Fatalf("login did not require two-factor authentication: %s", w.Body.String())
+	}
+	return response.ChallengeToken
+}
+
+func TestTOTPCodeCannotBeReplayed(t *testing.T) {
+	s := newTestServer(t)
+	registered := s.register("alice@example.com", "password123")
+	secret, _ := enableTwoFactor(s, registered.Token)
+
+	code := totpAt(t, secret, time.Now().Unix()/30)
+
+	w := s.do(http.MethodPost, "/api/auth/2fa/verify", "", TwoFactorVerifyRequest{
+		ChallengeToken: challenge(s, "alice@example.com", "password123"),
+		Code:           code,
+	})
+	expectStatus(t, w, http.StatusOK)
+
+	// Тот же код в новом входе отклоняется, даже если он ещё в пределах окна
+	w = s.do(http.MethodPost, "/api/auth/2fa/verify", "", TwoFactorVerifyRequest{
+		ChallengeToken: challenge(s, "alice@example.com", "password123"),
+		Code:           code,
+	})
+	expectStatus(t, w, http.StatusUnauthorized)
+}
+
+func TestTOTPConfirmationCodeCannotBeReplayed(t *testing.T) {
+	s := newTestServer(t)
+	registered := s.register("alice@example.com", "password123")
+	secret, _ := enableTwoFactor(s, registered.Token)
+
+	// Код, которым 2FA была подтверждена, не подходит для входа
+	w := s.do(http.MethodPost, "/api/auth/2fa/verify", "", TwoFactorVerifyRequest{
+		ChallengeToken: challenge(s, "alice@example.com", "password123"),
+		Code:           totpAt(t, secret, time.Now().Unix()/30-1),
+	})
+	expectStatus(t, w, http.StatusUnauthorized)
+}
+
+func TestRecoveryCodeIsSingleUse(t *testing.T) {
+	s := newTestServer(t)
+	registered := s.register("alice@example.com", "password123")
+	_, codes := enableTwoFactor(s, registered.Token)
+
+	w := s.do(http.MethodPost, "/api/auth/2fa/verify", "", TwoFactorVerifyRequest{
+		ChallengeToken: challenge(s, "alice@example.com", "password123"),
+		RecoveryCode:   codes[0],
+	})
+	expectStatus(t, w, http.StatusOK)
+
+	w = s.do(http.MethodPost, "/api/auth/2fa/verify", "", TwoFactorVerifyRequest{
+		ChallengeToken: challenge(s, "alice@example.com", "password123"),
+		RecoveryCode:   codes[0],
+	})
+	expectStatus(t, w, http.StatusUnauthorized)
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"commitcaster/internal/models"
+	"commitcaster/internal/usage"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	usageDefaultHistoryDays = 30
+	usageMaxHistoryDays     = 90
+)
+
+// Статус генерации постов в ответе /usage
+const (
+	usageStatusActive = "active"
+	usageStatusPaused = "paused"
+)
+
+type UsagePeriodResponse struct {
+	models.UsageCounts
+	PeriodStart time.Time `json:"period_start"`
+	ResetsAt    time.Time `json:"resets_at"`
+}
+
+type UsageDayResponse struct {
+	models.UsageCounts
+	Date string `json:"date"`
+}
+
+type UsageResponse struct {
+	Plan usage.Plan `json:"plan"`
+	// active или paused (квота исчерпана)
+	Status   string            `json:"status"`
+	Exceeded *usage.QuotaError `json:"exceeded,omitempty"`
+
+	Day     UsagePeriodResponse `json:"day"`
+	Month   UsagePeriodResponse `json:"month"`
+	History []UsageDayResponse  `json:"history"`
+}
+
+// GetUsage возвращает использование и квоты текущего пользователя
+// @Summary Использование и квоты
+// @Description Счётчики за текущие день и месяц (UTC): посты, запросы к AI, токены, отправки в Telegram; квоты тарифа и статус генерации (paused — квота исчерпана до resets_at). history — счётчики по дням
+// @Tags usage
+// @Security BearerAuth
+// @Produce json
+// @Param days query int false "History length in days (default 30, max 90)"
+// @Success 200 {object} UsageResponse
+// @Failure 401 {object} map[string]string
+// @Router /usage [get]
+func (h *APIHandler) GetUsage(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	ctx := c.Request.Context()
+	now := time.Now()
+
+	days, _ := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(usageDefaultHistoryDays)))
+	if days < 1 || days > usageMaxHistoryDays {
+		days = usageDefaultHistoryDays
+	}
+
+	user, err := h.store.Users.Get(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	day, month, err := usage.Current(ctx, h.store.Usage, userID, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load usage"})
+		return
+	}
+
+	counters, err := h.store.Usage.List(ctx, userID, models.UsagePeriodDay, usage.DayStart(now).AddDate(0, 0, -days))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load usage"})
+		return
+	}
+
+	plan := usage.PlanFor(user.Plan)
+	response := UsageResponse{
+		Plan:   plan,
+		Status: usageStatusActive,
+		Day: UsagePeriodResponse{
+			UsageCounts: day.UsageCounts,
+			PeriodStart: day.PeriodStart,
+			ResetsAt:    day.PeriodStart.AddDate(0, 0, 1),
+		},
+		Month: UsagePeriodResponse{
+			UsageCounts: month.UsageCounts,
+			PeriodStart: month.PeriodStart,
+			ResetsAt:    month.PeriodStart.AddDate(0, 1, 0),
+		},
+		History: make([]UsageDayResponse, 0, len(counters)),
+	}
+	if exceeded := usage.Check(plan, day.UsageCounts, month.UsageCounts, now); exceeded != nil {
+		response.Status = usageStatusPaused
+		response.Exceeded = exceeded
+	}
+	for _, counter := range counters {
+		response.History = append(response.History, UsageDayResponse{
+			UsageCounts: counter.UsageCounts,
+			Date:        counter.PeriodStart.UTC().Format("2006-01-02"),
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ListPlans возвращает доступные тарифы
+// @Summary Тарифы
+// @Description Тарифы и их квоты (0 — без ограничения)
+// @Tags usage
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} usage.Plan
+// @Failure 401 {object} map[string]string
+// @Router /usage/plans [get]
+func (h *APIHandler) ListPlans(c *gin.Context) {
+	c.JSON(http.StatusOK, usage.Plans())
+}
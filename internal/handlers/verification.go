@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"commitcaster/internal/auth"
+	"commitcaster/internal/mailer"
+	"commitcaster/internal/models"
+	"commitcaster/internal/store"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	verifyEmailTTL   = 48 * time.Hour
+	resetPasswordTTL = time.Hour
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+var errInvalidUserToken = errors.New("invalid or expired token")
+
+// ForgotPassword отправляет письмо со ссылкой для сброса пароля
+// @Summary Забыли пароль
+// @Description Отправляет на email одноразовую ссылку для сброса пароля (действует 1 час). Письмо отправляется в фоне: ни содержимое, ни время ответа не раскрывают, существует ли аккаунт
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ForgotPasswordRequest true "Email"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /auth/forgot [post]
+func (h *APIHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Поиск аккаунта и отправка письма выполняются в фоне: время ответа одинаково
+	// для зарегистрированных и незарегистрированных адресов
+	if err := h.jobs.Go(c.Request.Context(), func(ctx context.Context) {
+		h.sendPasswordReset(ctx, req.Email)
+	}); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service is shutting down"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the account exists, a password reset email has been sent"})
+}
+
+// sendPasswordReset отправляет ссылку сброса пароля, если аккаунт с email существует
+func (h *APIHandler) sendPasswordReset(ctx context.Context, email string) {
+	user, err := h.store.Users.GetByEmail(ctx, email)
+	if errors.Is(err, store.ErrNotFound) {
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to look up user for password reset", "error", err)
+		return
+	}
+
+	token, err := createUserToken(ctx, h.store.UserTokens, user.ID, models.TokenPurposeResetPassword, resetPasswordTTL)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create reset token", "user_id", user.ID, "error", err)
+		return
+	}
+	h.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "CommitCaster: сброс пароля",
+		Body: fmt.Sprintf("Чтобы задать новый пароль, перейдите по ссылке (действует 1 час):\n\n%s\n\n"+
+			"Если вы не запрашивали сброс пароля, просто проигнорируйте это письмо.",
+			appURL("/reset-password?token="+token)),
+	})
+}
+
+// ResetPassword задаёт новый пароль по токену из письма
+// @Summary Сброс пароля
+// @Description Задаёт новый пароль по одноразовому токену и завершает все сессии пользователя
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /auth/reset [post]
+func (h *APIHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	var userID uint
+	err := h.store.Transaction(ctx, func(tx *store.Store) error {
+		userToken, err := consumeUserToken(ctx, tx.UserTokens, req.Token, models.TokenPurposeResetPassword)
+		if err != nil {
+			return err
+		}
+
+		user, err := tx.Users.Get(ctx, userToken.UserID)
+		if err != nil {
+			return errInvalidUserToken
+		}
+		userID = user.ID
+		if err := user.SetPassword(req.Password); err != nil {
+			return err
+		}
+		// Сброс по ссылке из письма подтверждает владение адресом
+		if user.EmailVerifiedAt == nil {
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+		}
+		// Новый пароль снимает блокировку входа
+		user.FailedLogins = 0
+		user.LockedUntil = nil
+		if err := tx.Users.Save(ctx, &user); err != nil {
+			return err
+		}
+
+		// Остальные ссылки сброса и все сессии больше не действуют
+		if err := tx.UserTokens.Invalidate(ctx, user.ID, models.TokenPurposeResetPassword, time.Now()); err != nil {
+			return err
+		}
+		_, err = tx.Sessions.RevokeUser(ctx, user.ID, time.Now())
+		return err
+	})
+	if errors.Is(err, errInvalidUserToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+	h.recordAuditAs(c, userID, models.AuditPasswordReset, "user", userID, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
+
+// VerifyEmail подтверждает email по токену из письма
+// @Summary Подтверждение email
+// @Description Подтверждает email пользователя по одноразовому токену из письма
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body VerifyEmailRequest true "Verification token"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /auth/verify [post]
+func (h *APIHandler) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	err := h.store.Transaction(ctx, func(tx *store.Store) error {
+		userToken, err := consumeUserToken(ctx, tx.UserTokens, req.Token, models.TokenPurposeVerifyEmail)
+		if err != nil {
+			return err
+		}
+		return tx.Users.MarkEmailVerified(ctx, userToken.UserID, time.Now())
+	})
+	if errors.Is(err, errInvalidUserToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+// ResendVerification повторно отправляет письмо для подтверждения email
+// @Summary Повторить письмо подтверждения
+// @Description Отправляет новое письмо для подтверждения email текущего пользователя
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /auth/verify/resend [post]
+func (h *APIHandler) ResendVerification(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	ctx := c.Request.Context()
+
+	user, err := h.store.Users.Get(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already verified"})
+		return
+	}
+
+	if err := h.sendVerificationEmail(ctx, &user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+// sendVerificationEmail создаёт токен подтверждения и отправляет письмо
+func (h *APIHandler) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	if err := h.store.UserTokens.Invalidate(ctx, user.ID, models.TokenPurposeVerifyEmail, time.Now()); err != nil {
+		return err
+	}
+	token, err := createUserToken(ctx, h.store.UserTokens, user.ID, models.TokenPurposeVerifyEmail, verifyEmailTTL)
+	if err != nil {
+		return err
+	}
+
+	return h.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "CommitCaster: подтвердите email",
+		Body: fmt.Sprintf("Подтвердите адрес, перейдя по ссылке (действует 48 часов):\n\n%s",
+			appURL("/verify-email?token="+token)),
+	})
+}
+
+func (h *APIHandler) sendMail(msg mailer.Message) error {
+	if err := h.mailer.Send(msg); err != nil {
+		slog.Error("Failed to send email", "to", msg.To, "subject", msg.Subject, "error", err)
+		return err
+	}
+	return nil
+}
+
+// createUserToken создаёт одноразовый токен и сохраняет его хеш
+func createUserToken(ctx context.Context, tokens store.UserTokenStore, userID uint, purpose string, ttl time.Duration) (string, error) {
+	token := auth.RandomToken(32)
+	userToken := models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := tokens.Create(ctx, &userToken); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// consumeUserToken проверяет токен и помечает его использованным
+func consumeUserToken(ctx context.Context, tokens store.UserTokenStore, token, purpose string) (*models.UserToken, error) {
+	userToken, err := tokens.GetByHash(ctx, auth.HashToken(token), purpose)
+	if err != nil || userToken.UsedAt != nil || time.Now().After(userToken.ExpiresAt) {
+		return nil, errInvalidUserToken
+	}
+
+	used, err := tokens.Use(ctx, userToken.ID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, errInvalidUserToken
+	}
+
+	return &userToken, nil
+}
+
+// appURL возвращает ссылку на страницу фронтенда (APP_URL, по умолчанию BASE_URL)
+func appURL(path string) string {
+	base := os.Getenv("APP_URL")
+	if base == "" {
+		base = os.Getenv("BASE_URL")
+	}
+	if base == "" {
+		base = "http://localhost:8080"
+	}
+	return base + path
+}
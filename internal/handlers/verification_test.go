@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestForgotPasswordDoesNotRevealAccounts(t *testing.T) {
+	s := newTestServer(t)
+	s.register("alice@example.com", "password123")
+	registered := len(s.mailer.messages())
+
+	known := s.do(http.MethodPost, "/api/auth/forgot", "", ForgotPasswordRequest{Email: "alice@example.com"})
+	unknown := s.do(http.MethodPost, "/api/auth/forgot", "", ForgotPasswordRequest{Email: "nobody@example.com"})
+	expectStatus(t, known, http.StatusOK)
+	expectStatus(t, unknown, http.StatusOK)
+	if known.Body.String() != unknown.Body.String() {
+		t.Errorf("responses differ: %s vs %s", known.Body.String(), unknown.Body.String())
+	}
+
+	// Письмо отправляется в фоне: дожидаемся фоновых задач
+	if err := s.jobs.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	sent := s.mailer.messages()[registered:]
+	if len(sent) != 1 {
+		t.Fatalf("sent %d emails, want 1", len(sent))
+	}
+	if sent[0].To != "alice@example.com" || !strings.Contains(sent[0].Body, "/reset-password?token=") {
+		t.Errorf("unexpected email to %s: %s", sent[0].To, sent[0].Body)
+	}
+}
+
+func TestForgotPasswordAfterShutdown(t *testing.T) {
+	s := newTestServer(t)
+	if err := s.jobs.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	w := s.do(http.MethodPost, "/api/auth/forgot", "", ForgotPasswordRequest{Email: "alice@example.com"})
+	expectStatus(t, w, http.StatusServiceUnavailable)
+}
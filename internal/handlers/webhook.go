@@ -1,152 +1,146 @@
 package handlers
 
 import (
-	"commitcaster/config"
+	"commitcaster/internal/jobs"
+	"commitcaster/internal/metrics"
 	"commitcaster/internal/models"
-	"commitcaster/internal/services"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
+	"commitcaster/internal/pipeline"
+	"context"
 	"encoding/json"
-	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// WebhookHandler — webhook single-user режима: настройки берутся из переменных
+// окружения или файла конфигурации
 type WebhookHandler struct {
-	cfg             *config.Config
-	telegramService *services.TelegramService
-	aiService       *services.AIService
+	provider pipeline.Provider
+	pipeline *pipeline.Pipeline
+	pending  *jobs.PendingFile
 }
 
-func NewWebhookHandler(cfg *config.Config, telegramService *services.TelegramService, aiService *services.AIService) *WebhookHandler {
+// pendingJob — задача, прерванная остановкой сервиса. Post задан, если пост
+// уже сгенерирован: после запуска он отправляется без повторного запроса к AI
+// в каналы, куда ещё не отправлен (Sent)
+type pendingJob struct {
+	Project string                      `json:"project,omitempty"`
+	Payload models.GitHubWebhookPayload `json:"payload"`
+	Post    string                      `json:"post,omitempty"`
+	Sent    []string                    `json:"sent,omitempty"`
+}
+
+func NewWebhookHandler(provider pipeline.Provider, tracker *jobs.Tracker, pending *jobs.PendingFile) *WebhookHandler {
 	return &WebhookHandler{
-		cfg:             cfg,
-		telegramService: telegramService,
-		aiService:       aiService,
+		provider: provider,
+		pipeline: pipeline.New(tracker, pendingJournal{pending: pending}),
+		pending:  pending,
 	}
 }
 
-// HandleGitHubWebhook обрабатывает webhook от GitHub
+// HandleGitHubWebhook обрабатывает webhook от GitHub. С файлом конфигурации
+// проект выбирается по токену из URL (/webhook/github/:token)
 func (h *WebhookHandler) HandleGitHubWebhook(c *gin.Context) {
-	// Читаем тело запроса
-	body, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		log.Printf("Error reading body: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot read body"})
-		return
-	}
+	ctx := webhookContext(c)
 
-	// Проверяем подпись GitHub
-	if !h.verifySignature(body, c.GetHeader("X-Hub-Signature-256")) {
-		log.Println("Invalid signature")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
-		return
-	}
-
-	// Парсим payload
-	var payload models.GitHubWebhookPayload
-	if err := json.Unmarshal(body, &payload); err != nil {
-		log.Printf("Error parsing JSON: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+	target, err := h.provider.Resolve(ctx, c.Param("token"))
+	if err != nil {
+		slog.WarnContext(ctx, "Project not found for webhook token", "error", err)
+		metrics.Webhook(metrics.WebhookRejected, "invalid_token")
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invalid webhook token"})
 		return
 	}
 
-	// Проверяем, что это push event с коммитами
-	event := c.GetHeader("X-GitHub-Event")
-	if event != "push" || len(payload.Commits) == 0 {
-		c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+	// Без секрета подпись не проверяется: single-user сервис обычно не публичный
+	payload, rejection := pipeline.Ingest(ctx, target, c.Request.Header, http.MaxBytesReader(c.Writer, c.Request.Body, pipeline.MaxBodySize), true)
+	if rejection != nil {
+		rejectWebhook(c, rejection)
 		return
 	}
 
 	// Обрабатываем коммиты асинхронно
-	go h.processCommits(payload)
+	metrics.Webhook(metrics.WebhookAccepted, "push")
+	h.pipeline.Start(ctx, pipeline.Job{Target: target, Payload: payload, Started: time.Now()})
 
 	c.JSON(http.StatusOK, gin.H{"message": "Webhook received"})
 }
 
-func (h *WebhookHandler) processCommits(payload models.GitHubWebhookPayload) {
-	// Собираем информацию о коммитах
-	commitSummary := h.buildCommitSummary(payload)
-
-	log.Printf("Processing commits for repo: %s", payload.Repository.Name)
-	log.Printf("Commit summary: %s", commitSummary)
-
-	// Генерируем пост с помощью AI
-	post, err := h.aiService.GeneratePost(commitSummary, payload.Repository.Name)
+// ResumePending выполняет задачи, прерванные предыдущей остановкой сервиса
+func (h *WebhookHandler) ResumePending(ctx context.Context) error {
+	pending, err := h.pending.Take()
 	if err != nil {
-		log.Printf("Error generating post: %v", err)
-		return
+		return err
 	}
 
-	// Отправляем в Telegram
-	if err := h.telegramService.SendMessage(post); err != nil {
-		log.Printf("Error sending to Telegram: %v", err)
-		return
+	for _, data := range pending {
+		var job pendingJob
+		if err := json.Unmarshal(data, &job); err != nil {
+			slog.ErrorContext(ctx, "Skipping invalid pending job", "error", err)
+			continue
+		}
+		target, err := h.provider.Lookup(ctx, job.Project)
+		if err != nil {
+			slog.ErrorContext(ctx, "Skipping pending job of unknown project", "project", job.Project, "error", err)
+			continue
+		}
+		slog.InfoContext(ctx, "Resuming interrupted job", "repository", job.Payload.Repository.FullName)
+		h.pipeline.Start(ctx, pipeline.Job{
+			Target:  target,
+			Payload: job.Payload,
+			Started: time.Now(),
+			Post:    job.Post,
+			Sent:    job.Sent,
+		})
 	}
-
-	log.Println("Successfully posted to Telegram")
+	return nil
 }
 
-func (h *WebhookHandler) buildCommitSummary(payload models.GitHubWebhookPayload) string {
-	var summary strings.Builder
+// Shutdown сохраняет задачи, ждущие окна публикации, для продолжения после запуска
+func (h *WebhookHandler) Shutdown(ctx context.Context) {
+	h.pipeline.Stop(ctx)
+}
 
-	summary.WriteString(fmt.Sprintf("Репозиторий: %s\n", payload.Repository.Name))
-	summary.WriteString(fmt.Sprintf("Количество коммитов: %d\n\n", len(payload.Commits)))
+// HealthCheck для проверки работоспособности
+func (h *WebhookHandler) HealthCheck(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"service": "CommitCaster",
+	})
+}
 
-	for i, commit := range payload.Commits {
-		if i >= 5 { // Ограничиваем 5 коммитами
-			summary.WriteString(fmt.Sprintf("...и ещё %d коммитов\n", len(payload.Commits)-5))
-			break
-		}
+// pendingJournal — журнал single-user режима: история доставок и использование
+// не хранятся, прерванные задачи сохраняются в файл
+type pendingJournal struct {
+	pending *jobs.PendingFile
+}
 
-		summary.WriteString(fmt.Sprintf("Коммит %d:\n", i+1))
-		summary.WriteString(fmt.Sprintf("Сообщение: %s\n", commit.Message))
+func (j pendingJournal) ReserveQuota(ctx context.Context, job *pipeline.Job) error { return nil }
 
-		if len(commit.Added) > 0 {
-			summary.WriteString(fmt.Sprintf("Добавлено файлов: %d\n", len(commit.Added)))
-		}
-		if len(commit.Modified) > 0 {
-			summary.WriteString(fmt.Sprintf("Изменено файлов: %d\n", len(commit.Modified)))
-		}
-		if len(commit.Removed) > 0 {
-			summary.WriteString(fmt.Sprintf("Удалено файлов: %d\n", len(commit.Removed)))
-		}
-		summary.WriteString("\n")
-	}
+func (j pendingJournal) ReleaseQuota(ctx context.Context, job *pipeline.Job) {}
 
-	return summary.String()
+func (j pendingJournal) RecordUsage(ctx context.Context, job *pipeline.Job, counts models.UsageCounts) {
 }
 
-func (h *WebhookHandler) verifySignature(payload []byte, signature string) bool {
-	if h.cfg.GitHubSecret == "" {
-		log.Println("Warning: GitHub secret not configured, skipping signature verification")
-		return true
-	}
-
-	if signature == "" {
-		return false
-	}
-
-	// Убираем префикс "sha256="
-	signature = strings.TrimPrefix(signature, "sha256=")
+func (j pendingJournal) RecordPost(ctx context.Context, job *pipeline.Job, post models.Post) {}
 
-	// Вычисляем HMAC
-	mac := hmac.New(sha256.New, []byte(h.cfg.GitHubSecret))
-	mac.Write(payload)
-	expectedMAC := hex.EncodeToString(mac.Sum(nil))
+func (j pendingJournal) Finish(ctx context.Context, job *pipeline.Job, status, errMsg string) {}
 
-	return hmac.Equal([]byte(signature), []byte(expectedMAC))
+func (j pendingJournal) Interrupt(ctx context.Context, job *pipeline.Job) {
+	if err := j.pending.Add(pendingJob{Project: job.Project.Name, Payload: job.Payload, Post: job.Post, Sent: job.Sent}); err != nil {
+		slog.ErrorContext(ctx, "Failed to save interrupted job, it is lost", "repository", job.Payload.Repository.FullName, "error", err)
+		return
+	}
+	slog.WarnContext(ctx, "Job interrupted by shutdown, will resume after restart", "file", j.pending.Path())
 }
 
-// HealthCheck для проверки работоспособности
-func (h *WebhookHandler) HealthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status": "ok",
-		"service": "CommitCaster",
-	})
+// rejectWebhook отвечает GitHub на отклонённый или пропущенный webhook
+func rejectWebhook(c *gin.Context, rejection *pipeline.Rejection) {
+	metrics.Webhook(rejection.Result, rejection.Reason)
+	if rejection.Ignored() {
+		c.JSON(rejection.Status, gin.H{"message": rejection.Message})
+		return
+	}
+	c.JSON(rejection.Status, gin.H{"error": rejection.Message})
 }
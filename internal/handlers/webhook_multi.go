@@ -1,26 +1,45 @@
 package handlers
 
 import (
-	"commitcaster/internal/database"
+	"commitcaster/internal/auth"
+	"commitcaster/internal/jobs"
+	"commitcaster/internal/logging"
+	"commitcaster/internal/metrics"
+	"commitcaster/internal/middleware"
 	"commitcaster/internal/models"
-	"commitcaster/internal/services"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
+	"commitcaster/internal/pipeline"
+	"commitcaster/internal/ratelimit"
+	"commitcaster/internal/store"
+	"commitcaster/internal/usage"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-type MultiUserWebhookHandler struct{}
+type MultiUserWebhookHandler struct {
+	store    *store.Store
+	pipeline *pipeline.Pipeline
 
-func NewMultiUserWebhookHandler() *MultiUserWebhookHandler {
-	return &MultiUserWebhookHandler{}
+	// Лимит доставок на проект (RATE_LIMIT_WEBHOOK_TOKEN)
+	limiter     ratelimit.Limiter
+	projectRule ratelimit.Rule
+}
+
+func NewMultiUserWebhookHandler(st *store.Store, tracker *jobs.Tracker, limiter ratelimit.Limiter, projectRule ratelimit.Rule) *MultiUserWebhookHandler {
+	return &MultiUserWebhookHandler{
+		store:       st,
+		pipeline:    pipeline.New(tracker, deliveryJournal{store: st}),
+		limiter:     limiter,
+		projectRule: projectRule,
+	}
 }
 
 // HandleGitHubWebhook обрабатывает webhook от GitHub для multi-user
@@ -31,143 +50,267 @@ func (h *MultiUserWebhookHandler) HandleGitHubWebhook(c *gin.Context) {
 		return
 	}
 
-	// Находим пользователя по webhook токену
-	db := database.GetDB()
-	var user models.User
-	if err := db.Where("webhook_token = ?", webhookToken).First(&user).Error; err != nil {
-		log.Printf("User not found for token: %s", webhookToken)
+	ctx := webhookContext(c)
+
+	// Находим проект по webhook токену
+	target, err := h.Resolve(ctx, webhookToken)
+	if err != nil {
+		slog.WarnContext(ctx, "Project not found for webhook token", "error", err)
+		metrics.Webhook(metrics.WebhookRejected, "invalid_token")
 		c.JSON(http.StatusNotFound, gin.H{"error": "Invalid webhook token"})
 		return
 	}
 
-	// Загружаем настройки пользователя
-	var settings models.UserSettings
-	if err := db.Where("user_id = ?", user.ID).First(&settings).Error; err != nil {
-		log.Printf("Settings not found for user: %d", user.ID)
-		c.JSON(http.StatusNotFound, gin.H{"error": "User settings not configured"})
+	// Ключ лимита — проект, а не токен: в хранилище лимитов не попадают ни токены,
+	// ни случайные значения, а прежний и новый токены после ротации делят одну корзину
+	if !middleware.Allow(c, h.limiter, "webhook_token", h.projectRule, target.LimitKey()) {
 		return
 	}
 
-	// Проверяем что бот активен
-	if !settings.IsActive {
-		log.Printf("Bot is inactive for user: %d", user.ID)
-		c.JSON(http.StatusForbidden, gin.H{"error": "Bot is not active. Please configure your tokens first"})
+	payload, rejection := pipeline.Ingest(ctx, target, c.Request.Header, http.MaxBytesReader(c.Writer, c.Request.Body, pipeline.MaxBodySize), auth.AllowUnsignedWebhooks())
+	if rejection != nil {
+		rejectWebhook(c, rejection)
 		return
 	}
 
-	// Читаем тело запроса
-	body, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		log.Printf("Error reading body: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot read body"})
-		return
+	project, owner := target.Project, target.Owner
+
+	// Запоминаем доставку, чтобы видеть историю и ошибки обработки
+	delivery := models.Delivery{
+		UserID:      project.UserID,
+		Repository:  payload.Repository.FullName,
+		Ref:         payload.Ref,
+		CommitCount: len(payload.Commits),
+		Status:      models.DeliveryProcessing,
+	}
+	if project.ID != 0 {
+		delivery.ProjectID = &project.ID
 	}
 
-	// Проверяем подпись GitHub
-	if !h.verifySignature(body, c.GetHeader("X-Hub-Signature-256"), settings.GitHubSecret) {
-		log.Println("Invalid signature")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
-		return
+	// При исчерпанной квоте доставка сохраняется в истории, но пост не генерируется
+	quotaErr, err := usage.CheckUser(ctx, h.store.Usage, owner.ID, owner.Plan, time.Now())
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to check usage", "user_id", owner.ID, "error", err)
+	}
+	if quotaErr != nil {
+		delivery.Status = models.DeliveryQuotaExceeded
+		delivery.Error = quotaErr.Error()
 	}
 
-	// Парсим payload
-	var payload models.GitHubWebhookPayload
-	if err := json.Unmarshal(body, &payload); err != nil {
-		log.Printf("Error parsing JSON: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+	if err := h.store.Deliveries.Create(ctx, &delivery); err != nil {
+		slog.ErrorContext(ctx, "Failed to save delivery", "error", err)
+		metrics.Webhook(metrics.WebhookRejected, "store_error")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept webhook"})
 		return
 	}
 
-	// Проверяем, что это push event с коммитами
-	event := c.GetHeader("X-GitHub-Event")
-	if event != "push" || len(payload.Commits) == 0 {
-		c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+	ctx = logging.With(ctx, "delivery_id", delivery.ID)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("commitcaster.delivery_id", int(delivery.ID)))
+
+	if quotaErr != nil {
+		slog.InfoContext(ctx, "Usage quota exceeded", "user_id", owner.ID, "quota", quotaErr.Quota, "error", quotaErr)
+		metrics.Webhook(metrics.WebhookRejected, "quota_exceeded")
+		metrics.Delivery(models.DeliveryQuotaExceeded, delivery.CreatedAt)
+		middleware.TooManyRequests(c, quotaErr.Error(), time.Until(quotaErr.ResetsAt))
 		return
 	}
 
 	// Обрабатываем коммиты асинхронно
-	go h.processCommits(payload, settings)
+	metrics.Webhook(metrics.WebhookAccepted, "push")
+	h.pipeline.Start(ctx, pipeline.Job{
+		Target:     target,
+		Payload:    payload,
+		DeliveryID: delivery.ID,
+		Started:    delivery.CreatedAt,
+	})
 
 	c.JSON(http.StatusOK, gin.H{"message": "Webhook received"})
 }
 
-func (h *MultiUserWebhookHandler) processCommits(payload models.GitHubWebhookPayload, settings models.UserSettings) {
-	// Собираем информацию о коммитах
-	commitSummary := h.buildCommitSummary(payload, settings.MaxCommits)
+// ResumeInterrupted продолжает доставки, прерванные остановкой сервиса. Доставка
+// забирается атомарно, поэтому при нескольких экземплярах её продолжит только один
+func (h *MultiUserWebhookHandler) ResumeInterrupted(ctx context.Context) error {
+	deliveries, err := h.store.Deliveries.ListByStatus(ctx, models.DeliveryInterrupted)
+	if err != nil {
+		return fmt.Errorf("failed to load interrupted deliveries: %w", err)
+	}
 
-	log.Printf("Processing commits for repo: %s (user_id: %d)", payload.Repository.Name, settings.UserID)
+	journal := deliveryJournal{store: h.store}
+	for _, delivery := range deliveries {
+		claimed, err := h.store.Deliveries.Claim(ctx, delivery.ID, models.DeliveryInterrupted, models.DeliveryProcessing)
+		if err != nil {
+			return fmt.Errorf("failed to claim delivery %d: %w", delivery.ID, err)
+		}
+		if !claimed {
+			continue
+		}
 
-	// Создаём сервисы с настройками пользователя
-	telegramService := services.NewTelegramServiceWithSettings(&settings)
-	aiService := services.NewAIServiceWithSettings(&settings)
+		deliveryCtx := logging.With(ctx, "delivery_id", delivery.ID)
+		job, err := h.loadDelivery(deliveryCtx, delivery)
+		if err != nil {
+			metrics.Delivery(models.DeliveryFailed, delivery.CreatedAt)
+			journal.Finish(deliveryCtx, &job, models.DeliveryFailed, fmt.Sprintf("resume: %v", err))
+			continue
+		}
 
-	// Генерируем пост с помощью AI
-	post, err := aiService.GeneratePost(commitSummary, payload.Repository.Name)
-	if err != nil {
-		log.Printf("Error generating post: %v", err)
-		return
+		slog.InfoContext(deliveryCtx, "Resuming interrupted delivery", "repository", delivery.Repository)
+		h.pipeline.Start(deliveryCtx, job)
 	}
+	return nil
+}
 
-	// Отправляем в Telegram
-	if err := telegramService.SendMessage(post); err != nil {
-		log.Printf("Error sending to Telegram: %v", err)
-		return
+// loadDelivery восстанавливает задачу прерванной доставки: пост, если он уже
+// сгенерирован, и каналы, куда он отправлен. С постом задача сразу переходит
+// к публикации: AI не вызывается, квота не расходуется
+func (h *MultiUserWebhookHandler) loadDelivery(ctx context.Context, delivery models.Delivery) (pipeline.Job, error) {
+	job := pipeline.Job{DeliveryID: delivery.ID, Started: delivery.CreatedAt, Post: delivery.Post}
+	var err error
+
+	if err := json.Unmarshal([]byte(delivery.Payload), &job.Payload); err != nil {
+		return job, fmt.Errorf("invalid payload: %w", err)
+	}
+	if job.Owner, err = h.store.Users.Get(ctx, delivery.UserID); err != nil {
+		return job, fmt.Errorf("user not found: %w", err)
+	}
+	if job.Owner.IsDeactivated() {
+		return job, errors.New("account deactivated")
+	}
+	if job.Settings, err = h.store.Settings.GetByUser(ctx, job.Owner.ID); err != nil {
+		return job, fmt.Errorf("settings not found: %w", err)
 	}
 
-	log.Printf("Successfully posted to Telegram for user_id: %d", settings.UserID)
+	if delivery.ProjectID != nil {
+		if job.Project, err = h.store.Projects.Get(ctx, *delivery.ProjectID); err != nil {
+			return job, fmt.Errorf("project not found: %w", err)
+		}
+	} else {
+		job.Project = pipeline.DefaultProject(job.Owner, job.Settings)
+	}
+	if !job.Project.IsReady(job.Settings) {
+		return job, errors.New("bot is not active")
+	}
+
+	sentPosts, err := h.store.Posts.ListByDelivery(ctx, delivery.ID, models.PostSent)
+	if err != nil {
+		return job, fmt.Errorf("load sent posts: %w", err)
+	}
+	for _, post := range sentPosts {
+		job.Post = post.Content
+		job.Sent = append(job.Sent, post.TelegramChannelID)
+	}
+	return job, nil
 }
 
-func (h *MultiUserWebhookHandler) buildCommitSummary(payload models.GitHubWebhookPayload, maxCommits int) string {
-	var summary strings.Builder
+// Resolve находит проект, его настройки и владельца по webhook токену. Токен
+// пользователя (User.WebhookToken) работает как проект по умолчанию на основе UserSettings
+func (h *MultiUserWebhookHandler) Resolve(ctx context.Context, webhookToken string) (pipeline.Target, error) {
+	var target pipeline.Target
 
-	if maxCommits == 0 {
-		maxCommits = 5
+	// Прежний токен после ротации действует до истечения grace period
+	now := time.Now()
+
+	project, err := h.store.Projects.GetByWebhookToken(ctx, webhookToken, now)
+	if err == nil {
+		target.Project = project
+		if target.Owner, err = h.store.Users.Get(ctx, project.UserID); err != nil {
+			return target, err
+		}
+	} else if errors.Is(err, store.ErrNotFound) {
+		if target.Owner, err = h.store.Users.GetByWebhookToken(ctx, webhookToken, now); err != nil {
+			return target, err
+		}
+	} else {
+		return target, err
 	}
 
-	summary.WriteString(fmt.Sprintf("Репозиторий: %s\n", payload.Repository.Name))
-	summary.WriteString(fmt.Sprintf("Количество коммитов: %d\n\n", len(payload.Commits)))
+	if target.Settings, err = h.store.Settings.GetByUser(ctx, target.Owner.ID); err != nil {
+		return target, err
+	}
+	if target.Project.ID == 0 {
+		target.Project = pipeline.DefaultProject(target.Owner, target.Settings)
+	}
+	return target, nil
+}
 
-	for i, commit := range payload.Commits {
-		if i >= maxCommits {
-			summary.WriteString(fmt.Sprintf("...и ещё %d коммитов\n", len(payload.Commits)-maxCommits))
-			break
-		}
+// deliveryJournal — журнал SaaS режима: доставки, посты и использование хранятся в БД.
+// ctx обработки отменяется при остановке сервиса, а запросы к БД должны успеть
+// сохранить состояние доставки, поэтому они выполняются без отмены
+type deliveryJournal struct {
+	store *store.Store
+}
 
-		summary.WriteString(fmt.Sprintf("Коммит %d:\n", i+1))
-		summary.WriteString(fmt.Sprintf("Сообщение: %s\n", commit.Message))
+// ReserveQuota резервирует AI запрос. Если учёт недоступен, генерация не блокируется
+func (j deliveryJournal) ReserveQuota(ctx context.Context, job *pipeline.Job) error {
+	now := time.Now()
+	quotaErr, err := usage.Reserve(context.WithoutCancel(ctx), j.store.Usage, job.Owner.ID, job.Owner.Plan, now)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to reserve usage", "user_id", job.Owner.ID, "error", err)
+		return nil
+	}
+	if quotaErr != nil {
+		slog.InfoContext(ctx, "Usage quota exceeded", "user_id", job.Owner.ID, "quota", quotaErr.Quota, "error", quotaErr)
+		return quotaErr
+	}
+	job.QuotaReserved = now
+	return nil
+}
 
-		if len(commit.Added) > 0 {
-			summary.WriteString(fmt.Sprintf("Добавлено файлов: %d\n", len(commit.Added)))
-		}
-		if len(commit.Modified) > 0 {
-			summary.WriteString(fmt.Sprintf("Изменено файлов: %d\n", len(commit.Modified)))
-		}
-		if len(commit.Removed) > 0 {
-			summary.WriteString(fmt.Sprintf("Удалено файлов: %d\n", len(commit.Removed)))
-		}
-		summary.WriteString("\n")
+// RecordUsage учитывает использование; ошибка учёта не прерывает доставку
+func (j deliveryJournal) RecordUsage(ctx context.Context, job *pipeline.Job, counts models.UsageCounts) {
+	if err := usage.Record(context.WithoutCancel(ctx), j.store.Usage, job.Owner.ID, counts, time.Now()); err != nil {
+		slog.ErrorContext(ctx, "Failed to record usage", "user_id", job.Owner.ID, "error", err)
 	}
+}
 
-	return summary.String()
+// ReleaseQuota возвращает AI запрос, зарезервированный ReserveQuota
+func (j deliveryJournal) ReleaseQuota(ctx context.Context, job *pipeline.Job) {
+	if job.QuotaReserved.IsZero() {
+		return
+	}
+	if err := usage.Release(context.WithoutCancel(ctx), j.store.Usage, job.Owner.ID, job.QuotaReserved); err != nil {
+		slog.ErrorContext(ctx, "Failed to release usage", "user_id", job.Owner.ID, "error", err)
+	}
+	job.QuotaReserved = time.Time{}
 }
 
-func (h *MultiUserWebhookHandler) verifySignature(payload []byte, signature string, secret string) bool {
-	if secret == "" {
-		log.Println("Warning: GitHub secret not configured, skipping signature verification")
-		return true
+func (j deliveryJournal) RecordPost(ctx context.Context, job *pipeline.Job, post models.Post) {
+	if err := j.store.Posts.Create(context.WithoutCancel(ctx), &post); err != nil {
+		slog.ErrorContext(ctx, "Failed to save post", "error", err)
 	}
+}
 
-	if signature == "" {
-		return false
+// Finish сохраняет итоговый статус доставки. Payload и пост хранятся только до
+// завершения прерванной доставки
+func (j deliveryJournal) Finish(ctx context.Context, job *pipeline.Job, status, errMsg string) {
+	delivery := models.Delivery{ID: job.DeliveryID, Status: status, Error: errMsg}
+	if err := j.store.Deliveries.UpdateStatus(context.WithoutCancel(ctx), &delivery); err != nil {
+		slog.ErrorContext(ctx, "Failed to update delivery", "error", err)
 	}
+}
 
-	// Убираем префикс "sha256="
-	signature = strings.TrimPrefix(signature, "sha256=")
+// Interrupt сохраняет доставку вместе с payload и сгенерированным постом, чтобы
+// продолжить её после запуска (ResumeInterrupted) без повторной генерации
+func (j deliveryJournal) Interrupt(ctx context.Context, job *pipeline.Job) {
+	data, err := json.Marshal(job.Payload)
+	if err != nil {
+		j.Finish(ctx, job, models.DeliveryFailed, "interrupted by shutdown")
+		return
+	}
 
-	// Вычисляем HMAC
-	mac := hmac.New(sha256.New, []byte(secret))
-	mac.Write(payload)
-	expectedMAC := hex.EncodeToString(mac.Sum(nil))
+	delivery := models.Delivery{ID: job.DeliveryID, Status: models.DeliveryInterrupted, Payload: string(data), Post: job.Post}
+	if err := j.store.Deliveries.UpdateStatus(context.WithoutCancel(ctx), &delivery); err != nil {
+		slog.ErrorContext(ctx, "Failed to save interrupted delivery", "error", err)
+		return
+	}
+	slog.WarnContext(ctx, "Delivery interrupted by shutdown, will resume after restart")
+}
 
-	return hmac.Equal([]byte(signature), []byte(expectedMAC))
+// webhookContext — контекст обработки webhook: идентификатор доставки GitHub
+// попадает во все логи обработки, включая асинхронную
+func webhookContext(c *gin.Context) context.Context {
+	ctx := c.Request.Context()
+	if id := c.GetHeader("X-GitHub-Delivery"); id != "" {
+		ctx = logging.With(ctx, "github_delivery", id)
+	}
+	return ctx
 }
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"bytes"
+	"commitcaster/internal/auth"
+	"commitcaster/internal/jobs"
+	"commitcaster/internal/models"
+	"commitcaster/internal/pipeline"
+	"commitcaster/internal/ratelimit"
+	"commitcaster/internal/usage"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// readyWebhookUser регистрирует пользователя с заполненными настройками бота
+// и возвращает его webhook токен и секрет подписи
+func readyWebhookUser(s *testServer, email string) (token, secret string) {
+	s.t.Helper()
+
+	registered := s.register(email, "password123")
+	user := s.user(email)
+
+	settings, err := s.store.Settings.GetByUser(s.t.Context(), user.ID)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	settings.TelegramBotToken = "123:bot-token"
+	settings.TelegramChannelID = "@channel"
+	settings.GroqAPIKey = "ai-key"
+	settings.IsActive = true
+	if err := s.store.Settings.Save(s.t.Context(), &settings); err != nil {
+		s.t.Fatal(err)
+	}
+
+	return registered.WebhookToken, settings.GitHubSecret
+}
+
+// deliver отправляет webhook; пустая signature — запрос без подписи
+func (s *testServer) deliver(token, event string, body []byte, signature string) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook/github/"+token, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", event)
+	if signature != "" {
+		req.Header.Set(auth.SignatureHeader, signature)
+	}
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func pushPayload(t *testing.T) []byte {
+	t.Helper()
+
+	body, err := json.Marshal(models.GitHubWebhookPayload{
+		Ref:        "refs/heads/main",
+		Repository: models.Repository{Name: "repo", FullName: "alice/repo"},
+		Commits:    []models.Commit{{ID: "abc123", Message: "Fix bug"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func hmacSHA1Hex(body []byte, secret string) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhookSignatureVerification(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOW_UNSIGNED", "")
+	t.Setenv("WEBHOOK_ALLOW_SHA1", "")
+	s := newTestServer(t)
+	token, secret := readyWebhookUser(s, "alice@example.com")
+	body := []byte(`{"zen":"Keep it logically awesome."}`)
+
+	tests := []struct {
+		name      string
+		signature string
+		status    int
+	}{
+		{"valid signature", auth.SignGitHubPayload(body, secret), http.StatusOK},
+		{"missing signature", "", http.StatusUnauthorized},
+		{"wrong secret", auth.SignGitHubPayload(body, "other-secret"), http.StatusUnauthorized},
+		{"signature of other body", auth.SignGitHubPayload([]byte(`{}`), secret), http.StatusUnauthorized},
+		{"malformed signature", "sha256=not-hex", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectStatus(t, s.deliver(token, "ping", body, tt.signature), tt.status)
+		})
+	}
+}
+
+func TestWebhookRejectsSHA1SignatureByDefault(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOW_SHA1", "")
+	s := newTestServer(t)
+	token, secret := readyWebhookUser(s, "alice@example.com")
+	body := []byte(`{}`)
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook/github/"+token, bytes.NewReader(body))
+	req.Header.Set("X-GitHub-Event", "ping")
+	req.Header.Set(auth.SignatureHeaderSHA1, "sha1="+hmacSHA1Hex(body, secret))
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	expectStatus(t, w, http.StatusUnauthorized)
+}
+
+// clearWebhookSecret убирает секрет, как у аккаунтов, созданных до автоматических секретов
+func clearWebhookSecret(s *testServer, email string) {
+	s.t.Helper()
+
+	settings, err := s.store.Settings.GetByUser(s.t.Context(), s.user(email).ID)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	settings.GitHubSecret = ""
+	if err := s.store.Settings.Save(s.t.Context(), &settings); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+func TestWebhookWithoutSecretAcceptedUntilSecretCreated(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOW_UNSIGNED", "")
+	s := newTestServer(t)
+	token, _ := readyWebhookUser(s, "alice@example.com")
+	clearWebhookSecret(s, "alice@example.com")
+
+	w := s.do(http.MethodPost, "/api/auth/login", "", LoginRequest{Email: "alice@example.com", Password: "password123"})
+	expectStatus(t, w, http.StatusOK)
+	session := decode[AuthResponse](t, w).Token
+
+	expectStatus(t, s.deliver(token, "ping", []byte(`{}`), ""), http.StatusOK)
+
+	w = s.do(http.MethodGet, "/api/settings", session, nil)
+	expectStatus(t, w, http.StatusOK)
+	if !decode[SettingsResponse](t, w).WebhookSecretMissing {
+		t.Error("settings do not flag the missing webhook secret")
+	}
+
+	// После создания секрета подпись обязательна
+	w = s.do(http.MethodPost, "/api/webhook/secret", session, nil)
+	expectStatus(t, w, http.StatusOK)
+	secret := decode[WebhookSecretResponse](t, w).GitHubSecret
+
+	expectStatus(t, s.deliver(token, "ping", []byte(`{}`), ""), http.StatusUnauthorized)
+	expectStatus(t, s.deliver(token, "ping", []byte(`{}`), auth.SignGitHubPayload([]byte(`{}`), secret)), http.StatusOK)
+
+	w = s.do(http.MethodGet, "/api/settings", session, nil)
+	expectStatus(t, w, http.StatusOK)
+	if decode[SettingsResponse](t, w).WebhookSecretMissing {
+		t.Error("settings flag a missing webhook secret after it was created")
+	}
+}
+
+func TestWebhookWithoutSecretRejectedWhenUnsignedDisabled(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOW_UNSIGNED", "false")
+	s := newTestServer(t)
+	token, _ := readyWebhookUser(s, "alice@example.com")
+	clearWebhookSecret(s, "alice@example.com")
+
+	expectStatus(t, s.deliver(token, "ping", []byte(`{}`), ""), http.StatusForbidden)
+}
+
+func TestWebhookQuotaEnforced(t *testing.T) {
+	t.Setenv("USAGE_DEFAULT_PLAN", "free")
+	s := newTestServer(t)
+	token, secret := readyWebhookUser(s, "alice@example.com")
+	user := s.user("alice@example.com")
+
+	plan := usage.PlanFor(user.Plan)
+	if plan.DailyAIRequests == 0 {
+		t.Fatalf("plan %q has no daily AI request quota", plan.Name)
+	}
+	if err := usage.Record(t.Context(), s.store.Usage, user.ID, models.UsageCounts{AIRequests: plan.DailyAIRequests}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	body := pushPayload(t)
+	w := s.deliver(token, "push", body, auth.SignGitHubPayload(body, secret))
+	expectStatus(t, w, http.StatusTooManyRequests)
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("quota response has no Retry-After header")
+	}
+
+	// Доставка сохраняется в истории, но пост не генерируется
+	deliveries, err := s.store.Deliveries.ListByUser(t.Context(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Status != models.DeliveryQuotaExceeded {
+		t.Fatalf("deliveries = %+v, want one with status %s", deliveries, models.DeliveryQuotaExceeded)
+	}
+}
+
+func TestWebhookRateLimitPerProject(t *testing.T) {
+	s := newTestServer(t)
+	token, secret := readyWebhookUser(s, "alice@example.com")
+	body := []byte(`{}`)
+	signature := auth.SignGitHubPayload(body, secret)
+
+	// Неизвестные токены не расходуют лимит проекта
+	for i := 0; i < 20; i++ {
+		expectStatus(t, s.deliver(fmt.Sprintf("unknown-%d", i), "ping", body, signature), http.StatusNotFound)
+	}
+
+	for i := 0; i < 10; i++ {
+		expectStatus(t, s.deliver(token, "ping", body, signature), http.StatusOK)
+	}
+	w := s.deliver(token, "ping", body, signature)
+	expectStatus(t, w, http.StatusTooManyRequests)
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("rate limited response has no Retry-After header")
+	}
+}
+
+func TestWebhookBodyTooLarge(t *testing.T) {
+	s := newTestServer(t)
+	token, secret := readyWebhookUser(s, "alice@example.com")
+
+	body := bytes.Repeat([]byte(" "), pipeline.MaxBodySize+1)
+	expectStatus(t, s.deliver(token, "push", body, auth.SignGitHubPayload(body, secret)), http.StatusRequestEntityTooLarge)
+}
+
+func TestInterruptedDeliveryKeepsGeneratedPost(t *testing.T) {
+	s := newTestServer(t)
+	readyWebhookUser(s, "alice@example.com")
+	user := s.user("alice@example.com")
+	ctx := t.Context()
+
+	delivery := models.Delivery{UserID: user.ID, Status: models.DeliveryProcessing}
+	if err := s.store.Deliveries.Create(ctx, &delivery); err != nil {
+		t.Fatal(err)
+	}
+
+	// Остановка после генерации, но до отправки в каналы
+	journal := deliveryJournal{store: s.store}
+	job := pipeline.Job{
+		Target:     pipeline.Target{Owner: user},
+		DeliveryID: delivery.ID,
+		Payload:    models.GitHubWebhookPayload{Ref: "refs/heads/main", Commits: []models.Commit{{ID: "abc123"}}},
+		Post:       "Generated post",
+	}
+	journal.Interrupt(ctx, &job)
+
+	interrupted, err := s.store.Deliveries.ListByStatus(ctx, models.DeliveryInterrupted)
+	if err != nil || len(interrupted) != 1 {
+		t.Fatalf("interrupted deliveries = %d, %v", len(interrupted), err)
+	}
+
+	h := NewMultiUserWebhookHandler(s.store, jobs.NewTracker(), ratelimit.NewMemoryLimiter(), ratelimit.Rule{})
+	resumed, err := h.loadDelivery(ctx, interrupted[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// С постом продолжение сразу публикует его: генерация и проверка квоты пропускаются
+	if resumed.Post != job.Post {
+		t.Errorf("resumed post = %q, want %q", resumed.Post, job.Post)
+	}
+	if len(resumed.Payload.Commits) != 1 {
+		t.Errorf("resumed commits = %d, want 1", len(resumed.Payload.Commits))
+	}
+
+	// Итоговый статус очищает сохранённые payload и пост
+	journal.Finish(ctx, &job, models.DeliverySucceeded, "")
+	deliveries, err := s.store.Deliveries.ListByUser(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deliveries[0].Post != "" || deliveries[0].Payload != "" {
+		t.Errorf("finished delivery keeps post %q and payload %q", deliveries[0].Post, deliveries[0].Payload)
+	}
+}
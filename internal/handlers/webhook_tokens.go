@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"commitcaster/internal/auth"
+	"commitcaster/internal/models"
+	"commitcaster/internal/store"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxWebhookGracePeriod ограничивает срок действия прежнего токена после ротации
+const maxWebhookGracePeriod = 30 * 24 * time.Hour
+
+type RotateWebhookRequest struct {
+	// Сколько секунд прежний токен продолжает работать; 0 — отключить сразу.
+	// По умолчанию WEBHOOK_ROTATION_GRACE (24 часа)
+	GracePeriodSeconds *int `json:"grace_period_seconds"`
+}
+
+type WebhookInfoResponse struct {
+	WebhookToken string `json:"webhook_token"`
+	WebhookURL   string `json:"webhook_url"`
+
+	// Прежний токен, пока действует grace period после ротации
+	PreviousWebhookToken string     `json:"previous_webhook_token,omitempty"`
+	PreviousWebhookURL   string     `json:"previous_webhook_url,omitempty"`
+	PreviousExpiresAt    *time.Time `json:"previous_expires_at,omitempty"`
+}
+
+func newWebhookInfo(token, previousToken string, previousExpiresAt *time.Time) WebhookInfoResponse {
+	info := WebhookInfoResponse{
+		WebhookToken: token,
+		WebhookURL:   webhookURL(token),
+	}
+	if previousToken != "" && previousExpiresAt != nil && previousExpiresAt.After(time.Now()) {
+		info.PreviousWebhookToken = previousToken
+		info.PreviousWebhookURL = webhookURL(previousToken)
+		info.PreviousExpiresAt = previousExpiresAt
+	}
+	return info
+}
+
+// RotateWebhookToken выдаёт пользователю новый webhook токен
+// @Summary Ротация webhook токена
+// @Description Выдаёт новый webhook токен. Прежний продолжает работать в течение grace period (по умолчанию 24 часа), предыдущий до него отключается сразу
+// @Tags webhook
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body RotateWebhookRequest false "Grace period"
+// @Success 200 {object} WebhookInfoResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /webhook/rotate [post]
+func (h *APIHandler) RotateWebhookToken(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	grace, ok := bindGracePeriod(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	user, err := h.store.Users.Get(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	rotation := webhookRotation(user.WebhookToken, grace)
+	if err := h.store.Users.RotateWebhookToken(ctx, user.ID, rotation); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate webhook token"})
+		return
+	}
+	h.recordAudit(c, models.AuditWebhookRotate, "user", userID, gin.H{"grace_period_seconds": int(grace.Seconds())})
+
+	c.JSON(http.StatusOK, newWebhookInfo(rotation.Token, rotation.PreviousToken, rotation.PreviousExpiresAt))
+}
+
+// RotateProjectWebhookToken выдаёт проекту новый webhook токен
+// @Summary Ротация webhook токена проекта
+// @Description Выдаёт проекту новый webhook токен (editor и выше). Прежний продолжает работать в течение grace period
+// @Tags projects
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param request body RotateWebhookRequest false "Grace period"
+// @Success 200 {object} WebhookInfoResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /projects/{id}/webhook/rotate [post]
+func (h *APIHandler) RotateProjectWebhookToken(c *gin.Context) {
+	project, ok := h.findProject(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	grace, ok := bindGracePeriod(c)
+	if !ok {
+		return
+	}
+
+	rotation := webhookRotation(project.WebhookToken, grace)
+	if err := h.store.Projects.RotateWebhookToken(c.Request.Context(), project.ID, rotation); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate webhook token"})
+		return
+	}
+	h.recordAudit(c, models.AuditProjectWebhookRotate, "project", project.ID, gin.H{"grace_period_seconds": int(grace.Seconds())})
+
+	c.JSON(http.StatusOK, newWebhookInfo(rotation.Token, rotation.PreviousToken, rotation.PreviousExpiresAt))
+}
+
+// bindGracePeriod читает grace period из необязательного тела запроса.
+// При ошибке пишет ответ и возвращает false
+func bindGracePeriod(c *gin.Context) (time.Duration, bool) {
+	var req RotateWebhookRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return 0, false
+		}
+	}
+
+	if req.GracePeriodSeconds == nil {
+		return webhookRotationGrace(), true
+	}
+
+	grace := time.Duration(*req.GracePeriodSeconds) * time.Second
+	if grace < 0 || grace > maxWebhookGracePeriod {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "grace_period_seconds must be between 0 and 2592000"})
+		return 0, false
+	}
+	return grace, true
+}
+
+// webhookRotationGrace — grace period по умолчанию (WEBHOOK_ROTATION_GRACE, 24 часа)
+func webhookRotationGrace() time.Duration {
+	if value, err := time.ParseDuration(os.Getenv("WEBHOOK_ROTATION_GRACE")); err == nil && value >= 0 && value <= maxWebhookGracePeriod {
+		return value
+	}
+	return 24 * time.Hour
+}
+
+// webhookRotation выдаёт новый webhook токен пользователя или проекта,
+// сохраняя текущий как прежний на время grace period
+func webhookRotation(currentToken string, grace time.Duration) store.WebhookRotation {
+	rotation := store.WebhookRotation{Token: generateToken()}
+	if grace > 0 {
+		expiresAt := time.Now().Add(grace)
+		rotation.PreviousToken = currentToken
+		rotation.PreviousExpiresAt = &expiresAt
+	}
+	return rotation
+}
+
+type WebhookSecretResponse struct {
+	GitHubSecret string `json:"github_secret"`
+}
+
+// RegenerateWebhookSecret выдаёт пользователю новый секрет подписи webhook
+// @Summary Новый секрет webhook
+// @Description Генерирует новый секрет для подписи webhook пользователя. Секрет показывается один раз, его нужно указать в настройках webhook GitHub
+// @Tags webhook
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} WebhookSecretResponse
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /webhook/secret [post]
+func (h *APIHandler) RegenerateWebhookSecret(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	secret := auth.GenerateWebhookSecret()
+
+	err := h.store.Settings.SetGitHubSecret(c.Request.Context(), userID, secret)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Settings not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook secret"})
+		return
+	}
+	h.recordAudit(c, models.AuditWebhookSecret, "user", userID, nil)
+
+	c.JSON(http.StatusOK, WebhookSecretResponse{GitHubSecret: secret})
+}
+
+// RegenerateProjectWebhookSecret выдаёт проекту новый секрет подписи webhook
+// @Summary Новый секрет webhook проекта
+// @Description Генерирует новый секрет для подписи webhook проекта (editor и выше). Секрет показывается один раз
+// @Tags projects
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Project ID"
+// @Success 200 {object} WebhookSecretResponse
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /projects/{id}/webhook/secret [post]
+func (h *APIHandler) RegenerateProjectWebhookSecret(c *gin.Context) {
+	project, ok := h.findProject(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	secret := auth.GenerateWebhookSecret()
+	if err := h.store.Projects.SetGitHubSecret(c.Request.Context(), project.ID, secret); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook secret"})
+		return
+	}
+	h.recordAudit(c, models.AuditProjectWebhookSecret, "project", project.ID, nil)
+
+	c.JSON(http.StatusOK, WebhookSecretResponse{GitHubSecret: secret})
+}
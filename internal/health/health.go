@@ -0,0 +1,173 @@
+package health
+
+import (
+	"commitcaster/internal/jobs"
+	"commitcaster/internal/logging"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Время на одну проверку: недоступная БД или провайдер не должны подвешивать health check
+const checkTimeout = 3 * time.Second
+
+// Check проверяет компонент; nil — компонент исправен
+type Check func(ctx context.Context) error
+
+type component struct {
+	name  string
+	check Check
+}
+
+// ComponentStatus — результат проверки компонента
+type ComponentStatus struct {
+	Status     string `json:"status"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Report — ответ /livez и /readyz
+type Report struct {
+	Status string                     `json:"status"`
+	Mode   string                     `json:"mode"`
+	Checks map[string]ComponentStatus `json:"checks"`
+}
+
+// Checker — проверки для /livez (процесс жив и не завис) и /readyz (сервис может
+// обрабатывать запросы: БД, миграции, провайдеры)
+type Checker struct {
+	mode  string
+	live  []component
+	ready []component
+}
+
+func New(mode string) *Checker {
+	return &Checker{mode: mode}
+}
+
+// Live добавляет проверку в /livez и /readyz
+func (h *Checker) Live(name string, check Check) {
+	h.live = append(h.live, component{name: name, check: check})
+	h.ready = append(h.ready, component{name: name, check: check})
+}
+
+// Ready добавляет проверку в /readyz
+func (h *Checker) Ready(name string, check Check) {
+	h.ready = append(h.ready, component{name: name, check: check})
+}
+
+// Livez отвечает 200, если все проверки живости прошли, иначе 503
+func (h *Checker) Livez(c *gin.Context) {
+	h.respond(c, h.live)
+}
+
+// Readyz отвечает 200, если все проверки готовности прошли, иначе 503
+func (h *Checker) Readyz(c *gin.Context) {
+	h.respond(c, h.ready)
+}
+
+func (h *Checker) respond(c *gin.Context, components []component) {
+	report := h.run(c.Request.Context(), components)
+
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
+
+// run выполняет проверки параллельно
+func (h *Checker) run(ctx context.Context, components []component) Report {
+	report := Report{Status: StatusOK, Mode: h.mode, Checks: make(map[string]ComponentStatus, len(components))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, comp := range components {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := runCheck(ctx, comp)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[comp.name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+func runCheck(ctx context.Context, comp component) ComponentStatus {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := comp.check(ctx)
+	result := ComponentStatus{Status: StatusOK, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		// Ответ публичный: ошибки провайдеров могут содержать токены
+		result.Status = StatusFail
+		result.Error = logging.Redact(err.Error())
+		slog.WarnContext(ctx, "Health check failed", "component", comp.name, "error", err)
+	}
+	return result
+}
+
+// Jobs проверяет, что фоновые задачи (генерация и отправка постов) не зависли
+func Jobs(tracker *jobs.Tracker, stuckAfter time.Duration) Check {
+	return func(ctx context.Context) error {
+		if oldest := tracker.Oldest(); oldest > stuckAfter {
+			return fmt.Errorf("job running for %s (%d running)", oldest.Round(time.Second), tracker.Running())
+		}
+		return nil
+	}
+}
+
+// Reachable проверяет доступность HTTP сервиса: подходит любой ответ
+func Reachable(url string) Check {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+}
+
+// Cached повторяет результат проверки в течение ttl, чтобы частые health check
+// не упирались в лимиты внешних API
+func Cached(check Check, ttl time.Duration) Check {
+	var mu sync.Mutex
+	var checkedAt time.Time
+	var lastErr error
+
+	return func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !checkedAt.IsZero() && time.Since(checkedAt) < ttl {
+			return lastErr
+		}
+		lastErr = check(ctx)
+		checkedAt = time.Now()
+		return lastErr
+	}
+}
@@ -0,0 +1,92 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// PendingFile хранит прерванные задачи до следующего запуска там, где нет БД
+// (single-user режим). Файл — JSON массив задач
+type PendingFile struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewPendingFile(path string) *PendingFile {
+	return &PendingFile{path: path}
+}
+
+// Path возвращает путь к файлу
+func (f *PendingFile) Path() string {
+	return f.path
+}
+
+// Add дописывает задачу в файл
+func (f *PendingFile) Add(job any) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to encode job: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	pending, err := f.read()
+	if err != nil {
+		return err
+	}
+	pending = append(pending, data)
+
+	content, err := json.Marshal(pending)
+	if err != nil {
+		return fmt.Errorf("failed to encode pending jobs: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create pending jobs directory: %w", err)
+	}
+
+	// Пишем через временный файл, чтобы не оставить обрезанный JSON
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o600); err != nil {
+		return fmt.Errorf("failed to write pending jobs: %w", err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		return fmt.Errorf("failed to write pending jobs: %w", err)
+	}
+	return nil
+}
+
+// Take возвращает сохранённые задачи и удаляет файл
+func (f *PendingFile) Take() ([]json.RawMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	pending, err := f.read()
+	if err != nil || len(pending) == 0 {
+		return nil, err
+	}
+	if err := os.Remove(f.path); err != nil {
+		return nil, fmt.Errorf("failed to remove pending jobs: %w", err)
+	}
+	return pending, nil
+}
+
+func (f *PendingFile) read() ([]json.RawMessage, error) {
+	content, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pending jobs: %w", err)
+	}
+
+	var pending []json.RawMessage
+	if err := json.Unmarshal(content, &pending); err != nil {
+		return nil, fmt.Errorf("failed to decode pending jobs %s: %w", f.path, err)
+	}
+	return pending, nil
+}
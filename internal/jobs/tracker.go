@@ -0,0 +1,124 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrShuttingDown — сервис останавливается: новые задачи не принимаются,
+// а незавершённые к дедлайну прерываются с этой причиной
+var ErrShuttingDown = errors.New("service is shutting down")
+
+// Сколько ждать прерванные задачи, пока они сохраняют состояние
+const interruptGrace = 5 * time.Second
+
+// Tracker запускает фоновые задачи (генерация и отправка постов, письма) и дожидается их при остановке
+type Tracker struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	closed  bool
+	next    uint64
+	cancels map[uint64]context.CancelCauseFunc
+	started map[uint64]time.Time
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{
+		cancels: make(map[uint64]context.CancelCauseFunc),
+		started: make(map[uint64]time.Time),
+	}
+}
+
+// Go запускает задачу в горутине. Контекст задачи сохраняет значения ctx (логи, трейс),
+// но не отменяется вместе с ним — только при остановке сервиса, если задача не успела
+// завершиться. После начала остановки возвращает ErrShuttingDown
+func (t *Tracker) Go(ctx context.Context, fn func(ctx context.Context)) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return ErrShuttingDown
+	}
+
+	ctx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+	id := t.next
+	t.next++
+	t.cancels[id] = cancel
+	t.started[id] = time.Now()
+	t.wg.Add(1)
+
+	go func() {
+		defer func() {
+			t.mu.Lock()
+			delete(t.cancels, id)
+			delete(t.started, id)
+			t.mu.Unlock()
+			cancel(nil)
+			t.wg.Done()
+		}()
+		fn(ctx)
+	}()
+	return nil
+}
+
+// Running возвращает число выполняемых задач
+func (t *Tracker) Running() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.cancels)
+}
+
+// Oldest возвращает время выполнения самой долгой из текущих задач
+func (t *Tracker) Oldest() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var oldest time.Duration
+	for _, started := range t.started {
+		oldest = max(oldest, time.Since(started))
+	}
+	return oldest
+}
+
+// Shutdown перестаёт принимать задачи и ждёт текущие до отмены ctx. Оставшиеся
+// задачи прерываются (Interrupted) и получают несколько секунд на сохранение состояния
+func (t *Tracker) Shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	t.closed = true
+	t.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	t.mu.Lock()
+	slog.Warn("Interrupting unfinished jobs", "count", len(t.cancels))
+	for _, cancel := range t.cancels {
+		cancel(ErrShuttingDown)
+	}
+	t.mu.Unlock()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(interruptGrace):
+		return fmt.Errorf("%d job(s) did not stop in time", t.Running())
+	}
+}
+
+// Interrupted сообщает, что задача прервана остановкой сервиса
+func Interrupted(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrShuttingDown)
+}
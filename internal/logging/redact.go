@@ -0,0 +1,95 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[redacted]"
+
+// Ключи атрибутов, значения которых не пишутся в лог
+var sensitiveKeys = map[string]bool{
+	"token":         true,
+	"password":      true,
+	"secret":        true,
+	"authorization": true,
+	"cookie":        true,
+	"api_key":       true,
+	"apikey":        true,
+}
+
+var sensitiveKeySuffixes = []string{"_token", "_secret", "_password", "_key"}
+
+// Шаблоны секретов в тексте сообщений и ошибок
+var sensitivePatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	// Токен Telegram бота, в том числе в URL api.telegram.org/bot<token>/
+	{regexp.MustCompile(`\d{5,}:[A-Za-z0-9_-]{30,}`), redacted},
+	{regexp.MustCompile(`(?i)(bearer\s+)[^\s"',]+`), "${1}" + redacted},
+	// JWT
+	{regexp.MustCompile(`eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]+`), redacted},
+	// Ключи API (OpenRouter, Groq, GitHub) и personal access tokens
+	{regexp.MustCompile(`\b(?:sk-or-v1-|sk-|gsk_|ghp_|gho_|ghu_|ghs_|ghr_|github_pat_|ccp_)[A-Za-z0-9_-]{8,}`), redacted},
+	{regexp.MustCompile(`(/webhook/github/)[^/\s?"']+`), "${1}" + redacted},
+	{regexp.MustCompile(`(?i)([?&](?:token|access_token|code|state|key|secret)=)[^&\s"']+`), "${1}" + redacted},
+}
+
+// Redact скрывает токены, секреты и ключи API в строке
+func Redact(s string) string {
+	for _, p := range sensitivePatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return s
+}
+
+// RedactURLError скрывает секреты в URL ошибки net/http (токен бота в пути и т.п.)
+func RedactURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = Redact(urlErr.URL)
+	}
+	return err
+}
+
+// IsSensitiveKey сообщает, что значение атрибута с таким ключом — секрет
+func IsSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	if sensitiveKeys[key] {
+		return true
+	}
+	for _, suffix := range sensitiveKeySuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactAttr — ReplaceAttr для slog: секреты по ключу атрибута и по шаблону значения
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey || a.Key == slog.SourceKey) {
+		return a
+	}
+	if IsSensitiveKey(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Redact(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			return slog.String(a.Key, Redact(v.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, Redact(v.String()))
+		}
+	}
+	return a
+}
@@ -0,0 +1,207 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "commitcaster"
+
+// Режимы работы для метки mode
+const (
+	ModeSaaS   = "saas"
+	ModeSingle = "single"
+)
+
+// Результаты обработки webhook
+const (
+	WebhookAccepted = "accepted"
+	WebhookIgnored  = "ignored"
+	WebhookRejected = "rejected"
+)
+
+// Провайдер AI для меток метрик
+const ProviderOpenRouter = "openrouter"
+
+// ModelOther — метка model для моделей не из списка известных
+const ModelOther = "other"
+
+// knownModels — модели, которые попадают в метку model как есть. Модель задаёт
+// пользователь в настройках, поэтому остальные учитываются как ModelOther: иначе
+// каждое новое значение создаёт новые временные ряды. METRICS_AI_MODELS дополняет список
+var knownModels = map[string]bool{
+	"meta-llama/llama-3.3-70b-instruct": true,
+	"llama-3.3-70b-versatile":           true,
+}
+
+var (
+	WebhooksTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhooks_total",
+		Help:      "GitHub webhooks by result (accepted, ignored, rejected) and reason.",
+	}, []string{"result", "reason"})
+
+	DeliveriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "deliveries_total",
+		Help:      "Finished webhook deliveries by final status.",
+	}, []string{"status"})
+
+	PipelineQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pipeline_queue_depth",
+		Help:      "Accepted deliveries waiting for or in post generation.",
+	})
+
+	PipelineDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "pipeline_duration_seconds",
+		Help:      "Time from accepting a delivery to its final status.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+	}, []string{"status"})
+
+	AIRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "ai_request_duration_seconds",
+		Help:      "AI completion request latency.",
+		Buckets:   []float64{0.25, 0.5, 1, 2, 4, 8, 15, 30, 60},
+	}, []string{"provider", "model"})
+
+	AIErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ai_errors_total",
+		Help:      "Failed AI requests by reason (network, http_<status>, decode, empty_response, config).",
+	}, []string{"provider", "model", "reason"})
+
+	AITokensTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ai_tokens_total",
+		Help:      "AI tokens reported by the provider, by type (prompt, completion).",
+	}, []string{"provider", "model", "type"})
+
+	TelegramSendDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "telegram_send_duration_seconds",
+		Help:      "Telegram sendMessage latency by HTTP status (network_error if no response).",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10},
+	}, []string{"status"})
+
+	TelegramErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "telegram_errors_total",
+		Help:      "Failed Telegram sends by HTTP status (network_error if no response).",
+	}, []string{"status"})
+
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Database query latency by operation.",
+		Buckets:   []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+	}, []string{"operation"})
+
+	DBErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_errors_total",
+		Help:      "Failed database queries by operation (record not found is not an error).",
+	}, []string{"operation"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route template and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	RateLimitRejectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Requests rejected by rate limiters.",
+	}, []string{"limiter"})
+)
+
+// Init регистрирует метрики с меткой mode (saas или single)
+func Init(mode string) {
+	for _, model := range strings.Split(os.Getenv("METRICS_AI_MODELS"), ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			knownModels[model] = true
+		}
+	}
+
+	registerer := prometheus.WrapRegistererWith(prometheus.Labels{"mode": mode}, prometheus.DefaultRegisterer)
+	registerer.MustRegister(
+		WebhooksTotal,
+		DeliveriesTotal,
+		PipelineQueueDepth,
+		PipelineDuration,
+		AIRequestDuration,
+		AIErrorsTotal,
+		AITokensTotal,
+		TelegramSendDuration,
+		TelegramErrorsTotal,
+		DBQueryDuration,
+		DBErrorsTotal,
+		HTTPRequestDuration,
+		RateLimitRejectionsTotal,
+	)
+}
+
+// Webhook учитывает результат обработки webhook
+func Webhook(result, reason string) {
+	WebhooksTotal.WithLabelValues(result, reason).Inc()
+}
+
+// Delivery учитывает итоговый статус доставки и время её обработки
+func Delivery(status string, started time.Time) {
+	DeliveriesTotal.WithLabelValues(status).Inc()
+	PipelineDuration.WithLabelValues(status).Observe(time.Since(started).Seconds())
+}
+
+// Model возвращает значение метки model: известную модель или ModelOther
+func Model(model string) string {
+	if knownModels[model] {
+		return model
+	}
+	return ModelOther
+}
+
+// Handler отдаёт метрики в формате Prometheus. Если задан METRICS_TOKEN,
+// требуется заголовок "Authorization: Bearer <METRICS_TOKEN>"
+func Handler() gin.HandlerFunc {
+	handler := promhttp.Handler()
+	token := os.Getenv("METRICS_TOKEN")
+
+	return func(c *gin.Context) {
+		if token != "" {
+			expected := "Bearer " + token
+			if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte(expected)) != 1 {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid metrics token"})
+				return
+			}
+		}
+		handler.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// Middleware измеряет время HTTP запросов. В метку route попадает шаблон
+// маршрута (/webhook/github/:token), а не путь с токенами
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		HTTPRequestDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
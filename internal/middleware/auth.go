@@ -2,14 +2,24 @@ package middleware
 
 import (
 	"commitcaster/internal/auth"
+	"commitcaster/internal/models"
+	"commitcaster/internal/store"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware проверяет JWT токен
-func AuthMiddleware() gin.HandlerFunc {
+// Способы аутентификации запроса (ключ "auth_type" в контексте)
+const (
+	AuthTypeSession = "session"
+	AuthTypeToken   = "token"
+)
+
+// AuthMiddleware проверяет JWT токен (и что его сессия не отозвана)
+// или personal access токен
+func AuthMiddleware(st *store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -26,6 +36,11 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		if strings.HasPrefix(parts[1], models.PersonalTokenPrefix) {
+			authenticatePersonalToken(c, st, parts[1])
+			return
+		}
+
 		claims, err := auth.ValidateToken(parts[1])
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
@@ -33,9 +48,131 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Токен действителен, только пока его сессия не отозвана
+		session, err := st.Sessions.Get(c.Request.Context(), claims.SessionID)
+		if err != nil || session.UserID != claims.UserID || !session.IsActive() {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token revoked"})
+			c.Abort()
+			return
+		}
+
 		// Сохраняем данные пользователя в контексте
 		c.Set("user_id", claims.UserID)
 		c.Set("email", claims.Email)
+		c.Set("session_id", claims.SessionID)
+		c.Set("auth_type", AuthTypeSession)
+		if session.ImpersonatorID != nil {
+			c.Set("impersonator_id", *session.ImpersonatorID)
+		}
+
+		c.Next()
+	}
+}
+
+// authenticatePersonalToken проверяет personal access токен и отмечает его использование
+func authenticatePersonalToken(c *gin.Context, st *store.Store, token string) {
+	ctx := c.Request.Context()
+
+	pat, err := st.Tokens.GetByHash(ctx, auth.HashToken(token))
+	if err != nil || !pat.IsActive() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		c.Abort()
+		return
+	}
+
+	user, err := st.Users.Get(ctx, pat.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		c.Abort()
+		return
+	}
+	if user.IsDeactivated() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account deactivated"})
+		c.Abort()
+		return
+	}
+
+	st.Tokens.Touch(ctx, pat.ID, time.Now())
+
+	c.Set("user_id", user.ID)
+	c.Set("email", user.Email)
+	c.Set("token_id", pat.ID)
+	c.Set("scopes", pat.ScopeList())
+	c.Set("auth_type", AuthTypeToken)
+
+	c.Next()
+}
+
+// RequireScope пропускает запросы с JWT сессией и personal access токены с нужной областью доступа
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("auth_type") == AuthTypeToken {
+			allowed := false
+			for _, s := range c.GetStringSlice("scopes") {
+				if s == scope {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Token lacks required scope: " + scope})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// RequireSession пропускает только запросы с JWT сессией (не personal access токены)
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("auth_type") != AuthTypeSession {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint requires a login session"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireOwnSession пропускает только сессии, открытые самим пользователем:
+// в режиме impersonation нельзя менять учётные данные и удалять аккаунт
+func RequireOwnSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, impersonated := c.Get("impersonator_id"); impersonated {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed while impersonating"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireAdmin пропускает только администраторов сервиса, вошедших сами
+// (не через personal access токен и не в режиме impersonation)
+func RequireAdmin(st *store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("auth_type") != AuthTypeSession {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint requires a login session"})
+			c.Abort()
+			return
+		}
+		if _, impersonated := c.Get("impersonator_id"); impersonated {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+
+		user, err := st.Users.Get(c.Request.Context(), c.MustGet("user_id").(uint))
+		if err != nil || !user.IsAdmin() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"bytes"
+	"commitcaster/internal/metrics"
+	"commitcaster/internal/ratelimit"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeyFunc возвращает ключ корзины для запроса; пустой ключ — запрос не ограничивается
+type KeyFunc func(c *gin.Context) string
+
+// RateLimit ограничивает частоту запросов по ключу. name разделяет корзины
+// разных правил с одинаковыми ключами
+func RateLimit(limiter ratelimit.Limiter, name string, rule ratelimit.Rule, key KeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !rule.Enabled() {
+			c.Next()
+			return
+		}
+
+		if !Allow(c, limiter, name, rule, key(c)) {
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Allow списывает запрос из корзины key правила name. Если лимит исчерпан, отвечает 429
+// и возвращает false. Нужен, когда ключ известен только обработчику (например, проект
+// webhook после проверки токена); пустой ключ не ограничивается
+func Allow(c *gin.Context, limiter ratelimit.Limiter, name string, rule ratelimit.Rule, key string) bool {
+	if !rule.Enabled() || key == "" {
+		return true
+	}
+
+	if ok, retryAfter := limiter.Allow(name+":"+key, rule); !ok {
+		metrics.RateLimitRejectionsTotal.WithLabelValues(name).Inc()
+		TooManyRequests(c, "Too many requests", retryAfter)
+		return false
+	}
+	return true
+}
+
+// TooManyRequests прерывает запрос ответом 429 с заголовком Retry-After
+func TooManyRequests(c *gin.Context, message string, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"error":       message,
+		"retry_after": seconds,
+	})
+}
+
+// ByIP — ключ по IP клиента
+func ByIP(c *gin.Context) string {
+	return c.ClientIP()
+}
+
+// ByJSONField — ключ по строковому полю JSON тела (например, email).
+// Тело восстанавливается для обработчика
+func ByJSONField(field string) KeyFunc {
+	return func(c *gin.Context) string {
+		if c.Request.Body == nil {
+			return ""
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+		if err != nil {
+			return ""
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		var fields map[string]interface{}
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return ""
+		}
+
+		value, _ := fields[field].(string)
+		return strings.ToLower(strings.TrimSpace(value))
+	}
+}
@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// Действия журнала аудита
+const (
+	AuditLogin           = "auth.login"
+	AuditLoginFailed     = "auth.login_failed"
+	AuditLoginLocked     = "auth.lockout"
+	AuditLogout          = "auth.logout"
+	AuditLogoutAll       = "auth.logout_all"
+	AuditPasswordReset   = "auth.password_reset"
+	AuditTwoFactorEnable = "auth.2fa.enable"
+	AuditTwoFactorOff    = "auth.2fa.disable"
+	AuditRecoveryCodes   = "auth.2fa.recovery_codes"
+
+	AuditTokenCreate = "token.create"
+	AuditTokenRevoke = "token.revoke"
+
+	AuditSettingsUpdate = "settings.update"
+	AuditWebhookRotate  = "webhook.rotate"
+	AuditWebhookSecret  = "webhook.secret"
+
+	AuditProjectCreate        = "project.create"
+	AuditProjectUpdate        = "project.update"
+	AuditProjectDelete        = "project.delete"
+	AuditProjectWebhookRotate = "project.webhook.rotate"
+	AuditProjectWebhookSecret = "project.webhook.secret"
+
+	AuditMemberAdd    = "organization.member.add"
+	AuditMemberRole   = "organization.member.role"
+	AuditMemberRemove = "organization.member.remove"
+
+	AuditAdminRoleChange   = "admin.user.role"
+	AuditAdminPlanChange   = "admin.user.plan"
+	AuditAdminDeactivate   = "admin.user.deactivate"
+	AuditAdminReactivate   = "admin.user.reactivate"
+	AuditAdminUnlock       = "admin.user.unlock"
+	AuditAdminImpersonate  = "admin.user.impersonate"
+	AuditAdminRotateTokens = "admin.user.rotate_tokens"
+	AuditAccountExport     = "account.export"
+	AuditAccountDelete     = "account.delete"
+)
+
+// AuditLog — запись журнала действий, важных для безопасности.
+// Записи только добавляются и не изменяются (кроме обезличивания при удалении аккаунта)
+type AuditLog struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+
+	// Кто выполнил действие (nil — система или анонимный запрос)
+	ActorID *uint  `gorm:"index" json:"actor_id"`
+	Action  string `gorm:"index;not null" json:"action"`
+
+	// Объект действия, например "user" и его ID
+	TargetType string `json:"target_type"`
+	TargetID   *uint  `gorm:"index" json:"target_id"`
+
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+
+	// Дополнительные сведения в JSON
+	Details string `gorm:"type:text" json:"details,omitempty"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// Статусы обработки webhook
+const (
+	DeliveryProcessing = "processing"
+	DeliverySucceeded  = "succeeded"
+	DeliveryFailed     = "failed"
+	// Квота тарифа исчерпана, пост не генерировался
+	DeliveryQuotaExceeded = "quota_exceeded"
+	// Обработка прервана остановкой сервиса и продолжится после запуска
+	DeliveryInterrupted = "interrupted"
+)
+
+// Статусы отправки поста в канал
+const (
+	PostSent   = "sent"
+	PostFailed = "failed"
+)
+
+// Delivery — обработка одного push события, принятого webhook
+type Delivery struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID uint `gorm:"index;not null" json:"user_id"`
+	// Пусто для webhook пользователя (проект по умолчанию)
+	ProjectID *uint `gorm:"index" json:"project_id"`
+
+	Repository  string `json:"repository"`
+	Ref         string `json:"ref"`
+	CommitCount int    `json:"commit_count"`
+
+	Status string `gorm:"index;not null" json:"status"`
+	Error  string `json:"error,omitempty"`
+
+	// Payload webhook для продолжения прерванной обработки (только для interrupted)
+	Payload string `gorm:"type:text" json:"-"`
+	// Post — сгенерированный пост прерванной доставки: при продолжении AI не вызывается
+	// повторно, даже если пост ещё не отправлен ни в один канал
+	Post string `gorm:"type:text" json:"-"`
+
+	Posts []Post `gorm:"constraint:OnDelete:CASCADE;" json:"posts,omitempty"`
+}
+
+// Post — сгенерированный пост и результат его отправки в канал
+type Post struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	DeliveryID uint `gorm:"index;not null" json:"delivery_id"`
+	UserID     uint `gorm:"index;not null" json:"user_id"`
+
+	TelegramChannelID string `json:"telegram_channel_id"`
+	Content           string `gorm:"type:text" json:"content"`
+
+	Status string `gorm:"not null" json:"status"`
+	Error  string `json:"error,omitempty"`
+}
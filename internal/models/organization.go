@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Роли участников организации, от старшей к младшей
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+var roleRanks = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+	RoleOwner:  4,
+}
+
+// Organization объединяет пользователей для совместного управления проектами
+type Organization struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name string `gorm:"not null" json:"name"`
+
+	Members []Membership `gorm:"constraint:OnDelete:CASCADE;" json:"members,omitempty"`
+}
+
+// Membership — участие пользователя в организации с ролью
+type Membership struct {
+	ID             uint   `gorm:"primarykey" json:"id"`
+	OrganizationID uint   `gorm:"uniqueIndex:idx_membership_org_user;not null" json:"organization_id"`
+	UserID         uint   `gorm:"uniqueIndex:idx_membership_org_user;index;not null" json:"user_id"`
+	Role           string `gorm:"not null" json:"role"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ValidRole проверяет, что роль существует
+func ValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// RoleAtLeast проверяет, что роль не ниже требуемой
+func RoleAtLeast(role, required string) bool {
+	return roleRanks[role] >= roleRanks[required]
+}
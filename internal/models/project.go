@@ -0,0 +1,138 @@
+package models
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Project — отдельный источник коммитов пользователя со своим webhook,
+// каналами публикации, промптом и фильтрами
+type Project struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Владелец проекта: его API ключ AI и токен бота используются по умолчанию
+	UserID uint   `gorm:"index;not null" json:"user_id"`
+	Name   string `gorm:"not null" json:"name"`
+
+	// Проект организации доступен её участникам согласно их ролям
+	OrganizationID *uint `gorm:"index" json:"organization_id"`
+
+	// Webhook URL уникален для каждого проекта
+	WebhookToken string `gorm:"uniqueIndex;not null" json:"webhook_token"`
+	GitHubSecret string `json:"github_secret"`
+
+	// Прежний токен после ротации действует до PreviousWebhookTokenExpiresAt
+	PreviousWebhookToken          string     `gorm:"index" json:"-"`
+	PreviousWebhookTokenExpiresAt *time.Time `json:"-"`
+
+	IsActive bool `json:"is_active"`
+
+	// Пустые значения берутся из UserSettings
+	PostLanguage string `json:"post_language"`
+	MaxCommits   int    `json:"max_commits"`
+	CustomPrompt string `gorm:"type:text" json:"custom_prompt,omitempty"`
+
+	// Фильтры: ветки через запятую (поддерживаются glob-шаблоны, например "main,release/*")
+	// и подстрока в сообщении коммита, при которой коммит не публикуется
+	BranchFilter string `json:"branch_filter"`
+	SkipPattern  string `json:"skip_pattern"`
+
+	// Каналы, в которые публикуется пост
+	Destinations []Destination `gorm:"constraint:OnDelete:CASCADE;" json:"destinations"`
+
+	// Правила выбора канала, промпта и языка по репозиторию и ветке
+	RoutingRules []RoutingRule `gorm:"constraint:OnDelete:CASCADE;" json:"routing_rules"`
+}
+
+// Destination — Telegram канал, в который публикуются посты проекта
+type Destination struct {
+	ID        uint `gorm:"primarykey" json:"id"`
+	ProjectID uint `gorm:"index;not null" json:"project_id"`
+
+	Name string `json:"name"`
+
+	// Если токен бота пустой, используется токен из UserSettings
+	TelegramBotToken  string `json:"telegram_bot_token"`
+	TelegramChannelID string `gorm:"not null" json:"telegram_channel_id"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// MatchesRef проверяет, проходит ли ref (refs/heads/<branch>) фильтр веток
+func (p *Project) MatchesRef(ref string) bool {
+	if strings.TrimSpace(p.BranchFilter) == "" {
+		return true
+	}
+
+	branch := strings.TrimPrefix(ref, "refs/heads/")
+	for _, pattern := range strings.Split(p.BranchFilter, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if ok, _ := path.Match(pattern, branch); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterCommits отбрасывает коммиты, сообщение которых содержит SkipPattern
+func (p *Project) FilterCommits(commits []Commit) []Commit {
+	if p.SkipPattern == "" {
+		return commits
+	}
+
+	filtered := make([]Commit, 0, len(commits))
+	for _, commit := range commits {
+		if !strings.Contains(commit.Message, p.SkipPattern) {
+			filtered = append(filtered, commit)
+		}
+	}
+	return filtered
+}
+
+// IsReady проверяет, что проекту хватает настроек для публикации:
+// есть API ключ AI, хотя бы один канал и токен бота для каждого канала
+func (p *Project) IsReady(s UserSettings) bool {
+	if !p.IsActive || s.GroqAPIKey == "" || len(p.Destinations) == 0 {
+		return false
+	}
+	for _, d := range p.Destinations {
+		if d.TelegramBotToken == "" && s.TelegramBotToken == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// ForProject возвращает копию настроек с переопределениями проекта
+func (s UserSettings) ForProject(p *Project) UserSettings {
+	if p.PostLanguage != "" {
+		s.PostLanguage = p.PostLanguage
+	}
+	if p.MaxCommits > 0 {
+		s.MaxCommits = p.MaxCommits
+	}
+	if p.CustomPrompt != "" {
+		s.CustomPrompt = p.CustomPrompt
+	}
+	s.GitHubSecret = p.GitHubSecret
+	return s
+}
+
+// ForDestination возвращает копию настроек для отправки в конкретный канал
+func (s UserSettings) ForDestination(d Destination) UserSettings {
+	if d.TelegramBotToken != "" {
+		s.TelegramBotToken = d.TelegramBotToken
+	}
+	s.TelegramChannelID = d.TelegramChannelID
+	return s
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// RateLimitBucket — состояние корзины rate limiter при RATE_LIMIT_STORE=postgres
+type RateLimitBucket struct {
+	Key       string  `gorm:"primaryKey"`
+	Tokens    float64 `gorm:"not null"`
+	Allowed   bool    `gorm:"not null"`
+	UpdatedAt time.Time
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// RecoveryCode — одноразовый код восстановления для входа без приложения-аутентификатора
+type RecoveryCode struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+
+	UserID   uint   `gorm:"index;not null"`
+	CodeHash string `gorm:"not null"`
+	UsedAt   *time.Time
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+// RoutingRule выбирает канал, промпт и язык для push-события внутри проекта.
+// Правила проверяются по порядку Position, применяется первое совпавшее
+type RoutingRule struct {
+	ID        uint `gorm:"primarykey" json:"id"`
+	ProjectID uint `gorm:"index;not null" json:"project_id"`
+	Position  int  `gorm:"not null;default:0" json:"position"`
+
+	// Glob-шаблоны: "acme/api", "acme/*"; для ref — имя ветки или полный ref
+	// ("main", "release/*", "refs/tags/*"). Пустой шаблон совпадает со всем
+	RepositoryPattern string `json:"repository_pattern"`
+	RefPattern        string `json:"ref_pattern"`
+
+	// Имя канала проекта (Destination.Name); пусто — все каналы проекта
+	Destination string `json:"destination"`
+
+	// Пустые значения берутся из проекта
+	PostLanguage string `json:"post_language"`
+	CustomPrompt string `gorm:"type:text" json:"custom_prompt,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Matches проверяет, подходит ли правило для репозитория и ref
+func (r *RoutingRule) Matches(repoFullName, ref string) bool {
+	if r.RepositoryPattern != "" {
+		if ok, _ := path.Match(r.RepositoryPattern, repoFullName); !ok {
+			return false
+		}
+	}
+
+	if r.RefPattern != "" {
+		branch := strings.TrimPrefix(ref, "refs/heads/")
+		matchRef, _ := path.Match(r.RefPattern, ref)
+		matchBranch, _ := path.Match(r.RefPattern, branch)
+		if !matchRef && !matchBranch {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MatchRule возвращает первое подходящее правило маршрутизации или nil
+func (p *Project) MatchRule(repoFullName, ref string) *RoutingRule {
+	for i := range p.RoutingRules {
+		if p.RoutingRules[i].Matches(repoFullName, ref) {
+			return &p.RoutingRules[i]
+		}
+	}
+	return nil
+}
+
+// DestinationsFor возвращает каналы для публикации с учётом правила
+func (p *Project) DestinationsFor(rule *RoutingRule) []Destination {
+	if rule == nil || rule.Destination == "" {
+		return p.Destinations
+	}
+
+	var destinations []Destination
+	for _, d := range p.Destinations {
+		if d.Name == rule.Destination {
+			destinations = append(destinations, d)
+		}
+	}
+	return destinations
+}
+
+// ForRule возвращает копию настроек с переопределениями правила маршрутизации
+func (s UserSettings) ForRule(rule *RoutingRule) UserSettings {
+	if rule == nil {
+		return s
+	}
+	if rule.PostLanguage != "" {
+		s.PostLanguage = rule.PostLanguage
+	}
+	if rule.CustomPrompt != "" {
+		s.CustomPrompt = rule.CustomPrompt
+	}
+	return s
+}
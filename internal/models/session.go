@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Session — сессия входа пользователя. Access токены содержат ID сессии,
+// поэтому отзыв сессии сразу делает их недействительными
+type Session struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	UserAgent string     `json:"user_agent"`
+	IP        string     `json:"ip"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+
+	// Администратор, открывший сессию от имени пользователя (поддержка)
+	ImpersonatorID *uint `gorm:"index" json:"impersonator_id,omitempty"`
+
+	RefreshTokens []RefreshToken `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+}
+
+// RefreshToken — одноразовый refresh токен сессии. Хранится только хеш;
+// при обновлении токен помечается использованным и выдаётся новый
+type RefreshToken struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+
+	SessionID uint      `gorm:"index;not null"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+}
+
+// IsActive проверяет, что сессия не отозвана и не истекла
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// PersonalTokenPrefix отличает personal access токены от JWT в заголовке Authorization
+const PersonalTokenPrefix = "ccp_"
+
+// Области доступа personal access токенов
+const (
+	ScopeSettingsRead       = "settings:read"
+	ScopeSettingsWrite      = "settings:write"
+	ScopeProjectsRead       = "projects:read"
+	ScopeProjectsWrite      = "projects:write"
+	ScopeOrganizationsRead  = "organizations:read"
+	ScopeOrganizationsWrite = "organizations:write"
+	ScopeWebhookRead        = "webhook:read"
+	ScopeWebhookWrite       = "webhook:write"
+	ScopeUsageRead          = "usage:read"
+)
+
+// Scopes — все доступные области доступа
+var Scopes = []string{
+	ScopeSettingsRead,
+	ScopeSettingsWrite,
+	ScopeProjectsRead,
+	ScopeProjectsWrite,
+	ScopeOrganizationsRead,
+	ScopeOrganizationsWrite,
+	ScopeWebhookRead,
+	ScopeWebhookWrite,
+	ScopeUsageRead,
+}
+
+// PersonalAccessToken — токен для автоматизации (CI, скрипты) с ограниченными
+// областями доступа. Хранится только хеш, сам токен показывается один раз
+type PersonalAccessToken struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID    uint   `gorm:"index;not null" json:"user_id"`
+	Name      string `gorm:"not null" json:"name"`
+	TokenHash string `gorm:"uniqueIndex;not null" json:"-"`
+	// Начало токена для отображения в списке ("ccp_1a2b3c4d")
+	Prefix string `json:"prefix"`
+	// Области доступа через запятую
+	Scopes string `gorm:"not null" json:"scopes"`
+
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// IsActive проверяет, что токен не отозван и не истёк
+func (t *PersonalAccessToken) IsActive() bool {
+	if t.RevokedAt != nil {
+		return false
+	}
+	return t.ExpiresAt == nil || time.Now().Before(*t.ExpiresAt)
+}
+
+// ScopeList возвращает области доступа токена списком
+func (t *PersonalAccessToken) ScopeList() []string {
+	var scopes []string
+	for _, scope := range strings.Split(t.Scopes, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// ValidScope проверяет, что область доступа существует
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
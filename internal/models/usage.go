@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Периоды счётчиков использования
+const (
+	UsagePeriodDay   = "day"
+	UsagePeriodMonth = "month"
+)
+
+// UsageCounts — показатели использования за период
+type UsageCounts struct {
+	Posts            int64 `gorm:"column:posts;not null;default:0" json:"posts"`
+	AIRequests       int64 `gorm:"column:ai_requests;not null;default:0" json:"ai_requests"`
+	PromptTokens     int64 `gorm:"column:prompt_tokens;not null;default:0" json:"prompt_tokens"`
+	CompletionTokens int64 `gorm:"column:completion_tokens;not null;default:0" json:"completion_tokens"`
+	TelegramSends    int64 `gorm:"column:telegram_sends;not null;default:0" json:"telegram_sends"`
+}
+
+// Tokens возвращает общее число токенов AI
+func (c UsageCounts) Tokens() int64 {
+	return c.PromptTokens + c.CompletionTokens
+}
+
+// UsageCounter — счётчики пользователя за день или месяц (UTC)
+type UsageCounter struct {
+	ID     uint   `gorm:"primarykey" json:"-"`
+	UserID uint   `gorm:"uniqueIndex:idx_usage_user_period;not null" json:"-"`
+	Period string `gorm:"uniqueIndex:idx_usage_user_period;not null" json:"period"`
+	// Начало периода: полночь дня или первое число месяца
+	PeriodStart time.Time `gorm:"uniqueIndex:idx_usage_user_period;not null" json:"period_start"`
+
+	UsageCounts `gorm:"embedded"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -7,6 +7,12 @@ import (
 	"gorm.io/gorm"
 )
 
+// Роли пользователя в сервисе (не путать с ролями в организации)
+const (
+	UserRoleUser  = "user"
+	UserRoleAdmin = "admin"
+)
+
 // User представляет пользователя сервиса
 type User struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
@@ -18,9 +24,40 @@ type User struct {
 	PasswordHash string `gorm:"not null" json:"-"`
 	Name         string `json:"name"`
 
+	// Роль в сервисе: user или admin (доступ к /api/admin)
+	Role string `gorm:"not null;default:user" json:"role"`
+
+	// Тариф с квотами использования; пусто — тариф по умолчанию (USAGE_DEFAULT_PLAN)
+	Plan string `json:"plan,omitempty"`
+
+	// Время деактивации администратором; nil — аккаунт активен
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
+
+	// Время подтверждения email; nil — адрес не подтверждён
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+
+	// Привязанный аккаунт GitHub (вход через OAuth)
+	GitHubID    *int64 `gorm:"column:github_id;uniqueIndex" json:"github_id,omitempty"`
+	GitHubLogin string `gorm:"column:github_login" json:"github_login,omitempty"`
+
+	// Двухфакторная аутентификация (TOTP). Секрет задаётся при подключении
+	// и начинает действовать после подтверждения кодом
+	TOTPSecret   string `gorm:"column:totp_secret" json:"-"`
+	TOTPEnabled  bool   `gorm:"column:totp_enabled" json:"totp_enabled"`
+	TOTPLastStep int64  `gorm:"column:totp_last_step" json:"-"`
+
+	// Защита от перебора: число неудачных попыток входа подряд
+	// и время, до которого вход заблокирован
+	FailedLogins int        `gorm:"not null;default:0" json:"-"`
+	LockedUntil  *time.Time `json:"-"`
+
 	// Webhook URL уникален для каждого пользователя
 	WebhookToken string `gorm:"uniqueIndex;not null" json:"webhook_token"`
 
+	// Прежний токен после ротации действует до PreviousWebhookTokenExpiresAt
+	PreviousWebhookToken          string     `gorm:"index" json:"-"`
+	PreviousWebhookTokenExpiresAt *time.Time `json:"-"`
+
 	// Связь с настройками
 	Settings UserSettings `gorm:"constraint:OnDelete:CASCADE;" json:"settings"`
 }
@@ -61,6 +98,21 @@ func (u *User) SetPassword(password string) error {
 	return nil
 }
 
+// IsAdmin проверяет, что пользователь — администратор сервиса
+func (u *User) IsAdmin() bool {
+	return u.Role == UserRoleAdmin
+}
+
+// IsDeactivated проверяет, что аккаунт отключён администратором
+func (u *User) IsDeactivated() bool {
+	return u.DeactivatedAt != nil
+}
+
+// IsLocked проверяет, заблокирован ли вход после неудачных попыток
+func (u *User) IsLocked(now time.Time) bool {
+	return u.LockedUntil != nil && u.LockedUntil.After(now)
+}
+
 // CheckPassword проверяет пароль
 func (u *User) CheckPassword(password string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password))
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Назначения одноразовых токенов пользователя
+const (
+	TokenPurposeVerifyEmail   = "verify_email"
+	TokenPurposeResetPassword = "reset_password"
+)
+
+// UserToken — одноразовый токен с ограниченным сроком действия, отправляемый
+// на email (подтверждение адреса, сброс пароля). Хранится только хеш
+type UserToken struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+
+	UserID    uint      `gorm:"index;not null"`
+	Purpose   string    `gorm:"index;not null"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+}
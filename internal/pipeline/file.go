@@ -0,0 +1,140 @@
+package pipeline
+
+import (
+	"commitcaster/config"
+	"commitcaster/internal/models"
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrUnknownProject — в файле конфигурации нет проекта с таким токеном или именем
+var ErrUnknownProject = errors.New("unknown project")
+
+// FileProvider — провайдер single-user режима с проектами из файла конфигурации.
+// Reload перечитывает файл; при ошибке остаются прежние настройки
+type FileProvider struct {
+	path string
+
+	mu      sync.RWMutex
+	byToken map[string]Target
+	byName  map[string]Target
+}
+
+// NewFileProvider читает и проверяет файл конфигурации
+func NewFileProvider(path string) (*FileProvider, error) {
+	p := &FileProvider{path: path}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Path возвращает путь к файлу конфигурации
+func (p *FileProvider) Path() string {
+	return p.path
+}
+
+// Reload перечитывает файл конфигурации
+func (p *FileProvider) Reload() error {
+	file, err := config.LoadFile(p.path)
+	if err != nil {
+		return err
+	}
+
+	byToken := make(map[string]Target, len(file.Projects))
+	byName := make(map[string]Target, len(file.Projects))
+	for _, fp := range file.Projects {
+		target, err := fileTarget(file, fp)
+		if err != nil {
+			return err
+		}
+		byToken[fp.WebhookToken] = target
+		byName[fp.Name] = target
+	}
+
+	p.mu.Lock()
+	p.byToken, p.byName = byToken, byName
+	p.mu.Unlock()
+	return nil
+}
+
+// Projects возвращает число проектов
+func (p *FileProvider) Projects() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.byName)
+}
+
+func (p *FileProvider) Resolve(ctx context.Context, token string) (Target, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	target, ok := p.byToken[token]
+	if !ok {
+		return Target{}, ErrUnknownProject
+	}
+	return target, nil
+}
+
+func (p *FileProvider) Lookup(ctx context.Context, name string) (Target, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	target, ok := p.byName[name]
+	if !ok {
+		return Target{}, ErrUnknownProject
+	}
+	return target, nil
+}
+
+// fileTarget переводит проект файла конфигурации в проект и настройки
+func fileTarget(file *config.File, fp config.FileProject) (Target, error) {
+	target := Target{
+		Settings: models.UserSettings{
+			TelegramBotToken: file.TelegramBotToken,
+			GroqAPIKey:       file.GroqAPIKey,
+			AIModel:          file.AIModel,
+			PostLanguage:     file.PostLanguage,
+			MaxCommits:       file.MaxCommits,
+			CustomPrompt:     file.CustomPrompt,
+			IsActive:         true,
+		},
+		Project: models.Project{
+			Name:         fp.Name,
+			WebhookToken: fp.WebhookToken,
+			GitHubSecret: fp.GitHubSecret,
+			IsActive:     true,
+			PostLanguage: fp.PostLanguage,
+			MaxCommits:   fp.MaxCommits,
+			CustomPrompt: fp.CustomPrompt,
+			BranchFilter: fp.BranchFilter,
+			SkipPattern:  fp.SkipPattern,
+		},
+	}
+
+	for _, d := range fp.Destinations {
+		target.Project.Destinations = append(target.Project.Destinations, models.Destination{
+			Name:              d.Name,
+			TelegramBotToken:  d.TelegramBotToken,
+			TelegramChannelID: d.TelegramChannelID,
+		})
+	}
+	for i, r := range fp.RoutingRules {
+		target.Project.RoutingRules = append(target.Project.RoutingRules, models.RoutingRule{
+			Position:          i,
+			RepositoryPattern: r.Repository,
+			RefPattern:        r.Ref,
+			Destination:       r.Destination,
+			PostLanguage:      r.PostLanguage,
+			CustomPrompt:      r.CustomPrompt,
+		})
+	}
+
+	if fp.Schedule != nil {
+		window, err := fp.Schedule.Window()
+		if err != nil {
+			return target, err
+		}
+		target.Schedule = window
+	}
+	return target, nil
+}
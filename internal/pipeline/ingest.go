@@ -0,0 +1,116 @@
+package pipeline
+
+import (
+	"commitcaster/internal/auth"
+	"commitcaster/internal/metrics"
+	"commitcaster/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// Rejection — webhook отклонён или пропущен на одном из этапов приёма
+type Rejection struct {
+	// Status — HTTP статус ответа GitHub
+	Status int
+	// Result — metrics.WebhookRejected или metrics.WebhookIgnored
+	Result string
+	// Reason — причина для метрик
+	Reason  string
+	Message string
+}
+
+func (r *Rejection) Error() string {
+	return r.Message
+}
+
+// Ignored — событие пропущено, а не отклонено: GitHub получает 200
+func (r *Rejection) Ignored() bool {
+	return r.Result == metrics.WebhookIgnored
+}
+
+func reject(status int, reason, message string) *Rejection {
+	return &Rejection{Status: status, Result: metrics.WebhookRejected, Reason: reason, Message: message}
+}
+
+func ignore(reason string) *Rejection {
+	return &Rejection{Status: http.StatusOK, Result: metrics.WebhookIgnored, Reason: reason, Message: "Event ignored"}
+}
+
+// MaxBodySize — предельный размер тела webhook. Обработчики оборачивают тело в
+// http.MaxBytesReader с этим лимитом; push с тысячами коммитов укладывается в него с запасом
+const MaxBodySize = 5 << 20
+
+// Ingest принимает webhook проекта target: проверяет, что проект готов публиковать,
+// проверяет подпись, разбирает payload и применяет фильтры проекта.
+// allowUnsigned разрешает доставки без подписи, если секрет не задан
+func Ingest(ctx context.Context, target Target, header http.Header, body io.Reader, allowUnsigned bool) (models.GitHubWebhookPayload, *Rejection) {
+	var payload models.GitHubWebhookPayload
+	project := target.Project
+
+	// Аккаунт, отключённый администратором, не публикует посты
+	if target.Owner.IsDeactivated() {
+		return payload, reject(http.StatusForbidden, "deactivated", "Account deactivated")
+	}
+
+	// Проверяем что бот активен
+	if !project.IsReady(target.Settings) {
+		slog.InfoContext(ctx, "Bot is inactive", "user_id", project.UserID, "project", project.Name)
+		return payload, reject(http.StatusForbidden, "inactive", "Bot is not active. Please configure your tokens first")
+	}
+
+	// Читаем тело запроса
+	data, err := io.ReadAll(body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		slog.WarnContext(ctx, "Webhook body too large", "user_id", project.UserID, "project", project.Name, "limit", tooLarge.Limit)
+		return payload, reject(http.StatusRequestEntityTooLarge, "too_large", "Payload too large")
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Cannot read webhook body", "error", err)
+		return payload, reject(http.StatusBadRequest, "read_error", "Cannot read body")
+	}
+
+	// Проверяем подпись GitHub. Без секрета доставка принимается только с allowUnsigned:
+	// иначе любой, кто знает URL, может публиковать от имени пользователя
+	if project.GitHubSecret == "" {
+		if !allowUnsigned {
+			slog.WarnContext(ctx, "Webhook secret not configured", "user_id", project.UserID, "project", project.Name)
+			return payload, reject(http.StatusForbidden, "no_secret", "Webhook secret not configured. Set github_secret and use it in GitHub webhook settings")
+		}
+	} else if err := auth.VerifyGitHubSignature(data, project.GitHubSecret, header, auth.AllowSHA1Signatures()); err != nil {
+		slog.WarnContext(ctx, "Invalid webhook signature", "user_id", project.UserID, "project", project.Name, "error", err)
+		return payload, reject(http.StatusUnauthorized, "invalid_signature", "Invalid signature")
+	}
+
+	// Парсим payload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		slog.WarnContext(ctx, "Invalid webhook JSON", "error", err)
+		return payload, reject(http.StatusBadRequest, "invalid_json", "Invalid JSON")
+	}
+
+	// Проверяем, что это push event с коммитами
+	event := header.Get("X-GitHub-Event")
+	if event != "push" || len(payload.Commits) == 0 {
+		return payload, ignore(ignoreReason(event))
+	}
+
+	// Применяем фильтры проекта
+	payload.Commits = project.FilterCommits(payload.Commits)
+	if !project.MatchesRef(payload.Ref) || len(payload.Commits) == 0 {
+		return payload, ignore("filtered")
+	}
+
+	return payload, nil
+}
+
+// ignoreReason — причина пропуска события для метрик
+func ignoreReason(event string) string {
+	if event != "push" {
+		return "not_push"
+	}
+	return "no_commits"
+}
@@ -0,0 +1,97 @@
+package pipeline
+
+import (
+	"bytes"
+	"commitcaster/internal/auth"
+	"commitcaster/internal/models"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func readyTarget(secret string) Target {
+	return Target{
+		Project: models.Project{
+			Name:         "api",
+			IsActive:     true,
+			GitHubSecret: secret,
+			Destinations: []models.Destination{{TelegramChannelID: "@channel"}},
+		},
+		Settings: models.UserSettings{TelegramBotToken: "123:bot-token", GroqAPIKey: "ai-key"},
+	}
+}
+
+func pushHeader(body []byte, secret string) http.Header {
+	header := http.Header{"X-Github-Event": {"push"}}
+	if secret != "" {
+		header.Set(auth.SignatureHeader, auth.SignGitHubPayload(body, secret))
+	}
+	return header
+}
+
+func TestIngestBodyLimit(t *testing.T) {
+	push, err := json.Marshal(models.GitHubWebhookPayload{
+		Ref:     "refs/heads/main",
+		Commits: []models.Commit{{ID: "abc123", Message: "Fix bug"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Тело чуть больше лимита: валидный JSON с длинным полем
+	large := []byte(`{"ref":"` + strings.Repeat("a", MaxBodySize) + `"}`)
+
+	tests := []struct {
+		name   string
+		body   []byte
+		status int
+	}{
+		{"within limit", push, 0},
+		{"over limit", large, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := http.MaxBytesReader(httptest.NewRecorder(), io.NopCloser(bytes.NewReader(tt.body)), MaxBodySize)
+
+			_, rejection := Ingest(context.Background(), readyTarget("secret"), pushHeader(tt.body, "secret"), body, false)
+			switch {
+			case tt.status == 0 && rejection != nil:
+				t.Fatalf("rejected: %d %s", rejection.Status, rejection.Message)
+			case tt.status != 0 && (rejection == nil || rejection.Status != tt.status):
+				t.Fatalf("rejection = %+v, want status %d", rejection, tt.status)
+			}
+		})
+	}
+}
+
+func TestIngestSignature(t *testing.T) {
+	body := []byte(`{"ref":"refs/heads/main","commits":[{"id":"abc123","message":"Fix bug"}]}`)
+
+	tests := []struct {
+		name          string
+		secret        string
+		header        http.Header
+		allowUnsigned bool
+		status        int
+	}{
+		{"signed", "secret", pushHeader(body, "secret"), false, 0},
+		{"wrong secret", "secret", pushHeader(body, "other"), false, http.StatusUnauthorized},
+		{"unsigned with secret", "secret", pushHeader(body, ""), true, http.StatusUnauthorized},
+		{"no secret, unsigned allowed", "", pushHeader(body, ""), true, 0},
+		{"no secret, unsigned disabled", "", pushHeader(body, ""), false, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, rejection := Ingest(context.Background(), readyTarget(tt.secret), tt.header, bytes.NewReader(body), tt.allowUnsigned)
+			switch {
+			case tt.status == 0 && rejection != nil:
+				t.Fatalf("rejected: %d %s", rejection.Status, rejection.Message)
+			case tt.status != 0 && (rejection == nil || rejection.Status != tt.status):
+				t.Fatalf("rejection = %+v, want status %d", rejection, tt.status)
+			}
+		})
+	}
+}
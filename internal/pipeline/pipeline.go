@@ -0,0 +1,389 @@
+// Package pipeline — обработка push событий GitHub, общая для single-user и SaaS режимов:
+// приём (ingest) → проверка подписи (verify) → фильтры (filter) → сводка коммитов
+// (summarize) → генерация поста (generate) → форматирование (format) → публикация (publish).
+// Режимы отличаются только провайдером настроек (Provider) и журналом доставок (Journal)
+package pipeline
+
+import (
+	"commitcaster/config"
+	"commitcaster/internal/jobs"
+	"commitcaster/internal/metrics"
+	"commitcaster/internal/models"
+	"commitcaster/internal/services"
+	"commitcaster/internal/tracing"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// JobTimeout — сколько может длиться обработка одного push события: генерация
+// и отправка во все каналы. Обработка, не уложившаяся в срок, завершается ошибкой
+const JobTimeout = 5 * time.Minute
+
+// ErrJobTimeout — причина отмены обработки, превысившей JobTimeout
+var ErrJobTimeout = errors.New("delivery timed out")
+
+// Target — проект и настройки, по которым обрабатывается webhook
+type Target struct {
+	Project  models.Project
+	Settings models.UserSettings
+	// Owner — владелец проекта; в single-user режиме пустой
+	Owner models.User
+	// Schedule — окно публикации; nil — публикуем сразу
+	Schedule *config.Window
+}
+
+// LimitKey — ключ лимита доставок: проект или, для проекта по умолчанию, его владелец
+func (t Target) LimitKey() string {
+	if t.Project.ID != 0 {
+		return fmt.Sprintf("project:%d", t.Project.ID)
+	}
+	return fmt.Sprintf("user:%d", t.Owner.ID)
+}
+
+// Provider находит проект и настройки по webhook токену или имени проекта
+type Provider interface {
+	Resolve(ctx context.Context, token string) (Target, error)
+	Lookup(ctx context.Context, name string) (Target, error)
+}
+
+// Static — провайдер single-user режима без файла конфигурации: один проект
+// из переменных окружения для любого токена
+type Static Target
+
+// NewStatic создаёт провайдер с проектом по умолчанию на основе cfg
+func NewStatic(cfg *config.Config) Static {
+	settings := models.UserSettings{
+		TelegramBotToken:  cfg.TelegramBotToken,
+		TelegramChannelID: cfg.TelegramChannelID,
+		GroqAPIKey:        cfg.GroqAPIKey,
+		GitHubSecret:      cfg.GitHubSecret,
+		IsActive:          true,
+	}
+	return Static{Project: DefaultProject(models.User{}, settings), Settings: settings}
+}
+
+func (s Static) Resolve(ctx context.Context, token string) (Target, error) {
+	return Target(s), nil
+}
+
+func (s Static) Lookup(ctx context.Context, name string) (Target, error) {
+	return Target(s), nil
+}
+
+// DefaultProject — проект по умолчанию на основе UserSettings (webhook токен пользователя)
+func DefaultProject(user models.User, settings models.UserSettings) models.Project {
+	project := models.Project{
+		UserID:       user.ID,
+		Name:         "default",
+		WebhookToken: user.WebhookToken,
+		GitHubSecret: settings.GitHubSecret,
+		IsActive:     settings.IsActive,
+	}
+	if settings.TelegramChannelID != "" {
+		project.Destinations = []models.Destination{{TelegramChannelID: settings.TelegramChannelID}}
+	}
+	return project
+}
+
+// Job — обработка одного push события
+type Job struct {
+	Target
+	Payload models.GitHubWebhookPayload
+
+	// DeliveryID — запись доставки в БД (SaaS режим)
+	DeliveryID uint
+	Started    time.Time
+
+	// Состояние прерванной обработки: сгенерированный пост и каналы, куда он уже отправлен
+	Post string
+	Sent []string
+
+	// QuotaReserved — момент резервирования AI запроса (ReserveQuota): по нему
+	// ReleaseQuota освобождает счётчики того же дня и месяца
+	QuotaReserved time.Time
+}
+
+// Journal сохраняет состояние обработки: в SaaS режиме — доставки, посты и счётчики
+// использования в БД, в single-user — прерванные задачи в файле
+type Journal interface {
+	// ReserveQuota вызывается перед генерацией поста и резервирует AI запрос;
+	// ошибка завершает обработку со статусом quota_exceeded
+	ReserveQuota(ctx context.Context, job *Job) error
+	// ReleaseQuota освобождает резерв, если пост не сгенерирован
+	ReleaseQuota(ctx context.Context, job *Job)
+	RecordUsage(ctx context.Context, job *Job, counts models.UsageCounts)
+	RecordPost(ctx context.Context, job *Job, post models.Post)
+	// Finish сохраняет итоговый статус обработки
+	Finish(ctx context.Context, job *Job, status, errMsg string)
+	// Interrupt сохраняет обработку, прерванную остановкой сервиса, чтобы продолжить её после запуска
+	Interrupt(ctx context.Context, job *Job)
+}
+
+// Pipeline генерирует и публикует посты в фоне
+type Pipeline struct {
+	jobs    *jobs.Tracker
+	journal Journal
+
+	// Задачи, ждущие окна публикации
+	mu      sync.Mutex
+	held    map[*time.Timer]Job
+	stopped bool
+}
+
+func New(tracker *jobs.Tracker, journal Journal) *Pipeline {
+	return &Pipeline{jobs: tracker, journal: journal, held: make(map[*time.Timer]Job)}
+}
+
+// Start запускает обработку. Контекст запроса отменится вместе с ответом, поэтому
+// в обработку передаются только его атрибуты. Во время остановки сервиса обработка
+// сохраняется как прерванная и продолжается после запуска. Вне окна публикации
+// проекта обработка откладывается до его начала
+func (p *Pipeline) Start(ctx context.Context, job Job) {
+	if job.Schedule != nil {
+		if at := job.Schedule.Next(time.Now()); at.After(time.Now()) {
+			p.hold(ctx, job, at)
+			return
+		}
+	}
+	p.run(ctx, job)
+}
+
+// hold откладывает обработку до момента at
+func (p *Pipeline) hold(ctx context.Context, job Job, at time.Time) {
+	ctx = context.WithoutCancel(ctx)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		p.interrupt(ctx, &job)
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(time.Until(at), func() {
+		p.mu.Lock()
+		_, ok := p.held[timer]
+		delete(p.held, timer)
+		p.mu.Unlock()
+		// Задачи нет — её уже сохранил Stop
+		if ok {
+			p.run(ctx, job)
+		}
+	})
+	p.held[timer] = job
+	slog.InfoContext(ctx, "Delivery held until publishing window", "project", job.Project.Name, "at", at)
+}
+
+// Stop сохраняет отложенные задачи как прерванные; вызывается при остановке сервиса.
+// Сохраняются и задачи, таймер которых уже сработал, но ещё не забрал их из held:
+// такой таймер найдёт held пустым и ничего не запустит
+func (p *Pipeline) Stop(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stopped = true
+	for timer, job := range p.held {
+		timer.Stop()
+		p.interrupt(ctx, &job)
+	}
+	clear(p.held)
+}
+
+func (p *Pipeline) run(ctx context.Context, job Job) {
+	metrics.PipelineQueueDepth.Inc()
+	err := p.jobs.Go(ctx, func(ctx context.Context) {
+		defer metrics.PipelineQueueDepth.Dec()
+		p.process(ctx, job)
+	})
+	if err != nil {
+		metrics.PipelineQueueDepth.Dec()
+		p.interrupt(ctx, &job)
+	}
+}
+
+func (p *Pipeline) process(ctx context.Context, job Job) {
+	payload := job.Payload
+
+	// Спан обработки — дочерний к спану запроса webhook
+	attributes := []attribute.KeyValue{
+		attribute.String("commitcaster.project", job.Project.Name),
+		attribute.String("commitcaster.repository", payload.Repository.FullName),
+		attribute.String("commitcaster.ref", payload.Ref),
+		attribute.Int("commitcaster.commits", len(payload.Commits)),
+	}
+	if job.DeliveryID != 0 {
+		attributes = append(attributes,
+			attribute.Int("commitcaster.delivery_id", int(job.DeliveryID)),
+			attribute.Int("commitcaster.user_id", int(job.Owner.ID)),
+		)
+	}
+	ctx, span := tracing.Start(ctx, "webhook.process", trace.WithAttributes(attributes...))
+	defer span.End()
+
+	// Журнал пишет без отмены контекста, поэтому итог сохраняется и после дедлайна
+	ctx, cancel := context.WithTimeoutCause(ctx, JobTimeout, ErrJobTimeout)
+	defer cancel()
+
+	// Применяем настройки проекта и подходящего правила маршрутизации
+	rule := job.Project.MatchRule(payload.Repository.FullName, payload.Ref)
+	settings := job.Settings.ForProject(&job.Project).ForRule(rule)
+
+	destinations := job.Project.DestinationsFor(rule)
+	if len(destinations) == 0 {
+		slog.WarnContext(ctx, "No destinations for repository", "repository", payload.Repository.FullName, "ref", payload.Ref, "project", job.Project.Name)
+		p.finish(ctx, &job, models.DeliveryFailed, "no destinations for repository and ref")
+		return
+	}
+
+	commitSummary := Summarize(payload, settings.MaxCommits)
+
+	slog.InfoContext(ctx, "Processing commits", "repository", payload.Repository.FullName, "user_id", settings.UserID, "project", job.Project.Name, "commits", len(payload.Commits))
+	slog.DebugContext(ctx, "Commit summary", "summary", commitSummary)
+
+	// При продолжении прерванной обработки пост не генерируется заново,
+	// а каналы, куда он уже отправлен, пропускаются
+	generated := job.Post == ""
+	if generated {
+		post, ok := p.generate(ctx, &job, settings, commitSummary)
+		if !ok {
+			return
+		}
+		job.Post = Format(post)
+	}
+
+	sentNow := p.publish(ctx, &job, settings, destinations)
+
+	counts := models.UsageCounts{TelegramSends: int64(sentNow)}
+	if generated {
+		counts.Posts = 1
+	}
+	p.journal.RecordUsage(ctx, &job, counts)
+
+	sent := len(job.Sent)
+	if sent < len(destinations) && jobs.Interrupted(ctx) {
+		p.interrupt(ctx, &job)
+		return
+	}
+	if sent < len(destinations) && timedOut(ctx) {
+		p.finish(ctx, &job, models.DeliveryFailed, fmt.Sprintf("%v after %s: sent to %d of %d channels", ErrJobTimeout, JobTimeout, sent, len(destinations)))
+		return
+	}
+	if sent < len(destinations) {
+		p.finish(ctx, &job, models.DeliveryFailed, fmt.Sprintf("sent to %d of %d channels", sent, len(destinations)))
+		return
+	}
+	p.finish(ctx, &job, models.DeliverySucceeded, "")
+}
+
+// generate генерирует пост с помощью AI. false — обработка завершена или прервана
+func (p *Pipeline) generate(ctx context.Context, job *Job, settings models.UserSettings, commitSummary string) (string, bool) {
+	// Квоту проверяем ещё раз и резервируем AI запрос: параллельные доставки могли
+	// исчерпать её после приёма webhook
+	if err := p.journal.ReserveQuota(ctx, job); err != nil {
+		p.finish(ctx, job, models.DeliveryQuotaExceeded, err.Error())
+		return "", false
+	}
+
+	aiService := services.NewAIServiceWithSettings(&settings)
+	post, aiUsage, err := aiService.GeneratePostWithUsage(ctx, commitSummary, job.Payload.Repository.Name)
+	p.journal.RecordUsage(ctx, job, models.UsageCounts{
+		PromptTokens:     aiUsage.PromptTokens,
+		CompletionTokens: aiUsage.CompletionTokens,
+	})
+	if err != nil {
+		p.journal.ReleaseQuota(ctx, job)
+		if jobs.Interrupted(ctx) {
+			p.interrupt(ctx, job)
+			return "", false
+		}
+		if timedOut(ctx) {
+			err = fmt.Errorf("%w after %s", ErrJobTimeout, JobTimeout)
+		}
+		slog.ErrorContext(ctx, "Failed to generate post", "error", err)
+		p.finish(ctx, job, models.DeliveryFailed, fmt.Sprintf("generate post: %v", err))
+		return "", false
+	}
+	return post, true
+}
+
+// publish отправляет пост в каналы, куда он ещё не отправлен, и возвращает число отправок
+func (p *Pipeline) publish(ctx context.Context, job *Job, settings models.UserSettings, destinations []models.Destination) int {
+	sent := make(map[string]bool, len(job.Sent))
+	for _, channelID := range job.Sent {
+		sent[channelID] = true
+	}
+
+	sentNow := 0
+	for _, destination := range destinations {
+		if sent[destination.TelegramChannelID] {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		record := models.Post{
+			DeliveryID:        job.DeliveryID,
+			UserID:            job.Owner.ID,
+			TelegramChannelID: destination.TelegramChannelID,
+			Content:           job.Post,
+			Status:            models.PostSent,
+		}
+
+		destinationSettings := settings.ForDestination(destination)
+		telegramService := services.NewTelegramServiceWithSettings(&destinationSettings)
+		if err := telegramService.SendMessageContext(ctx, job.Post); err != nil {
+			if jobs.Interrupted(ctx) {
+				break
+			}
+			slog.ErrorContext(ctx, "Failed to send to Telegram", "channel_id", destination.TelegramChannelID, "error", err)
+			record.Status = models.PostFailed
+			record.Error = err.Error()
+		} else {
+			sent[destination.TelegramChannelID] = true
+			job.Sent = append(job.Sent, destination.TelegramChannelID)
+			sentNow++
+			slog.InfoContext(ctx, "Posted to Telegram", "channel_id", destination.TelegramChannelID, "user_id", settings.UserID)
+		}
+
+		p.journal.RecordPost(ctx, job, record)
+	}
+	return sentNow
+}
+
+// finish завершает обработку с итоговым статусом
+func (p *Pipeline) finish(ctx context.Context, job *Job, status, errMsg string) {
+	metrics.Delivery(status, job.Started)
+
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("commitcaster.delivery_status", status))
+	if errMsg != "" {
+		span.SetStatus(codes.Error, errMsg)
+	}
+
+	p.journal.Finish(ctx, job, status, errMsg)
+	if errMsg != "" {
+		slog.WarnContext(ctx, "Delivery finished", "status", status, "error", errMsg)
+		return
+	}
+	slog.InfoContext(ctx, "Delivery finished", "status", status)
+}
+
+// timedOut сообщает, что обработка отменена по JobTimeout
+func timedOut(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrJobTimeout)
+}
+
+// interrupt сохраняет обработку, прерванную остановкой сервиса
+func (p *Pipeline) interrupt(ctx context.Context, job *Job) {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("commitcaster.delivery_status", models.DeliveryInterrupted))
+	p.journal.Interrupt(ctx, job)
+}
@@ -0,0 +1,136 @@
+package pipeline
+
+import (
+	"commitcaster/config"
+	"commitcaster/internal/jobs"
+	"commitcaster/internal/models"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testJournal запоминает вызовы журнала
+type testJournal struct {
+	mu          sync.Mutex
+	interrupted []Job
+	finished    []string
+}
+
+func (j *testJournal) ReserveQuota(ctx context.Context, job *Job) error { return nil }
+
+func (j *testJournal) ReleaseQuota(ctx context.Context, job *Job) {}
+
+func (j *testJournal) RecordUsage(ctx context.Context, job *Job, counts models.UsageCounts) {}
+
+func (j *testJournal) RecordPost(ctx context.Context, job *Job, post models.Post) {}
+
+func (j *testJournal) Finish(ctx context.Context, job *Job, status, errMsg string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.finished = append(j.finished, status)
+}
+
+func (j *testJournal) Interrupt(ctx context.Context, job *Job) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.interrupted = append(j.interrupted, *job)
+}
+
+func (j *testJournal) interruptedCount() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.interrupted)
+}
+
+// tomorrowWindow — окно публикации, которое откроется только завтра
+func tomorrowWindow(t *testing.T) *config.Window {
+	t.Helper()
+
+	day := strings.ToLower(time.Now().AddDate(0, 0, 1).Weekday().String()[:3])
+	window, err := config.Schedule{Timezone: "UTC", Days: []string{day}}.Window()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return window
+}
+
+func heldJob(t *testing.T) Job {
+	return Job{
+		Target:  Target{Project: models.Project{Name: "api"}, Schedule: tomorrowWindow(t)},
+		Payload: models.GitHubWebhookPayload{Ref: "refs/heads/main"},
+	}
+}
+
+func TestPipelineHoldsJobOutsideWindow(t *testing.T) {
+	journal := &testJournal{}
+	p := New(jobs.NewTracker(), journal)
+
+	p.Start(context.Background(), heldJob(t))
+
+	p.mu.Lock()
+	held := len(p.held)
+	p.mu.Unlock()
+	if held != 1 {
+		t.Fatalf("held jobs = %d, want 1", held)
+	}
+	if n := journal.interruptedCount(); n != 0 {
+		t.Fatalf("interrupted = %d before Stop", n)
+	}
+
+	p.Stop(context.Background())
+	if n := journal.interruptedCount(); n != 1 {
+		t.Fatalf("interrupted = %d after Stop, want 1", n)
+	}
+}
+
+func TestPipelineStopInterruptsFiredTimer(t *testing.T) {
+	journal := &testJournal{}
+	p := New(jobs.NewTracker(), journal)
+
+	// Таймер уже сработал, но его callback ещё не забрал задачу из held:
+	// timer.Stop() вернёт false, а задача всё равно должна быть сохранена
+	fired := make(chan struct{})
+	timer := time.AfterFunc(0, func() { close(fired) })
+	<-fired
+
+	p.mu.Lock()
+	p.held[timer] = heldJob(t)
+	p.mu.Unlock()
+
+	p.Stop(context.Background())
+	if n := journal.interruptedCount(); n != 1 {
+		t.Fatalf("interrupted = %d, want 1", n)
+	}
+}
+
+func TestPipelineHoldAfterStopInterrupts(t *testing.T) {
+	journal := &testJournal{}
+	p := New(jobs.NewTracker(), journal)
+	p.Stop(context.Background())
+
+	p.Start(context.Background(), heldJob(t))
+
+	if n := journal.interruptedCount(); n != 1 {
+		t.Fatalf("interrupted = %d, want 1", n)
+	}
+	if len(p.held) != 0 {
+		t.Fatalf("job held after Stop")
+	}
+}
+
+func TestPipelineRunAfterShutdownInterrupts(t *testing.T) {
+	journal := &testJournal{}
+	tracker := jobs.NewTracker()
+	if err := tracker.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	p := New(tracker, journal)
+
+	p.Start(context.Background(), Job{Target: Target{Project: models.Project{Name: "api"}}})
+
+	if n := journal.interruptedCount(); n != 1 {
+		t.Fatalf("interrupted = %d, want 1", n)
+	}
+}
@@ -0,0 +1,58 @@
+package pipeline
+
+import (
+	"commitcaster/internal/models"
+	"fmt"
+	"strings"
+)
+
+// DefaultMaxCommits — сколько коммитов попадает в сводку, если MaxCommits не задан
+const DefaultMaxCommits = 5
+
+// maxPostLength — ограничение Telegram на длину сообщения в символах
+const maxPostLength = 4096
+
+// Summarize собирает сводку коммитов для промпта AI
+func Summarize(payload models.GitHubWebhookPayload, maxCommits int) string {
+	var summary strings.Builder
+
+	if maxCommits <= 0 {
+		maxCommits = DefaultMaxCommits
+	}
+
+	summary.WriteString(fmt.Sprintf("Репозиторий: %s\n", payload.Repository.Name))
+	summary.WriteString(fmt.Sprintf("Количество коммитов: %d\n\n", len(payload.Commits)))
+
+	for i, commit := range payload.Commits {
+		if i >= maxCommits {
+			summary.WriteString(fmt.Sprintf("...и ещё %d коммитов\n", len(payload.Commits)-maxCommits))
+			break
+		}
+
+		summary.WriteString(fmt.Sprintf("Коммит %d:\n", i+1))
+		summary.WriteString(fmt.Sprintf("Сообщение: %s\n", commit.Message))
+
+		if len(commit.Added) > 0 {
+			summary.WriteString(fmt.Sprintf("Добавлено файлов: %d\n", len(commit.Added)))
+		}
+		if len(commit.Modified) > 0 {
+			summary.WriteString(fmt.Sprintf("Изменено файлов: %d\n", len(commit.Modified)))
+		}
+		if len(commit.Removed) > 0 {
+			summary.WriteString(fmt.Sprintf("Удалено файлов: %d\n", len(commit.Removed)))
+		}
+		summary.WriteString("\n")
+	}
+
+	return summary.String()
+}
+
+// Format готовит сгенерированный пост к публикации: убирает пробелы по краям
+// и обрезает до максимальной длины сообщения Telegram
+func Format(post string) string {
+	post = strings.TrimSpace(post)
+	if runes := []rune(post); len(runes) > maxPostLength {
+		post = string(runes[:maxPostLength-1]) + "…"
+	}
+	return post
+}
@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // момент, когда корзина снова станет полной
+}
+
+// MemoryLimiter хранит корзины в памяти процесса
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func (l *MemoryLimiter) Allow(key string, rule Rule) (bool, time.Duration) {
+	if !rule.Enabled() {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rule.Limit), updated: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens += now.Sub(b.updated).Seconds() * rule.rate()
+	if b.tokens > float64(rule.Limit) {
+		b.tokens = float64(rule.Limit)
+	}
+	b.updated = now
+
+	if b.tokens < 1 {
+		return false, rule.retryAfter(b.tokens)
+	}
+
+	b.tokens--
+	b.full = now.Add(time.Duration((float64(rule.Limit) - b.tokens) / rule.rate() * float64(time.Second)))
+	return true, 0
+}
+
+// sweep удаляет восстановившиеся корзины, чтобы карта не росла бесконечно
+func (l *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.After(b.full) {
+			delete(l.buckets, key)
+		}
+	}
+}
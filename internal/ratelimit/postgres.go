@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PostgresLimiter хранит корзины в таблице rate_limit_buckets, чтобы лимиты
+// были общими для всех экземпляров сервиса. Списание выполняется одним
+// атомарным UPSERT. Ключи (IP, email) хранятся в виде SHA-256 хеша
+type PostgresLimiter struct {
+	db *gorm.DB
+
+	mu        sync.Mutex
+	lastSweep time.Time
+	// maxPeriod — самый долгий период восстановления среди правил, с которыми
+	// вызывался Allow: корзина без запросов дольше него заведомо полная
+	maxPeriod time.Duration
+}
+
+func NewPostgresLimiter(db *gorm.DB) *PostgresLimiter {
+	return &PostgresLimiter{db: db, lastSweep: time.Now()}
+}
+
+const upsertBucketSQL = `
+INSERT INTO rate_limit_buckets (key, tokens, allowed, updated_at)
+VALUES (@key, @limit - 1, TRUE, now())
+ON CONFLICT (key) DO UPDATE SET
+	tokens = CASE
+		WHEN LEAST(@limit, rate_limit_buckets.tokens + EXTRACT(EPOCH FROM now() - rate_limit_buckets.updated_at) * @rate) >= 1
+		THEN LEAST(@limit, rate_limit_buckets.tokens + EXTRACT(EPOCH FROM now() - rate_limit_buckets.updated_at) * @rate) - 1
+		ELSE LEAST(@limit, rate_limit_buckets.tokens + EXTRACT(EPOCH FROM now() - rate_limit_buckets.updated_at) * @rate)
+	END,
+	allowed = LEAST(@limit, rate_limit_buckets.tokens + EXTRACT(EPOCH FROM now() - rate_limit_buckets.updated_at) * @rate) >= 1,
+	updated_at = now()
+RETURNING tokens, allowed`
+
+func (l *PostgresLimiter) Allow(key string, rule Rule) (bool, time.Duration) {
+	if !rule.Enabled() {
+		return true, 0
+	}
+
+	l.sweep(rule)
+
+	var result struct {
+		Tokens  float64
+		Allowed bool
+	}
+	err := l.db.Raw(upsertBucketSQL, map[string]interface{}{
+		"key":   bucketKey(key),
+		"limit": float64(rule.Limit),
+		"rate":  rule.rate(),
+	}).Scan(&result).Error
+	if err != nil {
+		// Недоступность хранилища лимитов не должна блокировать вход и webhooks
+		slog.Error("Rate limit store error", "error", err)
+		return true, 0
+	}
+
+	if !result.Allowed {
+		return false, rule.retryAfter(result.Tokens)
+	}
+	return true, 0
+}
+
+// bucketKey — ключ корзины в таблице: хеш, чтобы в БД не попадали IP и email
+func bucketKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// sweep раз в sweepInterval удаляет корзины, которые уже восстановились: без неё
+// таблица растёт с каждым новым ключом. Удалённая корзина равна полной, поэтому
+// лимиты не меняются. Ошибка удаления только логируется
+func (l *PostgresLimiter) sweep(rule Rule) {
+	l.mu.Lock()
+	l.maxPeriod = max(l.maxPeriod, rule.Period)
+	now := time.Now()
+	if now.Sub(l.lastSweep) < sweepInterval {
+		l.mu.Unlock()
+		return
+	}
+	l.lastSweep = now
+	period := l.maxPeriod
+	l.mu.Unlock()
+
+	result := l.db.Exec("DELETE FROM rate_limit_buckets WHERE updated_at < now() - make_interval(secs => ?)", period.Seconds())
+	if result.Error != nil {
+		slog.Error("Failed to sweep rate limit buckets", "error", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		slog.Debug("Rate limit buckets swept", "count", result.RowsAffected)
+	}
+}
@@ -0,0 +1,107 @@
+// Package ratelimit реализует ограничение частоты запросов по алгоритму token bucket
+package ratelimit
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Rule — ёмкость корзины (Limit запросов) и время её полного восстановления (Period)
+type Rule struct {
+	Limit  int
+	Period time.Duration
+}
+
+// Enabled сообщает, что правило ограничивает запросы (Limit 0 отключает его)
+func (r Rule) Enabled() bool {
+	return r.Limit > 0 && r.Period > 0
+}
+
+// rate — скорость пополнения корзины в токенах в секунду
+func (r Rule) rate() float64 {
+	return float64(r.Limit) / r.Period.Seconds()
+}
+
+// retryAfter — время до появления целого токена при текущем остатке
+func (r Rule) retryAfter(tokens float64) time.Duration {
+	wait := time.Duration((1 - tokens) / r.rate() * float64(time.Second))
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return wait
+}
+
+// Limiter списывает токен из корзины key. Если токенов нет, возвращает false
+// и время, через которое стоит повторить запрос
+type Limiter interface {
+	Allow(key string, rule Rule) (bool, time.Duration)
+}
+
+// New выбирает хранилище по RATE_LIMIT_STORE: memory (по умолчанию) или postgres.
+// Postgres нужен, когда запущено несколько экземпляров сервиса. db = nil — данные
+// хранятся в памяти процесса (DATABASE_URL=memory://)
+func New(db *gorm.DB) Limiter {
+	switch os.Getenv("RATE_LIMIT_STORE") {
+	case "postgres":
+		if db == nil {
+			slog.Warn("RATE_LIMIT_STORE=postgres requires PostgreSQL, using memory")
+			return NewMemoryLimiter()
+		}
+		if db.Dialector.Name() != "postgres" {
+			// Общие лимиты нужны только нескольким экземплярам, а SQLite рассчитан на один
+			slog.Warn("RATE_LIMIT_STORE=postgres requires PostgreSQL, using memory", "driver", db.Dialector.Name())
+			return NewMemoryLimiter()
+		}
+		return NewPostgresLimiter(db)
+	case "", "memory":
+		return NewMemoryLimiter()
+	default:
+		slog.Warn("Unknown RATE_LIMIT_STORE, using memory", "store", os.Getenv("RATE_LIMIT_STORE"))
+		return NewMemoryLimiter()
+	}
+}
+
+// RuleFromEnv читает правило вида "20/1m" (20 запросов в минуту); "0" отключает ограничение
+func RuleFromEnv(key string, defaultRule Rule) Rule {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return defaultRule
+	}
+
+	rule, err := ParseRule(value)
+	if err != nil {
+		slog.Warn("Invalid rate limit rule, using default", "key", key, "value", value, "error", err)
+		return defaultRule
+	}
+	return rule
+}
+
+// ParseRule разбирает правило вида "<limit>/<period>", например "5/15m"
+func ParseRule(value string) (Rule, error) {
+	if value == "0" {
+		return Rule{}, nil
+	}
+
+	limitPart, periodPart, ok := strings.Cut(value, "/")
+	if !ok {
+		return Rule{}, fmt.Errorf("expected <limit>/<period>")
+	}
+
+	limit, err := strconv.Atoi(strings.TrimSpace(limitPart))
+	if err != nil || limit < 0 {
+		return Rule{}, fmt.Errorf("invalid limit")
+	}
+
+	period, err := time.ParseDuration(strings.TrimSpace(periodPart))
+	if err != nil || period <= 0 {
+		return Rule{}, fmt.Errorf("invalid period")
+	}
+
+	return Rule{Limit: limit, Period: period}, nil
+}
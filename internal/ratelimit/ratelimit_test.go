@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// testLimiter — MemoryLimiter с управляемыми часами
+func testLimiter() (*MemoryLimiter, *time.Time) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := NewMemoryLimiter()
+	l.now = func() time.Time { return now }
+	l.lastSweep = now
+	return l, &now
+}
+
+func TestMemoryLimiterAllow(t *testing.T) {
+	l, now := testLimiter()
+	rule := Rule{Limit: 3, Period: 3 * time.Second}
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("ip:1", rule); !ok {
+			t.Fatalf("request %d rejected", i+1)
+		}
+	}
+	ok, retry := l.Allow("ip:1", rule)
+	if ok {
+		t.Fatal("request over the limit allowed")
+	}
+	if retry != time.Second {
+		t.Errorf("retry after = %v, want 1s", retry)
+	}
+
+	// Другой ключ считается отдельно
+	if ok, _ := l.Allow("ip:2", rule); !ok {
+		t.Error("other key rejected")
+	}
+
+	// За секунду восстанавливается один токен
+	*now = now.Add(time.Second)
+	if ok, _ := l.Allow("ip:1", rule); !ok {
+		t.Error("request rejected after refill")
+	}
+	if ok, _ := l.Allow("ip:1", rule); ok {
+		t.Error("refill exceeded one token")
+	}
+}
+
+func TestMemoryLimiterDisabledRule(t *testing.T) {
+	l, _ := testLimiter()
+	for i := 0; i < 10; i++ {
+		if ok, _ := l.Allow("ip:1", Rule{}); !ok {
+			t.Fatal("disabled rule rejected a request")
+		}
+	}
+	if len(l.buckets) != 0 {
+		t.Errorf("buckets = %d for a disabled rule", len(l.buckets))
+	}
+}
+
+func TestMemoryLimiterSweep(t *testing.T) {
+	l, now := testLimiter()
+	rule := Rule{Limit: 2, Period: 10 * time.Minute}
+
+	l.Allow("stale", rule)
+	*now = now.Add(9 * time.Minute)
+	l.Allow("fresh", rule)
+
+	// Через 11 минут корзина stale полная и удаляется, fresh — ещё нет
+	*now = now.Add(2 * time.Minute)
+	l.Allow("other", rule)
+
+	if _, ok := l.buckets["stale"]; ok {
+		t.Error("full bucket not swept")
+	}
+	if _, ok := l.buckets["fresh"]; !ok {
+		t.Error("partially used bucket swept")
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Rule
+		err   bool
+	}{
+		{"5/15m", Rule{Limit: 5, Period: 15 * time.Minute}, false},
+		{" 20 / 1m ", Rule{Limit: 20, Period: time.Minute}, false},
+		{"0", Rule{}, false},
+		{"5", Rule{}, true},
+		{"x/1m", Rule{}, true},
+		{"-1/1m", Rule{}, true},
+		{"5/soon", Rule{}, true},
+		{"5/0s", Rule{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRule(tt.value)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseRule(%q) error = %v, want error %v", tt.value, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRule(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRuleFromEnv(t *testing.T) {
+	fallback := Rule{Limit: 60, Period: time.Minute}
+
+	t.Setenv("RATE_LIMIT_TEST", "")
+	if got := RuleFromEnv("RATE_LIMIT_TEST", fallback); got != fallback {
+		t.Errorf("empty value: %+v, want default", got)
+	}
+	t.Setenv("RATE_LIMIT_TEST", "invalid")
+	if got := RuleFromEnv("RATE_LIMIT_TEST", fallback); got != fallback {
+		t.Errorf("invalid value: %+v, want default", got)
+	}
+	t.Setenv("RATE_LIMIT_TEST", "0")
+	if got := RuleFromEnv("RATE_LIMIT_TEST", fallback); got.Enabled() {
+		t.Errorf("\"0\" does not disable the rule: %+v", got)
+	}
+}
+
+func TestBucketKeyHashesKey(t *testing.T) {
+	key := bucketKey("login:alice@example.com")
+	if strings.Contains(key, "alice") || len(key) != 64 {
+		t.Errorf("bucketKey() = %q, want SHA-256 hex", key)
+	}
+	if key != bucketKey("login:alice@example.com") {
+		t.Error("bucketKey is not deterministic")
+	}
+	if key == bucketKey("login:bob@example.com") {
+		t.Error("different keys share a bucket")
+	}
+}
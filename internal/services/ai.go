@@ -3,13 +3,26 @@ package services
 import (
 	"bytes"
 	"commitcaster/config"
+	"commitcaster/internal/logging"
+	"commitcaster/internal/metrics"
 	"commitcaster/internal/models"
+	"commitcaster/internal/tracing"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// aiClient — HTTP клиент AI API. Таймаут ограничивает генерацию, даже если
+// контекст запроса не отменится
+var aiClient = &http.Client{Timeout: 2 * time.Minute}
+
 type AIService struct {
 	cfg      *config.Config
 	settings *models.UserSettings
@@ -31,6 +44,13 @@ type GroqResponse struct {
 	Choices []struct {
 		Message Message `json:"message"`
 	} `json:"choices"`
+	Usage AIUsage `json:"usage"`
+}
+
+// AIUsage — токены, которые провайдер учёл за запрос
+type AIUsage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
 }
 
 func NewAIService(cfg *config.Config) *AIService {
@@ -44,6 +64,12 @@ func NewAIServiceWithSettings(settings *models.UserSettings) *AIService {
 
 // GeneratePost генерирует пост на основе информации о коммитах
 func (s *AIService) GeneratePost(commitSummary, repoName string) (string, error) {
+	post, _, err := s.GeneratePostWithUsage(context.Background(), commitSummary, repoName)
+	return post, err
+}
+
+// GeneratePostWithUsage генерирует пост и возвращает число токенов из ответа провайдера
+func (s *AIService) GeneratePostWithUsage(ctx context.Context, commitSummary, repoName string) (post string, aiUsage AIUsage, err error) {
 	// Определяем промпт
 	var prompt string
 	if s.settings != nil && s.settings.CustomPrompt != "" {
@@ -64,12 +90,12 @@ func (s *AIService) GeneratePost(commitSummary, repoName string) (string, error)
 - Можно пошутить или добавить мем-реакцию
 - Используй эмодзи (но не перебарщивай)
 - БЕЗ хештегов
-- На русском языке
+- %s
 
 Примеры стиля:
 "Допилил вебхуки для GitHub - теперь бот сам постит обновления. Попутно словил баг с токенами, но разобрался 💪"
 "Запушил фичу с AI-генерацией постов. Llama работает огонь, генерит годноту! 🔥"
-"Переехал с Groq на OpenRouter из-за блокировок. Работает даже быстрее оказалось 🚀"`, commitSummary, repoName)
+"Переехал с Groq на OpenRouter из-за блокировок. Работает даже быстрее оказалось 🚀"`, commitSummary, repoName, s.languageInstruction())
 	}
 
 	// Определяем модель (OpenRouter format)
@@ -78,6 +104,18 @@ func (s *AIService) GeneratePost(commitSummary, repoName string) (string, error)
 		model = s.settings.AIModel
 	}
 
+	modelLabel := metrics.Model(model)
+
+	ctx, span := tracing.Start(ctx, "ai.generate_post",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.GenAIProviderNameKey.String(metrics.ProviderOpenRouter),
+			semconv.GenAIRequestModel(model),
+			semconv.ServerAddress("openrouter.ai"),
+		),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	// Определяем API ключ
 	var apiKey string
 	if s.settings != nil {
@@ -85,7 +123,8 @@ func (s *AIService) GeneratePost(commitSummary, repoName string) (string, error)
 	} else if s.cfg != nil {
 		apiKey = s.cfg.GroqAPIKey
 	} else {
-		return "", fmt.Errorf("no API key available")
+		metrics.AIErrorsTotal.WithLabelValues(metrics.ProviderOpenRouter, modelLabel, "config").Inc()
+		return "", AIUsage{}, fmt.Errorf("no API key available")
 	}
 
 	reqBody := GroqRequest{
@@ -102,12 +141,12 @@ func (s *AIService) GeneratePost(commitSummary, repoName string) (string, error)
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return "", AIUsage{}, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", "https://openrouter.ai/api/v1/chat/completions", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://openrouter.ai/api/v1/chat/completions", bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return "", AIUsage{}, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -115,26 +154,96 @@ func (s *AIService) GeneratePost(commitSummary, repoName string) (string, error)
 	req.Header.Set("HTTP-Referer", "https://github.com/Minkaill/commit-caster-bot")
 	req.Header.Set("X-Title", "CommitCaster")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	start := time.Now()
+	defer func() {
+		metrics.AIRequestDuration.WithLabelValues(metrics.ProviderOpenRouter, modelLabel).Observe(time.Since(start).Seconds())
+	}()
+	aiError := func(reason string) {
+		metrics.AIErrorsTotal.WithLabelValues(metrics.ProviderOpenRouter, modelLabel, reason).Inc()
+	}
+
+	resp, err := aiClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		aiError("network")
+		return "", AIUsage{}, fmt.Errorf("failed to send request: %w", logging.RedactURLError(err))
 	}
 	defer resp.Body.Close()
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
 
 	if resp.StatusCode != http.StatusOK {
+		aiError("http_" + strconv.Itoa(resp.StatusCode))
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("AI API error (status %d): %s", resp.StatusCode, string(body))
+		return "", AIUsage{}, fmt.Errorf("AI API error (status %d): %s", resp.StatusCode, string(body))
 	}
 
 	var groqResp GroqResponse
 	if err := json.NewDecoder(resp.Body).Decode(&groqResp); err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
+		aiError("decode")
+		return "", AIUsage{}, fmt.Errorf("failed to decode response: %w", err)
 	}
 
+	metrics.AITokensTotal.WithLabelValues(metrics.ProviderOpenRouter, modelLabel, "prompt").Add(float64(groqResp.Usage.PromptTokens))
+	metrics.AITokensTotal.WithLabelValues(metrics.ProviderOpenRouter, modelLabel, "completion").Add(float64(groqResp.Usage.CompletionTokens))
+	span.SetAttributes(
+		semconv.GenAIUsageInputTokens(int(groqResp.Usage.PromptTokens)),
+		semconv.GenAIUsageOutputTokens(int(groqResp.Usage.CompletionTokens)),
+	)
+
 	if len(groqResp.Choices) == 0 {
-		return "", fmt.Errorf("no response from AI")
+		aiError("empty_response")
+		return "", groqResp.Usage, fmt.Errorf("no response from AI")
 	}
 
-	return groqResp.Choices[0].Message.Content, nil
+	return groqResp.Choices[0].Message.Content, groqResp.Usage, nil
+}
+
+// Ping проверяет API ключ запросом информации о ключе (для проверки готовности)
+func (s *AIService) Ping(ctx context.Context) error {
+	var apiKey string
+	if s.settings != nil {
+		apiKey = s.settings.GroqAPIKey
+	} else if s.cfg != nil {
+		apiKey = s.cfg.GroqAPIKey
+	}
+	if apiKey == "" {
+		return fmt.Errorf("no API key available")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://openrouter.ai/api/v1/key", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
+
+	resp, err := aiClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", logging.RedactURLError(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("AI API error (status %d)", resp.StatusCode)
+	}
+	return nil
+}
+
+// languageInstruction возвращает указание для AI, на каком языке писать пост
+func (s *AIService) languageInstruction() string {
+	lang := "ru"
+	if s.settings != nil && s.settings.PostLanguage != "" {
+		lang = s.settings.PostLanguage
+	}
+
+	names := map[string]string{
+		"ru": "русском",
+		"en": "английском",
+		"uk": "украинском",
+		"de": "немецком",
+		"es": "испанском",
+		"fr": "французском",
+	}
+	if name, ok := names[lang]; ok {
+		return fmt.Sprintf("На %s языке", name)
+	}
+	return fmt.Sprintf("На языке с кодом %q", lang)
 }
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// GitHubOAuthService реализует вход через GitHub OAuth2.
+// Адреса провайдера настраиваются через env, чтобы можно было
+// использовать локальный fake провайдер
+type GitHubOAuthService struct {
+	ClientID     string
+	ClientSecret string
+	AuthorizeURL string
+	TokenURL     string
+	APIURL       string
+	RedirectURL  string
+
+	client *http.Client
+}
+
+// GitHubUser — профиль пользователя GitHub с подтверждённым основным email
+type GitHubUser struct {
+	ID    int64
+	Login string
+	Name  string
+	Email string
+}
+
+func NewGitHubOAuthService() *GitHubOAuthService {
+	baseURL := os.Getenv("BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+
+	return &GitHubOAuthService{
+		ClientID:     os.Getenv("GITHUB_CLIENT_ID"),
+		ClientSecret: os.Getenv("GITHUB_CLIENT_SECRET"),
+		AuthorizeURL: getEnv("GITHUB_OAUTH_AUTHORIZE_URL", "https://github.com/login/oauth/authorize"),
+		TokenURL:     getEnv("GITHUB_OAUTH_TOKEN_URL", "https://github.com/login/oauth/access_token"),
+		APIURL:       strings.TrimSuffix(getEnv("GITHUB_API_URL", "https://api.github.com"), "/"),
+		RedirectURL:  getEnv("GITHUB_OAUTH_REDIRECT_URL", baseURL+"/api/auth/github/callback"),
+		client:       &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Enabled проверяет, что OAuth настроен
+func (s *GitHubOAuthService) Enabled() bool {
+	return s.ClientID != "" && s.ClientSecret != ""
+}
+
+// AuthorizationURL возвращает адрес страницы авторизации GitHub
+func (s *GitHubOAuthService) AuthorizationURL(state string) string {
+	params := url.Values{}
+	params.Set("client_id", s.ClientID)
+	params.Set("redirect_uri", s.RedirectURL)
+	params.Set("scope", "read:user user:email")
+	params.Set("state", state)
+	params.Set("allow_signup", "true")
+	return s.AuthorizeURL + "?" + params.Encode()
+}
+
+// Exchange обменивает код авторизации на access токен GitHub
+func (s *GitHubOAuthService) Exchange(code string) (string, error) {
+	form := url.Values{}
+	form.Set("client_id", s.ClientID)
+	form.Set("client_secret", s.ClientSecret)
+	form.Set("code", code)
+	form.Set("redirect_uri", s.RedirectURL)
+
+	req, err := http.NewRequest("POST", s.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tokenResp struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := s.do(req, &tokenResp); err != nil {
+		return "", err
+	}
+	if tokenResp.Error != "" {
+		return "", fmt.Errorf("GitHub OAuth error: %s (%s)", tokenResp.Error, tokenResp.ErrorDescription)
+	}
+	if tokenResp.AccessToken == "" {
+		return "", fmt.Errorf("GitHub OAuth error: empty access token")
+	}
+
+	return tokenResp.AccessToken, nil
+}
+
+// FetchUser загружает профиль и подтверждённый основной email пользователя
+func (s *GitHubOAuthService) FetchUser(accessToken string) (*GitHubUser, error) {
+	var profile struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := s.get(accessToken, "/user", &profile); err != nil {
+		return nil, err
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := s.get(accessToken, "/user/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	user := &GitHubUser{ID: profile.ID, Login: profile.Login, Name: profile.Name}
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			user.Email = e.Email
+			break
+		}
+	}
+
+	return user, nil
+}
+
+func (s *GitHubOAuthService) get(accessToken, path string, out interface{}) error {
+	req, err := http.NewRequest("GET", s.APIURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	return s.do(req, out)
+}
+
+func (s *GitHubOAuthService) do(req *http.Request, out interface{}) error {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("GitHub API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
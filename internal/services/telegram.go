@@ -3,13 +3,27 @@ package services
 import (
 	"bytes"
 	"commitcaster/config"
+	"commitcaster/internal/logging"
+	"commitcaster/internal/metrics"
 	"commitcaster/internal/models"
+	"commitcaster/internal/tracing"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// telegramClient — HTTP клиент Telegram Bot API с таймаутом на запрос
+var telegramClient = &http.Client{Timeout: 30 * time.Second}
+
 type TelegramService struct {
 	cfg      *config.Config
 	settings *models.UserSettings
@@ -32,6 +46,11 @@ func NewTelegramServiceWithSettings(settings *models.UserSettings) *TelegramServ
 
 // SendMessage отправляет сообщение в Telegram канал
 func (s *TelegramService) SendMessage(text string) error {
+	return s.SendMessageContext(context.Background(), text)
+}
+
+// SendMessageContext отправляет сообщение в Telegram канал; атрибуты логов и трейс берутся из ctx
+func (s *TelegramService) SendMessageContext(ctx context.Context, text string) (err error) {
 	var botToken, channelID string
 
 	if s.settings != nil {
@@ -44,8 +63,16 @@ func (s *TelegramService) SendMessage(text string) error {
 		return fmt.Errorf("no configuration available")
 	}
 
-	// Debug logging
-	fmt.Printf("Telegram: Sending to channel '%s' with token '%s...'\n", channelID, botToken[:20])
+	ctx, span := tracing.Start(ctx, "telegram.send_message",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("telegram.channel_id", channelID),
+			semconv.ServerAddress("api.telegram.org"),
+		),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	slog.DebugContext(ctx, "Sending Telegram message", "channel_id", channelID)
 
 	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", botToken)
 
@@ -60,11 +87,22 @@ func (s *TelegramService) SendMessage(text string) error {
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", logging.RedactURLError(err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	start := time.Now()
+	resp, err := telegramClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		observeTelegramSend("network_error", start)
+		// URL содержит токен бота, а ошибка сохраняется в истории доставок
+		return fmt.Errorf("failed to send request: %w", logging.RedactURLError(err))
 	}
 	defer resp.Body.Close()
+	observeTelegramSend(strconv.Itoa(resp.StatusCode), start)
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
@@ -73,3 +111,41 @@ func (s *TelegramService) SendMessage(text string) error {
 
 	return nil
 }
+
+// Ping проверяет токен бота запросом getMe (для проверки готовности)
+func (s *TelegramService) Ping(ctx context.Context) error {
+	var botToken string
+	if s.settings != nil {
+		botToken = s.settings.TelegramBotToken
+	} else if s.cfg != nil {
+		botToken = s.cfg.TelegramBotToken
+	}
+	if botToken == "" {
+		return fmt.Errorf("bot token not configured")
+	}
+
+	url := fmt.Sprintf("https://api.telegram.org/bot%s/getMe", botToken)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", logging.RedactURLError(err))
+	}
+
+	resp, err := telegramClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", logging.RedactURLError(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("telegram API error (status %d)", resp.StatusCode)
+	}
+	return nil
+}
+
+// observeTelegramSend учитывает время отправки и ошибки по статусу ответа
+func observeTelegramSend(status string, start time.Time) {
+	metrics.TelegramSendDuration.WithLabelValues(status).Observe(time.Since(start).Seconds())
+	if status != strconv.Itoa(http.StatusOK) {
+		metrics.TelegramErrorsTotal.WithLabelValues(status).Inc()
+	}
+}
@@ -0,0 +1,415 @@
+package store
+
+import (
+	"commitcaster/internal/models"
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NewGORM возвращает хранилища поверх подключения GORM (PostgreSQL или SQLite)
+func NewGORM(db *gorm.DB) *Store {
+	return &Store{
+		Users:         gormUsers{db},
+		Settings:      gormSettings{db},
+		Sessions:      gormSessions{db},
+		Tokens:        gormTokens{db},
+		UserTokens:    gormUserTokens{db},
+		RecoveryCodes: gormRecoveryCodes{db},
+		Organizations: gormOrganizations{db},
+		Projects:      gormProjects{db},
+		Deliveries:    gormDeliveries{db},
+		Posts:         gormPosts{db},
+		Usage:         gormUsage{db},
+		Audit:         gormAudit{db},
+
+		transaction: func(ctx context.Context, fn func(tx *Store) error) error {
+			return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				return fn(NewGORM(tx))
+			})
+		},
+	}
+}
+
+// notFound заменяет ошибку GORM на ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+type gormUsers struct{ db *gorm.DB }
+
+func (s gormUsers) Get(ctx context.Context, id uint) (models.User, error) {
+	var user models.User
+	err := s.db.WithContext(ctx).First(&user, id).Error
+	return user, notFound(err)
+}
+
+func (s gormUsers) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	var user models.User
+	err := s.db.WithContext(ctx).Where("email = ?", email).First(&user).Error
+	return user, notFound(err)
+}
+
+func (s gormUsers) GetByGitHubID(ctx context.Context, githubID int64) (models.User, error) {
+	var user models.User
+	err := s.db.WithContext(ctx).Where("github_id = ?", githubID).First(&user).Error
+	return user, notFound(err)
+}
+
+func (s gormUsers) GetByWebhookToken(ctx context.Context, token string, now time.Time) (models.User, error) {
+	var user models.User
+	err := s.db.WithContext(ctx).Where(webhookTokenQuery(s.db, token, now)).First(&user).Error
+	return user, notFound(err)
+}
+
+func (s gormUsers) List(ctx context.Context, filter UserFilter) ([]models.User, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.User{})
+	if q := strings.ToLower(strings.TrimSpace(filter.Query)); q != "" {
+		pattern := "%" + q + "%"
+		query = query.Where("LOWER(email) LIKE ? OR LOWER(name) LIKE ?", pattern, pattern)
+	}
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.Deactivated != nil {
+		if *filter.Deactivated {
+			query = query.Where("deactivated_at IS NOT NULL")
+		} else {
+			query = query.Where("deactivated_at IS NULL")
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []models.User
+	err := paginate(query.Order("id"), filter.Offset, filter.Limit).Find(&users).Error
+	return users, total, err
+}
+
+func (s gormUsers) Create(ctx context.Context, user *models.User) error {
+	return s.db.WithContext(ctx).Omit(clause.Associations).Create(user).Error
+}
+
+func (s gormUsers) Save(ctx context.Context, user *models.User) error {
+	return s.db.WithContext(ctx).Omit(clause.Associations).Save(user).Error
+}
+
+func (s gormUsers) IncrementFailedLogins(ctx context.Context, id uint) (int, error) {
+	// Строка заблокирована обновлением до конца транзакции, поэтому чтение
+	// возвращает значение после нашего увеличения
+	var failed int
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{ID: id}).
+			UpdateColumn("failed_logins", gorm.Expr("failed_logins + 1")).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", id).Pluck("failed_logins", &failed).Error
+	})
+	return failed, err
+}
+
+func (s gormUsers) SetLoginFailures(ctx context.Context, id uint, failed int, lockedUntil *time.Time) error {
+	return s.db.WithContext(ctx).Model(&models.User{ID: id}).
+		UpdateColumns(map[string]interface{}{"failed_logins": failed, "locked_until": lockedUntil}).Error
+}
+
+func (s gormUsers) MarkEmailVerified(ctx context.Context, id uint, at time.Time) error {
+	return s.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND email_verified_at IS NULL", id).
+		Update("email_verified_at", at).Error
+}
+
+func (s gormUsers) AdvanceTOTPStep(ctx context.Context, id uint, step int64) (bool, error) {
+	result := s.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND COALESCE(totp_last_step, 0) < ?", id, step).
+		UpdateColumn("totp_last_step", step)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (s gormUsers) SetRole(ctx context.Context, id uint, role string) error {
+	return s.db.WithContext(ctx).Model(&models.User{ID: id}).Update("role", role).Error
+}
+
+func (s gormUsers) SetPlan(ctx context.Context, id uint, plan string) error {
+	return s.db.WithContext(ctx).Model(&models.User{ID: id}).Update("plan", plan).Error
+}
+
+func (s gormUsers) SetDeactivatedAt(ctx context.Context, id uint, at *time.Time) error {
+	return s.db.WithContext(ctx).Model(&models.User{ID: id}).Update("deactivated_at", at).Error
+}
+
+func (s gormUsers) RotateWebhookToken(ctx context.Context, id uint, rotation WebhookRotation) error {
+	return s.db.WithContext(ctx).Model(&models.User{ID: id}).Updates(rotationUpdates(rotation)).Error
+}
+
+func (s gormUsers) PromoteAdmins(ctx context.Context, emails []string) (int64, error) {
+	result := s.db.WithContext(ctx).Model(&models.User{}).
+		Where("LOWER(email) IN ? AND role <> ?", emails, models.UserRoleAdmin).
+		Update("role", models.UserRoleAdmin)
+	return result.RowsAffected, result.Error
+}
+
+func (s gormUsers) Purge(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := purgeProjects(tx, "user_id = ? AND organization_id IS NULL", id); err != nil {
+			return err
+		}
+
+		sessionIDs := tx.Model(&models.Session{}).Select("id").Where("user_id = ?", id)
+		deliveryIDs := tx.Model(&models.Delivery{}).Select("id").Where("user_id = ?", id)
+
+		steps := []struct {
+			model interface{}
+			query string
+			args  interface{}
+		}{
+			{&models.RefreshToken{}, "session_id IN (?)", sessionIDs},
+			{&models.Session{}, "user_id = ?", id},
+			{&models.PersonalAccessToken{}, "user_id = ?", id},
+			{&models.UserToken{}, "user_id = ?", id},
+			{&models.RecoveryCode{}, "user_id = ?", id},
+			{&models.Post{}, "delivery_id IN (?)", deliveryIDs},
+			{&models.Delivery{}, "user_id = ?", id},
+			{&models.UsageCounter{}, "user_id = ?", id},
+			{&models.Membership{}, "user_id = ?", id},
+			{&models.UserSettings{}, "user_id = ?", id},
+		}
+		for _, step := range steps {
+			if err := tx.Unscoped().Where(step.query, step.args).Delete(step.model).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&models.AuditLog{}).
+			Where("actor_id = ? OR (target_type = ? AND target_id = ?)", id, "user", id).
+			Updates(map[string]interface{}{"ip": "", "user_agent": ""}).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(&models.User{}, id).Error
+	})
+}
+
+type gormSettings struct{ db *gorm.DB }
+
+func (s gormSettings) GetByUser(ctx context.Context, userID uint) (models.UserSettings, error) {
+	var settings models.UserSettings
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).First(&settings).Error
+	return settings, notFound(err)
+}
+
+func (s gormSettings) Create(ctx context.Context, settings *models.UserSettings) error {
+	return s.db.WithContext(ctx).Create(settings).Error
+}
+
+func (s gormSettings) Save(ctx context.Context, settings *models.UserSettings) error {
+	return s.db.WithContext(ctx).Save(settings).Error
+}
+
+func (s gormSettings) SetGitHubSecret(ctx context.Context, userID uint, secret string) error {
+	result := s.db.WithContext(ctx).Model(&models.UserSettings{}).Where("user_id = ?", userID).Update("git_hub_secret", secret)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type gormSessions struct{ db *gorm.DB }
+
+func (s gormSessions) Get(ctx context.Context, id uint) (models.Session, error) {
+	var session models.Session
+	err := s.db.WithContext(ctx).First(&session, id).Error
+	return session, notFound(err)
+}
+
+func (s gormSessions) ListByUser(ctx context.Context, userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&sessions).Error
+	return sessions, err
+}
+
+func (s gormSessions) Create(ctx context.Context, session *models.Session) error {
+	return s.db.WithContext(ctx).Omit(clause.Associations).Create(session).Error
+}
+
+func (s gormSessions) Save(ctx context.Context, session *models.Session) error {
+	return s.db.WithContext(ctx).Omit(clause.Associations).Save(session).Error
+}
+
+func (s gormSessions) Revoke(ctx context.Context, id uint, at time.Time) error {
+	return s.db.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", at).Error
+}
+
+func (s gormSessions) RevokeUser(ctx context.Context, userID uint, at time.Time) (int64, error) {
+	result := s.db.WithContext(ctx).Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", at)
+	return result.RowsAffected, result.Error
+}
+
+func (s gormSessions) CountActive(ctx context.Context, userID uint, now time.Time) (int64, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Count(&count).Error
+	return count, err
+}
+
+func (s gormSessions) LastLogins(ctx context.Context, userIDs []uint) (map[uint]time.Time, error) {
+	db := s.db.WithContext(ctx)
+
+	var sessions []models.Session
+	err := db.Where("id IN (?)", db.Model(&models.Session{}).
+		Select("MAX(id)").
+		Where("user_id IN ? AND impersonator_id IS NULL", userIDs).
+		Group("user_id")).
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	lastLogins := make(map[uint]time.Time, len(sessions))
+	for _, session := range sessions {
+		lastLogins[session.UserID] = session.CreatedAt
+	}
+	return lastLogins, nil
+}
+
+func (s gormSessions) GetRefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := s.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error
+	return token, notFound(err)
+}
+
+func (s gormSessions) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	return s.db.WithContext(ctx).Create(token).Error
+}
+
+func (s gormSessions) UseRefreshToken(ctx context.Context, id uint, at time.Time) (bool, error) {
+	result := s.db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", at)
+	return result.RowsAffected > 0, result.Error
+}
+
+type gormTokens struct{ db *gorm.DB }
+
+func (s gormTokens) Get(ctx context.Context, id uint) (models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	err := s.db.WithContext(ctx).First(&token, id).Error
+	return token, notFound(err)
+}
+
+func (s gormTokens) GetByHash(ctx context.Context, tokenHash string) (models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	err := s.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error
+	return token, notFound(err)
+}
+
+func (s gormTokens) ListByUser(ctx context.Context, userID uint) ([]models.PersonalAccessToken, error) {
+	tokens := []models.PersonalAccessToken{}
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&tokens).Error
+	return tokens, err
+}
+
+func (s gormTokens) Create(ctx context.Context, token *models.PersonalAccessToken) error {
+	return s.db.WithContext(ctx).Create(token).Error
+}
+
+func (s gormTokens) Revoke(ctx context.Context, id uint, at time.Time) error {
+	return s.db.WithContext(ctx).Model(&models.PersonalAccessToken{ID: id}).Update("revoked_at", at).Error
+}
+
+func (s gormTokens) RevokeUser(ctx context.Context, userID uint, at time.Time) (int64, error) {
+	result := s.db.WithContext(ctx).Model(&models.PersonalAccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", at)
+	return result.RowsAffected, result.Error
+}
+
+func (s gormTokens) Touch(ctx context.Context, id uint, at time.Time) error {
+	return s.db.WithContext(ctx).Model(&models.PersonalAccessToken{ID: id}).UpdateColumn("last_used_at", at).Error
+}
+
+type gormUserTokens struct{ db *gorm.DB }
+
+func (s gormUserTokens) GetByHash(ctx context.Context, tokenHash, purpose string) (models.UserToken, error) {
+	var token models.UserToken
+	err := s.db.WithContext(ctx).Where("token_hash = ? AND purpose = ?", tokenHash, purpose).First(&token).Error
+	return token, notFound(err)
+}
+
+func (s gormUserTokens) Create(ctx context.Context, token *models.UserToken) error {
+	return s.db.WithContext(ctx).Create(token).Error
+}
+
+func (s gormUserTokens) Use(ctx context.Context, id uint, at time.Time) (bool, error) {
+	result := s.db.WithContext(ctx).Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", at)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (s gormUserTokens) Invalidate(ctx context.Context, userID uint, purpose string, at time.Time) error {
+	return s.db.WithContext(ctx).Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", at).Error
+}
+
+type gormRecoveryCodes struct{ db *gorm.DB }
+
+func (s gormRecoveryCodes) Create(ctx context.Context, code *models.RecoveryCode) error {
+	return s.db.WithContext(ctx).Create(code).Error
+}
+
+func (s gormRecoveryCodes) Use(ctx context.Context, userID uint, codeHash string, at time.Time) (bool, error) {
+	result := s.db.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", at)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (s gormRecoveryCodes) DeleteByUser(ctx context.Context, userID uint) error {
+	return s.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+}
+
+// webhookTokenQuery — условие поиска по webhook токену: прежний токен после ротации
+// действует до истечения grace period
+func webhookTokenQuery(db *gorm.DB, token string, now time.Time) *gorm.DB {
+	return db.Where("webhook_token = ?", token).
+		Or("previous_webhook_token = ? AND previous_webhook_token_expires_at > ?", token, now)
+}
+
+func rotationUpdates(rotation WebhookRotation) map[string]interface{} {
+	return map[string]interface{}{
+		"webhook_token":                     rotation.Token,
+		"previous_webhook_token":            rotation.PreviousToken,
+		"previous_webhook_token_expires_at": rotation.PreviousExpiresAt,
+	}
+}
+
+// paginate применяет offset и limit; нулевой limit не ограничивает выборку
+func paginate(query *gorm.DB, offset, limit int) *gorm.DB {
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	return query
+}
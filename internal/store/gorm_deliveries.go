@@ -0,0 +1,201 @@
+package store
+
+import (
+	"commitcaster/internal/models"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormDeliveries struct{ db *gorm.DB }
+
+func (s gormDeliveries) Create(ctx context.Context, delivery *models.Delivery) error {
+	return s.db.WithContext(ctx).Create(delivery).Error
+}
+
+func (s gormDeliveries) ListByStatus(ctx context.Context, status string) ([]models.Delivery, error) {
+	var deliveries []models.Delivery
+	err := s.db.WithContext(ctx).Where("status = ?", status).Order("id").Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (s gormDeliveries) ListByUser(ctx context.Context, userID uint) ([]models.Delivery, error) {
+	deliveries := []models.Delivery{}
+	err := s.db.WithContext(ctx).Preload("Posts").Where("user_id = ?", userID).Order("id").Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (s gormDeliveries) ListRecent(ctx context.Context, userID uint, limit int) ([]models.Delivery, error) {
+	deliveries := []models.Delivery{}
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("id DESC").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (s gormDeliveries) Claim(ctx context.Context, id uint, from, to string) (bool, error) {
+	result := s.db.WithContext(ctx).Model(&models.Delivery{}).
+		Where("id = ? AND status = ?", id, from).
+		Update("status", to)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (s gormDeliveries) UpdateStatus(ctx context.Context, delivery *models.Delivery) error {
+	return s.db.WithContext(ctx).Model(delivery).Updates(map[string]interface{}{
+		"status":  delivery.Status,
+		"error":   delivery.Error,
+		"payload": delivery.Payload,
+		"post":    delivery.Post,
+	}).Error
+}
+
+func (s gormDeliveries) Stats(ctx context.Context, userIDs []uint) (map[uint]DeliveryStats, error) {
+	var rows []struct {
+		UserID uint
+		Total  int64
+		Failed int64
+	}
+	err := s.db.WithContext(ctx).Model(&models.Delivery{}).
+		Select("user_id, COUNT(*) AS total, SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS failed", models.DeliveryFailed).
+		Where("user_id IN ?", userIDs).
+		Group("user_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[uint]DeliveryStats, len(rows))
+	for _, row := range rows {
+		stats[row.UserID] = DeliveryStats{Total: row.Total, Failed: row.Failed}
+	}
+	return stats, nil
+}
+
+type gormPosts struct{ db *gorm.DB }
+
+func (s gormPosts) Create(ctx context.Context, post *models.Post) error {
+	return s.db.WithContext(ctx).Create(post).Error
+}
+
+func (s gormPosts) ListByDelivery(ctx context.Context, deliveryID uint, status string) ([]models.Post, error) {
+	var posts []models.Post
+	err := s.db.WithContext(ctx).Where("delivery_id = ? AND status = ?", deliveryID, status).Order("id").Find(&posts).Error
+	return posts, err
+}
+
+type gormUsage struct{ db *gorm.DB }
+
+func (s gormUsage) Add(ctx context.Context, userID uint, period string, periodStart time.Time, counts models.UsageCounts, now time.Time) error {
+	counter := models.UsageCounter{
+		UserID:      userID,
+		Period:      period,
+		PeriodStart: periodStart,
+		UsageCounts: counts,
+		UpdatedAt:   now,
+	}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "period"}, {Name: "period_start"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"posts":             gorm.Expr("usage_counters.posts + ?", counts.Posts),
+			"ai_requests":       gorm.Expr("usage_counters.ai_requests + ?", counts.AIRequests),
+			"prompt_tokens":     gorm.Expr("usage_counters.prompt_tokens + ?", counts.PromptTokens),
+			"completion_tokens": gorm.Expr("usage_counters.completion_tokens + ?", counts.CompletionTokens),
+			"telegram_sends":    gorm.Expr("usage_counters.telegram_sends + ?", counts.TelegramSends),
+			"updated_at":        now,
+		}),
+	}).Create(&counter).Error
+}
+
+func (s gormUsage) ReserveAIRequest(ctx context.Context, userID uint, period string, periodStart time.Time, limit int64, now time.Time) (bool, error) {
+	// Строка счётчика создаётся заранее, чтобы условное увеличение было одним UPDATE
+	if err := s.Add(ctx, userID, period, periodStart, models.UsageCounts{}, now); err != nil {
+		return false, err
+	}
+	query := s.db.WithContext(ctx).Model(&models.UsageCounter{}).
+		Where("user_id = ? AND period = ? AND period_start = ?", userID, period, periodStart)
+	if limit > 0 {
+		query = query.Where("ai_requests < ?", limit)
+	}
+	result := query.UpdateColumns(map[string]interface{}{
+		"ai_requests": gorm.Expr("ai_requests + 1"),
+		"updated_at":  now,
+	})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (s gormUsage) Get(ctx context.Context, userID uint, period string, periodStart time.Time) (models.UsageCounter, error) {
+	counter := models.UsageCounter{UserID: userID, Period: period, PeriodStart: periodStart}
+	err := s.db.WithContext(ctx).
+		Where("user_id = ? AND period = ? AND period_start = ?", userID, period, periodStart).
+		Limit(1).Find(&counter).Error
+	return counter, err
+}
+
+func (s gormUsage) List(ctx context.Context, userID uint, period string, since time.Time) ([]models.UsageCounter, error) {
+	query := s.db.WithContext(ctx).Where("user_id = ?", userID)
+	if period != "" {
+		query = query.Where("period = ?", period)
+	}
+	if !since.IsZero() {
+		query = query.Where("period_start > ?", since)
+	}
+
+	counters := []models.UsageCounter{}
+	err := query.Order("period, period_start").Find(&counters).Error
+	return counters, err
+}
+
+type gormAudit struct{ db *gorm.DB }
+
+func (s gormAudit) Create(ctx context.Context, entry *models.AuditLog) error {
+	return s.db.WithContext(ctx).Create(entry).Error
+}
+
+func (s gormAudit) List(ctx context.Context, filter AuditFilter) ([]models.AuditLog, int64, error) {
+	db := s.db.WithContext(ctx)
+	query := db.Model(&models.AuditLog{})
+
+	if filter.VisibleTo != 0 {
+		orgIDs := db.Model(&models.Membership{}).Select("organization_id").Where("user_id = ?", filter.VisibleTo)
+		projectIDs := db.Unscoped().Model(&models.Project{}).Select("id").
+			Where("(user_id = ? AND organization_id IS NULL) OR organization_id IN (?)", filter.VisibleTo, orgIDs)
+		query = query.Where(
+			"actor_id = ? OR (target_type = ? AND target_id = ?) OR (target_type = ? AND target_id IN (?)) OR (target_type = ? AND target_id IN (?))",
+			filter.VisibleTo, "user", filter.VisibleTo, "project", projectIDs, "organization", orgIDs,
+		)
+	}
+	if filter.Subject != 0 {
+		query = query.Where("actor_id = ? OR (target_type = ? AND target_id = ?)", filter.Subject, "user", filter.Subject)
+	}
+	if filter.ActorID != nil {
+		query = query.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != nil {
+		query = query.Where("target_id = ?", *filter.TargetID)
+	}
+	if filter.Since != nil {
+		query = query.Where("created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("created_at < ?", *filter.Until)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	order := "id DESC"
+	if filter.OldestFirst {
+		order = "id"
+	}
+	var entries []models.AuditLog
+	err := paginate(query.Order(order), filter.Offset, filter.Limit).Find(&entries).Error
+	return entries, total, err
+}
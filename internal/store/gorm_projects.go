@@ -0,0 +1,220 @@
+package store
+
+import (
+	"commitcaster/internal/models"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormOrganizations struct{ db *gorm.DB }
+
+func (s gormOrganizations) Get(ctx context.Context, id uint) (models.Organization, error) {
+	var org models.Organization
+	err := s.db.WithContext(ctx).First(&org, id).Error
+	return org, notFound(err)
+}
+
+func (s gormOrganizations) Create(ctx context.Context, org *models.Organization) error {
+	return s.db.WithContext(ctx).Create(org).Error
+}
+
+func (s gormOrganizations) Save(ctx context.Context, org *models.Organization) error {
+	return s.db.WithContext(ctx).Omit(clause.Associations).Save(org).Error
+}
+
+func (s gormOrganizations) Delete(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var projects []models.Project
+		if err := tx.Where("organization_id = ?", id).Find(&projects).Error; err != nil {
+			return err
+		}
+		for i := range projects {
+			if err := tx.Select("Destinations", "RoutingRules").Delete(&projects[i]).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Select("Members").Delete(&models.Organization{ID: id}).Error
+	})
+}
+
+func (s gormOrganizations) Purge(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := purgeProjects(tx, "organization_id = ?", id); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("organization_id = ?", id).Delete(&models.Membership{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&models.Organization{}, id).Error
+	})
+}
+
+func (s gormOrganizations) GetMembership(ctx context.Context, orgID, userID uint) (models.Membership, error) {
+	var membership models.Membership
+	err := s.db.WithContext(ctx).Where("organization_id = ? AND user_id = ?", orgID, userID).First(&membership).Error
+	return membership, notFound(err)
+}
+
+func (s gormOrganizations) ListMemberships(ctx context.Context, userID uint) ([]models.Membership, error) {
+	var memberships []models.Membership
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("organization_id").Find(&memberships).Error
+	return memberships, err
+}
+
+func (s gormOrganizations) ListMembers(ctx context.Context, orgID uint) ([]Member, error) {
+	var members []Member
+	err := s.db.WithContext(ctx).Table("memberships").
+		Select("memberships.user_id, users.email, users.name, memberships.role, memberships.created_at").
+		Joins("JOIN users ON users.id = memberships.user_id AND users.deleted_at IS NULL").
+		Where("memberships.organization_id = ?", orgID).
+		Order("memberships.id").
+		Scan(&members).Error
+	return members, err
+}
+
+func (s gormOrganizations) CreateMembership(ctx context.Context, membership *models.Membership) error {
+	return s.db.WithContext(ctx).Create(membership).Error
+}
+
+func (s gormOrganizations) SaveMembership(ctx context.Context, membership *models.Membership) error {
+	return s.db.WithContext(ctx).Save(membership).Error
+}
+
+func (s gormOrganizations) DeleteMembership(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Delete(&models.Membership{}, id).Error
+}
+
+func (s gormOrganizations) CountMembers(ctx context.Context, orgID uint, role string) (int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.Membership{}).Where("organization_id = ?", orgID)
+	if role != "" {
+		query = query.Where("role = ?", role)
+	}
+	var count int64
+	err := query.Count(&count).Error
+	return count, err
+}
+
+func (s gormOrganizations) FirstOwner(ctx context.Context, orgID, exceptUserID uint) (models.Membership, error) {
+	var owner models.Membership
+	err := s.db.WithContext(ctx).
+		Where("organization_id = ? AND role = ? AND user_id <> ?", orgID, models.RoleOwner, exceptUserID).
+		Order("id").First(&owner).Error
+	return owner, notFound(err)
+}
+
+type gormProjects struct{ db *gorm.DB }
+
+// preload загружает проект с каналами и правилами маршрутизации по порядку применения
+func (s gormProjects) preload(ctx context.Context) *gorm.DB {
+	return s.db.WithContext(ctx).Preload("Destinations").Preload("RoutingRules", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position, id")
+	})
+}
+
+func (s gormProjects) Get(ctx context.Context, id uint) (models.Project, error) {
+	var project models.Project
+	err := s.preload(ctx).First(&project, id).Error
+	return project, notFound(err)
+}
+
+func (s gormProjects) GetByWebhookToken(ctx context.Context, token string, now time.Time) (models.Project, error) {
+	var project models.Project
+	err := s.preload(ctx).Where(webhookTokenQuery(s.db, token, now)).First(&project).Error
+	return project, notFound(err)
+}
+
+func (s gormProjects) ListForUser(ctx context.Context, userID uint) ([]models.Project, error) {
+	orgIDs := s.db.Model(&models.Membership{}).Select("organization_id").Where("user_id = ?", userID)
+
+	projects := []models.Project{}
+	err := s.preload(ctx).
+		Where("(user_id = ? AND organization_id IS NULL) OR organization_id IN (?)", userID, orgIDs).
+		Order("id").
+		Find(&projects).Error
+	return projects, err
+}
+
+func (s gormProjects) ListByUser(ctx context.Context, userID uint) ([]models.Project, error) {
+	projects := []models.Project{}
+	err := s.preload(ctx).Where("user_id = ?", userID).Order("id").Find(&projects).Error
+	return projects, err
+}
+
+func (s gormProjects) Create(ctx context.Context, project *models.Project) error {
+	return s.db.WithContext(ctx).Create(project).Error
+}
+
+func (s gormProjects) Save(ctx context.Context, project *models.Project) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var keepDestinations, keepRules []uint
+		for i := range project.Destinations {
+			project.Destinations[i].ProjectID = project.ID
+			if id := project.Destinations[i].ID; id != 0 {
+				keepDestinations = append(keepDestinations, id)
+			}
+		}
+		for i := range project.RoutingRules {
+			project.RoutingRules[i].ProjectID = project.ID
+			if id := project.RoutingRules[i].ID; id != 0 {
+				keepRules = append(keepRules, id)
+			}
+		}
+
+		if err := deleteExcept(tx, &models.Destination{}, project.ID, keepDestinations); err != nil {
+			return err
+		}
+		if err := deleteExcept(tx, &models.RoutingRule{}, project.ID, keepRules); err != nil {
+			return err
+		}
+		return tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(project).Error
+	})
+}
+
+func (s gormProjects) Delete(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Select("Destinations", "RoutingRules").Delete(&models.Project{ID: id}).Error
+}
+
+func (s gormProjects) SetGitHubSecret(ctx context.Context, id uint, secret string) error {
+	return s.db.WithContext(ctx).Model(&models.Project{ID: id}).Update("git_hub_secret", secret).Error
+}
+
+func (s gormProjects) RotateWebhookToken(ctx context.Context, id uint, rotation WebhookRotation) error {
+	return s.db.WithContext(ctx).Model(&models.Project{ID: id}).Updates(rotationUpdates(rotation)).Error
+}
+
+func (s gormProjects) TransferOwnership(ctx context.Context, orgID, fromUserID, toUserID uint) error {
+	return s.db.WithContext(ctx).Unscoped().Model(&models.Project{}).
+		Where("organization_id = ? AND user_id = ?", orgID, fromUserID).
+		Update("user_id", toUserID).Error
+}
+
+// deleteExcept удаляет записи проекта, кроме записей с id из keep
+func deleteExcept(tx *gorm.DB, model interface{}, projectID uint, keep []uint) error {
+	query := tx.Where("project_id = ?", projectID)
+	if len(keep) > 0 {
+		query = query.Where("id NOT IN ?", keep)
+	}
+	return query.Delete(model).Error
+}
+
+// purgeProjects безвозвратно удаляет проекты, выбранные запросом, с каналами и правилами
+func purgeProjects(tx *gorm.DB, query string, args ...interface{}) error {
+	var projectIDs []uint
+	if err := tx.Unscoped().Model(&models.Project{}).Where(query, args...).Pluck("id", &projectIDs).Error; err != nil {
+		return err
+	}
+	if len(projectIDs) == 0 {
+		return nil
+	}
+
+	if err := tx.Where("project_id IN ?", projectIDs).Delete(&models.Destination{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("project_id IN ?", projectIDs).Delete(&models.RoutingRule{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("id IN ?", projectIDs).Delete(&models.Project{}).Error
+}
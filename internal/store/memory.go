@@ -0,0 +1,761 @@
+package store
+
+import (
+	"commitcaster/internal/models"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// errDuplicate — нарушение уникальности, как unique index в БД
+var errDuplicate = errors.New("duplicate key value violates unique constraint")
+
+// MemoryURL — значение DATABASE_URL для хранилища в памяти процесса
+const MemoryURL = "memory://"
+
+// NewMemory возвращает хранилища в памяти процесса: данные теряются при перезапуске.
+// Подходит для разработки и тестов без PostgreSQL
+func NewMemory() *Store {
+	return newMemoryStore(&memory{mu: &sync.Mutex{}, data: newMemoryData()})
+}
+
+func newMemoryStore(m *memory) *Store {
+	return &Store{
+		Users:         memoryUsers{m},
+		Settings:      memorySettings{m},
+		Sessions:      memorySessions{m},
+		Tokens:        memoryTokens{m},
+		UserTokens:    memoryUserTokens{m},
+		RecoveryCodes: memoryRecoveryCodes{m},
+		Organizations: memoryOrganizations{m},
+		Projects:      memoryProjects{m},
+		Deliveries:    memoryDeliveries{m},
+		Posts:         memoryPosts{m},
+		Usage:         memoryUsage{m},
+		Audit:         memoryAudit{m},
+
+		transaction: m.transaction,
+	}
+}
+
+// memory — общее состояние хранилищ в памяти. Транзакция держит блокировку до конца
+// и работает с копией данных, которая заменяет данные только при успешном завершении
+type memory struct {
+	mu   *sync.Mutex
+	data *memoryData
+	inTx bool
+}
+
+func (m *memory) lock() func() {
+	if m.inTx {
+		return func() {}
+	}
+	m.mu.Lock()
+	return m.mu.Unlock
+}
+
+func (m *memory) transaction(ctx context.Context, fn func(tx *Store) error) error {
+	if m.inTx {
+		return fn(newMemoryStore(m))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx := &memory{mu: m.mu, data: m.data.clone(), inTx: true}
+	if err := fn(newMemoryStore(tx)); err != nil {
+		return err
+	}
+	*m.data = *tx.data
+	return nil
+}
+
+// table — таблица с автоинкрементным id. Строки хранятся без связанных записей
+type table[T any] struct {
+	rows map[uint]T
+	last uint
+}
+
+func newTable[T any]() *table[T] {
+	return &table[T]{rows: make(map[uint]T)}
+}
+
+func (t *table[T]) next() uint {
+	t.last++
+	return t.last
+}
+
+func (t *table[T]) clone() *table[T] {
+	c := &table[T]{rows: make(map[uint]T, len(t.rows)), last: t.last}
+	for id, row := range t.rows {
+		c.rows[id] = row
+	}
+	return c
+}
+
+// filter возвращает подходящие строки по возрастанию id
+func (t *table[T]) filter(match func(T) bool) []T {
+	ids := make([]uint, 0, len(t.rows))
+	for id, row := range t.rows {
+		if match == nil || match(row) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	rows := make([]T, len(ids))
+	for i, id := range ids {
+		rows[i] = t.rows[id]
+	}
+	return rows
+}
+
+// find возвращает первую по id подходящую строку
+func (t *table[T]) find(match func(T) bool) (T, error) {
+	if rows := t.filter(match); len(rows) > 0 {
+		return rows[0], nil
+	}
+	var zero T
+	return zero, ErrNotFound
+}
+
+// deleteWhere удаляет подходящие строки и возвращает их число
+func (t *table[T]) deleteWhere(match func(T) bool) int64 {
+	var n int64
+	for id, row := range t.rows {
+		if match(row) {
+			delete(t.rows, id)
+			n++
+		}
+	}
+	return n
+}
+
+type memoryData struct {
+	users         *table[models.User]
+	settings      *table[models.UserSettings]
+	sessions      *table[models.Session]
+	refreshTokens *table[models.RefreshToken]
+	tokens        *table[models.PersonalAccessToken]
+	userTokens    *table[models.UserToken]
+	recoveryCodes *table[models.RecoveryCode]
+	organizations *table[models.Organization]
+	memberships   *table[models.Membership]
+	projects      *table[models.Project]
+	destinations  *table[models.Destination]
+	routingRules  *table[models.RoutingRule]
+	deliveries    *table[models.Delivery]
+	posts         *table[models.Post]
+	usage         *table[models.UsageCounter]
+	audit         *table[models.AuditLog]
+}
+
+func newMemoryData() *memoryData {
+	return &memoryData{
+		users:         newTable[models.User](),
+		settings:      newTable[models.UserSettings](),
+		sessions:      newTable[models.Session](),
+		refreshTokens: newTable[models.RefreshToken](),
+		tokens:        newTable[models.PersonalAccessToken](),
+		userTokens:    newTable[models.UserToken](),
+		recoveryCodes: newTable[models.RecoveryCode](),
+		organizations: newTable[models.Organization](),
+		memberships:   newTable[models.Membership](),
+		projects:      newTable[models.Project](),
+		destinations:  newTable[models.Destination](),
+		routingRules:  newTable[models.RoutingRule](),
+		deliveries:    newTable[models.Delivery](),
+		posts:         newTable[models.Post](),
+		usage:         newTable[models.UsageCounter](),
+		audit:         newTable[models.AuditLog](),
+	}
+}
+
+func (d *memoryData) clone() *memoryData {
+	return &memoryData{
+		users:         d.users.clone(),
+		settings:      d.settings.clone(),
+		sessions:      d.sessions.clone(),
+		refreshTokens: d.refreshTokens.clone(),
+		tokens:        d.tokens.clone(),
+		userTokens:    d.userTokens.clone(),
+		recoveryCodes: d.recoveryCodes.clone(),
+		organizations: d.organizations.clone(),
+		memberships:   d.memberships.clone(),
+		projects:      d.projects.clone(),
+		destinations:  d.destinations.clone(),
+		routingRules:  d.routingRules.clone(),
+		deliveries:    d.deliveries.clone(),
+		posts:         d.posts.clone(),
+		usage:         d.usage.clone(),
+		audit:         d.audit.clone(),
+	}
+}
+
+// touch заполняет CreatedAt, если он не задан, и обновляет UpdatedAt, как GORM
+func touch(createdAt, updatedAt *time.Time) {
+	now := time.Now()
+	if createdAt != nil && createdAt.IsZero() {
+		*createdAt = now
+	}
+	if updatedAt != nil {
+		*updatedAt = now
+	}
+}
+
+func softDelete(at *gorm.DeletedAt) {
+	*at = gorm.DeletedAt{Time: time.Now(), Valid: true}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+type memoryUsers struct{ *memory }
+
+func (s memoryUsers) Get(ctx context.Context, id uint) (models.User, error) {
+	defer s.lock()()
+	return s.get(id)
+}
+
+func (s memoryUsers) get(id uint) (models.User, error) {
+	user, ok := s.data.users.rows[id]
+	if !ok || user.DeletedAt.Valid {
+		return models.User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (s memoryUsers) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	defer s.lock()()
+	return s.data.users.find(func(u models.User) bool {
+		return !u.DeletedAt.Valid && u.Email == email
+	})
+}
+
+func (s memoryUsers) GetByGitHubID(ctx context.Context, githubID int64) (models.User, error) {
+	defer s.lock()()
+	return s.data.users.find(func(u models.User) bool {
+		return !u.DeletedAt.Valid && u.GitHubID != nil && *u.GitHubID == githubID
+	})
+}
+
+func (s memoryUsers) GetByWebhookToken(ctx context.Context, token string, now time.Time) (models.User, error) {
+	defer s.lock()()
+	return s.data.users.find(func(u models.User) bool {
+		return !u.DeletedAt.Valid && matchesWebhookToken(token, now, u.WebhookToken, u.PreviousWebhookToken, u.PreviousWebhookTokenExpiresAt)
+	})
+}
+
+func (s memoryUsers) List(ctx context.Context, filter UserFilter) ([]models.User, int64, error) {
+	defer s.lock()()
+	q := strings.ToLower(strings.TrimSpace(filter.Query))
+	users := s.data.users.filter(func(u models.User) bool {
+		switch {
+		case u.DeletedAt.Valid:
+			return false
+		case q != "" && !strings.Contains(strings.ToLower(u.Email), q) && !strings.Contains(strings.ToLower(u.Name), q):
+			return false
+		case filter.Role != "" && u.Role != filter.Role:
+			return false
+		case filter.Deactivated != nil && *filter.Deactivated != (u.DeactivatedAt != nil):
+			return false
+		}
+		return true
+	})
+	return page(users, filter.Offset, filter.Limit), int64(len(users)), nil
+}
+
+func (s memoryUsers) Create(ctx context.Context, user *models.User) error {
+	defer s.lock()()
+	for _, u := range s.data.users.rows {
+		if u.Email == user.Email || u.WebhookToken == user.WebhookToken ||
+			(user.GitHubID != nil && u.GitHubID != nil && *u.GitHubID == *user.GitHubID) {
+			return errDuplicate
+		}
+	}
+	if user.Role == "" {
+		user.Role = models.UserRoleUser
+	}
+	user.ID = s.data.users.next()
+	touch(&user.CreatedAt, &user.UpdatedAt)
+	s.put(*user)
+	return nil
+}
+
+func (s memoryUsers) Save(ctx context.Context, user *models.User) error {
+	defer s.lock()()
+	if _, ok := s.data.users.rows[user.ID]; !ok {
+		return ErrNotFound
+	}
+	touch(&user.CreatedAt, &user.UpdatedAt)
+	s.put(*user)
+	return nil
+}
+
+func (s memoryUsers) put(user models.User) {
+	user.Settings = models.UserSettings{}
+	s.data.users.rows[user.ID] = user
+}
+
+// update изменяет пользователя fn; удалённые и отсутствующие пропускаются, как UPDATE в БД
+func (s memoryUsers) update(id uint, fn func(u *models.User)) error {
+	defer s.lock()()
+	user, err := s.get(id)
+	if err != nil {
+		return nil
+	}
+	fn(&user)
+	s.data.users.rows[id] = user
+	return nil
+}
+
+func (s memoryUsers) IncrementFailedLogins(ctx context.Context, id uint) (int, error) {
+	var failed int
+	err := s.update(id, func(u *models.User) {
+		u.FailedLogins++
+		failed = u.FailedLogins
+	})
+	return failed, err
+}
+
+func (s memoryUsers) SetLoginFailures(ctx context.Context, id uint, failed int, lockedUntil *time.Time) error {
+	return s.update(id, func(u *models.User) {
+		u.FailedLogins = failed
+		u.LockedUntil = lockedUntil
+	})
+}
+
+func (s memoryUsers) MarkEmailVerified(ctx context.Context, id uint, at time.Time) error {
+	return s.update(id, func(u *models.User) {
+		if u.EmailVerifiedAt == nil {
+			u.EmailVerifiedAt = timePtr(at)
+			u.UpdatedAt = time.Now()
+		}
+	})
+}
+
+func (s memoryUsers) AdvanceTOTPStep(ctx context.Context, id uint, step int64) (bool, error) {
+	defer s.lock()()
+	user, err := s.get(id)
+	if err != nil || user.TOTPLastStep >= step {
+		return false, nil
+	}
+	user.TOTPLastStep = step
+	s.data.users.rows[id] = user
+	return true, nil
+}
+
+func (s memoryUsers) SetRole(ctx context.Context, id uint, role string) error {
+	return s.update(id, func(u *models.User) {
+		u.Role = role
+		u.UpdatedAt = time.Now()
+	})
+}
+
+func (s memoryUsers) SetPlan(ctx context.Context, id uint, plan string) error {
+	return s.update(id, func(u *models.User) {
+		u.Plan = plan
+		u.UpdatedAt = time.Now()
+	})
+}
+
+func (s memoryUsers) SetDeactivatedAt(ctx context.Context, id uint, at *time.Time) error {
+	return s.update(id, func(u *models.User) {
+		u.DeactivatedAt = at
+		u.UpdatedAt = time.Now()
+	})
+}
+
+func (s memoryUsers) RotateWebhookToken(ctx context.Context, id uint, rotation WebhookRotation) error {
+	return s.update(id, func(u *models.User) {
+		u.WebhookToken = rotation.Token
+		u.PreviousWebhookToken = rotation.PreviousToken
+		u.PreviousWebhookTokenExpiresAt = rotation.PreviousExpiresAt
+		u.UpdatedAt = time.Now()
+	})
+}
+
+func (s memoryUsers) PromoteAdmins(ctx context.Context, emails []string) (int64, error) {
+	defer s.lock()()
+	var promoted int64
+	for id, user := range s.data.users.rows {
+		if user.DeletedAt.Valid || user.Role == models.UserRoleAdmin {
+			continue
+		}
+		for _, email := range emails {
+			if strings.ToLower(user.Email) == email {
+				user.Role = models.UserRoleAdmin
+				user.UpdatedAt = time.Now()
+				s.data.users.rows[id] = user
+				promoted++
+				break
+			}
+		}
+	}
+	return promoted, nil
+}
+
+func (s memoryUsers) Purge(ctx context.Context, id uint) error {
+	defer s.lock()()
+	d := s.data
+
+	purgeMemoryProjects(d, func(p models.Project) bool { return p.UserID == id && p.OrganizationID == nil })
+
+	sessionIDs := make(map[uint]bool)
+	for _, session := range d.sessions.filter(func(session models.Session) bool { return session.UserID == id }) {
+		sessionIDs[session.ID] = true
+	}
+	deliveryIDs := make(map[uint]bool)
+	for _, delivery := range d.deliveries.filter(func(delivery models.Delivery) bool { return delivery.UserID == id }) {
+		deliveryIDs[delivery.ID] = true
+	}
+
+	d.refreshTokens.deleteWhere(func(t models.RefreshToken) bool { return sessionIDs[t.SessionID] })
+	d.sessions.deleteWhere(func(session models.Session) bool { return session.UserID == id })
+	d.tokens.deleteWhere(func(t models.PersonalAccessToken) bool { return t.UserID == id })
+	d.userTokens.deleteWhere(func(t models.UserToken) bool { return t.UserID == id })
+	d.recoveryCodes.deleteWhere(func(c models.RecoveryCode) bool { return c.UserID == id })
+	d.posts.deleteWhere(func(p models.Post) bool { return deliveryIDs[p.DeliveryID] })
+	d.deliveries.deleteWhere(func(delivery models.Delivery) bool { return delivery.UserID == id })
+	d.usage.deleteWhere(func(c models.UsageCounter) bool { return c.UserID == id })
+	d.memberships.deleteWhere(func(m models.Membership) bool { return m.UserID == id })
+	d.settings.deleteWhere(func(settings models.UserSettings) bool { return settings.UserID == id })
+
+	for entryID, entry := range d.audit.rows {
+		if isUserSubject(entry, id) {
+			entry.IP = ""
+			entry.UserAgent = ""
+			d.audit.rows[entryID] = entry
+		}
+	}
+
+	delete(d.users.rows, id)
+	return nil
+}
+
+type memorySettings struct{ *memory }
+
+func (s memorySettings) GetByUser(ctx context.Context, userID uint) (models.UserSettings, error) {
+	defer s.lock()()
+	return s.data.settings.find(func(settings models.UserSettings) bool { return settings.UserID == userID })
+}
+
+func (s memorySettings) Create(ctx context.Context, settings *models.UserSettings) error {
+	defer s.lock()()
+	return s.create(settings)
+}
+
+func (s memorySettings) create(settings *models.UserSettings) error {
+	for _, existing := range s.data.settings.rows {
+		if existing.UserID == settings.UserID {
+			return errDuplicate
+		}
+	}
+	// Нулевые значения заменяются значениями по умолчанию столбцов, как при INSERT через GORM
+	if !settings.IsActive {
+		settings.IsActive = true
+	}
+	if settings.AIModel == "" {
+		settings.AIModel = "llama-3.3-70b-versatile"
+	}
+	if settings.PostLanguage == "" {
+		settings.PostLanguage = "ru"
+	}
+	if settings.MaxCommits == 0 {
+		settings.MaxCommits = 5
+	}
+	settings.ID = s.data.settings.next()
+	touch(&settings.CreatedAt, &settings.UpdatedAt)
+	s.data.settings.rows[settings.ID] = *settings
+	return nil
+}
+
+func (s memorySettings) Save(ctx context.Context, settings *models.UserSettings) error {
+	defer s.lock()()
+	if settings.ID == 0 {
+		return s.create(settings)
+	}
+	touch(&settings.CreatedAt, &settings.UpdatedAt)
+	s.data.settings.rows[settings.ID] = *settings
+	return nil
+}
+
+func (s memorySettings) SetGitHubSecret(ctx context.Context, userID uint, secret string) error {
+	defer s.lock()()
+	settings, err := s.data.settings.find(func(settings models.UserSettings) bool { return settings.UserID == userID })
+	if err != nil {
+		return err
+	}
+	settings.GitHubSecret = secret
+	settings.UpdatedAt = time.Now()
+	s.data.settings.rows[settings.ID] = settings
+	return nil
+}
+
+type memorySessions struct{ *memory }
+
+func (s memorySessions) Get(ctx context.Context, id uint) (models.Session, error) {
+	defer s.lock()()
+	session, ok := s.data.sessions.rows[id]
+	if !ok {
+		return models.Session{}, ErrNotFound
+	}
+	return session, nil
+}
+
+func (s memorySessions) ListByUser(ctx context.Context, userID uint) ([]models.Session, error) {
+	defer s.lock()()
+	return s.data.sessions.filter(func(session models.Session) bool { return session.UserID == userID }), nil
+}
+
+func (s memorySessions) Create(ctx context.Context, session *models.Session) error {
+	defer s.lock()()
+	session.ID = s.data.sessions.next()
+	touch(&session.CreatedAt, &session.UpdatedAt)
+	s.put(*session)
+	return nil
+}
+
+func (s memorySessions) Save(ctx context.Context, session *models.Session) error {
+	defer s.lock()()
+	if session.ID == 0 {
+		session.ID = s.data.sessions.next()
+	}
+	touch(&session.CreatedAt, &session.UpdatedAt)
+	s.put(*session)
+	return nil
+}
+
+func (s memorySessions) put(session models.Session) {
+	session.RefreshTokens = nil
+	s.data.sessions.rows[session.ID] = session
+}
+
+func (s memorySessions) Revoke(ctx context.Context, id uint, at time.Time) error {
+	defer s.lock()()
+	if session, ok := s.data.sessions.rows[id]; ok && session.RevokedAt == nil {
+		session.RevokedAt = timePtr(at)
+		session.UpdatedAt = time.Now()
+		s.data.sessions.rows[id] = session
+	}
+	return nil
+}
+
+func (s memorySessions) RevokeUser(ctx context.Context, userID uint, at time.Time) (int64, error) {
+	defer s.lock()()
+	var revoked int64
+	for id, session := range s.data.sessions.rows {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = timePtr(at)
+			session.UpdatedAt = time.Now()
+			s.data.sessions.rows[id] = session
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+func (s memorySessions) CountActive(ctx context.Context, userID uint, now time.Time) (int64, error) {
+	defer s.lock()()
+	active := s.data.sessions.filter(func(session models.Session) bool {
+		return session.UserID == userID && session.RevokedAt == nil && session.ExpiresAt.After(now)
+	})
+	return int64(len(active)), nil
+}
+
+func (s memorySessions) LastLogins(ctx context.Context, userIDs []uint) (map[uint]time.Time, error) {
+	defer s.lock()()
+	wanted := make(map[uint]bool, len(userIDs))
+	for _, id := range userIDs {
+		wanted[id] = true
+	}
+
+	// Сессии по возрастанию id: последняя перезаписывает предыдущие
+	lastLogins := make(map[uint]time.Time)
+	for _, session := range s.data.sessions.filter(func(session models.Session) bool {
+		return wanted[session.UserID] && session.ImpersonatorID == nil
+	}) {
+		lastLogins[session.UserID] = session.CreatedAt
+	}
+	return lastLogins, nil
+}
+
+func (s memorySessions) GetRefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error) {
+	defer s.lock()()
+	return s.data.refreshTokens.find(func(t models.RefreshToken) bool { return t.TokenHash == tokenHash })
+}
+
+func (s memorySessions) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	defer s.lock()()
+	token.ID = s.data.refreshTokens.next()
+	touch(&token.CreatedAt, nil)
+	s.data.refreshTokens.rows[token.ID] = *token
+	return nil
+}
+
+func (s memorySessions) UseRefreshToken(ctx context.Context, id uint, at time.Time) (bool, error) {
+	defer s.lock()()
+	token, ok := s.data.refreshTokens.rows[id]
+	if !ok || token.UsedAt != nil {
+		return false, nil
+	}
+	token.UsedAt = timePtr(at)
+	s.data.refreshTokens.rows[id] = token
+	return true, nil
+}
+
+type memoryTokens struct{ *memory }
+
+func (s memoryTokens) Get(ctx context.Context, id uint) (models.PersonalAccessToken, error) {
+	defer s.lock()()
+	token, ok := s.data.tokens.rows[id]
+	if !ok {
+		return models.PersonalAccessToken{}, ErrNotFound
+	}
+	return token, nil
+}
+
+func (s memoryTokens) GetByHash(ctx context.Context, tokenHash string) (models.PersonalAccessToken, error) {
+	defer s.lock()()
+	return s.data.tokens.find(func(t models.PersonalAccessToken) bool { return t.TokenHash == tokenHash })
+}
+
+func (s memoryTokens) ListByUser(ctx context.Context, userID uint) ([]models.PersonalAccessToken, error) {
+	defer s.lock()()
+	return s.data.tokens.filter(func(t models.PersonalAccessToken) bool { return t.UserID == userID }), nil
+}
+
+func (s memoryTokens) Create(ctx context.Context, token *models.PersonalAccessToken) error {
+	defer s.lock()()
+	token.ID = s.data.tokens.next()
+	touch(&token.CreatedAt, &token.UpdatedAt)
+	s.data.tokens.rows[token.ID] = *token
+	return nil
+}
+
+func (s memoryTokens) Revoke(ctx context.Context, id uint, at time.Time) error {
+	defer s.lock()()
+	if token, ok := s.data.tokens.rows[id]; ok {
+		token.RevokedAt = timePtr(at)
+		token.UpdatedAt = time.Now()
+		s.data.tokens.rows[id] = token
+	}
+	return nil
+}
+
+func (s memoryTokens) RevokeUser(ctx context.Context, userID uint, at time.Time) (int64, error) {
+	defer s.lock()()
+	var revoked int64
+	for id, token := range s.data.tokens.rows {
+		if token.UserID == userID && token.RevokedAt == nil {
+			token.RevokedAt = timePtr(at)
+			token.UpdatedAt = time.Now()
+			s.data.tokens.rows[id] = token
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+func (s memoryTokens) Touch(ctx context.Context, id uint, at time.Time) error {
+	defer s.lock()()
+	if token, ok := s.data.tokens.rows[id]; ok {
+		token.LastUsedAt = timePtr(at)
+		s.data.tokens.rows[id] = token
+	}
+	return nil
+}
+
+type memoryUserTokens struct{ *memory }
+
+func (s memoryUserTokens) GetByHash(ctx context.Context, tokenHash, purpose string) (models.UserToken, error) {
+	defer s.lock()()
+	return s.data.userTokens.find(func(t models.UserToken) bool {
+		return t.TokenHash == tokenHash && t.Purpose == purpose
+	})
+}
+
+func (s memoryUserTokens) Create(ctx context.Context, token *models.UserToken) error {
+	defer s.lock()()
+	token.ID = s.data.userTokens.next()
+	touch(&token.CreatedAt, nil)
+	s.data.userTokens.rows[token.ID] = *token
+	return nil
+}
+
+func (s memoryUserTokens) Use(ctx context.Context, id uint, at time.Time) (bool, error) {
+	defer s.lock()()
+	token, ok := s.data.userTokens.rows[id]
+	if !ok || token.UsedAt != nil {
+		return false, nil
+	}
+	token.UsedAt = timePtr(at)
+	s.data.userTokens.rows[id] = token
+	return true, nil
+}
+
+func (s memoryUserTokens) Invalidate(ctx context.Context, userID uint, purpose string, at time.Time) error {
+	defer s.lock()()
+	for id, token := range s.data.userTokens.rows {
+		if token.UserID == userID && token.Purpose == purpose && token.UsedAt == nil {
+			token.UsedAt = timePtr(at)
+			s.data.userTokens.rows[id] = token
+		}
+	}
+	return nil
+}
+
+type memoryRecoveryCodes struct{ *memory }
+
+func (s memoryRecoveryCodes) Create(ctx context.Context, code *models.RecoveryCode) error {
+	defer s.lock()()
+	code.ID = s.data.recoveryCodes.next()
+	touch(&code.CreatedAt, nil)
+	s.data.recoveryCodes.rows[code.ID] = *code
+	return nil
+}
+
+func (s memoryRecoveryCodes) Use(ctx context.Context, userID uint, codeHash string, at time.Time) (bool, error) {
+	defer s.lock()()
+	code, err := s.data.recoveryCodes.find(func(c models.RecoveryCode) bool {
+		return c.UserID == userID && c.CodeHash == codeHash && c.UsedAt == nil
+	})
+	if err != nil {
+		return false, nil
+	}
+	code.UsedAt = timePtr(at)
+	s.data.recoveryCodes.rows[code.ID] = code
+	return true, nil
+}
+
+func (s memoryRecoveryCodes) DeleteByUser(ctx context.Context, userID uint) error {
+	defer s.lock()()
+	s.data.recoveryCodes.deleteWhere(func(c models.RecoveryCode) bool { return c.UserID == userID })
+	return nil
+}
+
+// matchesWebhookToken — текущий токен или прежний, пока не истёк его срок
+func matchesWebhookToken(token string, now time.Time, current, previous string, previousExpiresAt *time.Time) bool {
+	if token == "" {
+		return false
+	}
+	return current == token || (previous == token && previousExpiresAt != nil && previousExpiresAt.After(now))
+}
+
+// page возвращает страницу строк; нулевой limit не ограничивает выборку
+func page[T any](rows []T, offset, limit int) []T {
+	if offset >= len(rows) {
+		return []T{}
+	}
+	rows = rows[offset:]
+	if limit > 0 && limit < len(rows) {
+		rows = rows[:limit]
+	}
+	return rows
+}
@@ -0,0 +1,250 @@
+package store
+
+import (
+	"commitcaster/internal/models"
+	"context"
+	"sort"
+	"time"
+)
+
+type memoryDeliveries struct{ *memory }
+
+func (s memoryDeliveries) Create(ctx context.Context, delivery *models.Delivery) error {
+	defer s.lock()()
+	delivery.ID = s.data.deliveries.next()
+	touch(&delivery.CreatedAt, &delivery.UpdatedAt)
+	row := *delivery
+	row.Posts = nil
+	s.data.deliveries.rows[delivery.ID] = row
+	return nil
+}
+
+func (s memoryDeliveries) ListByStatus(ctx context.Context, status string) ([]models.Delivery, error) {
+	defer s.lock()()
+	return s.data.deliveries.filter(func(d models.Delivery) bool { return d.Status == status }), nil
+}
+
+func (s memoryDeliveries) ListByUser(ctx context.Context, userID uint) ([]models.Delivery, error) {
+	defer s.lock()()
+	deliveries := s.data.deliveries.filter(func(d models.Delivery) bool { return d.UserID == userID })
+	for i := range deliveries {
+		deliveries[i].Posts = s.data.posts.filter(func(p models.Post) bool { return p.DeliveryID == deliveries[i].ID })
+	}
+	return deliveries, nil
+}
+
+func (s memoryDeliveries) ListRecent(ctx context.Context, userID uint, limit int) ([]models.Delivery, error) {
+	defer s.lock()()
+	deliveries := s.data.deliveries.filter(func(d models.Delivery) bool { return d.UserID == userID })
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID > deliveries[j].ID })
+	return page(deliveries, 0, limit), nil
+}
+
+func (s memoryDeliveries) Claim(ctx context.Context, id uint, from, to string) (bool, error) {
+	defer s.lock()()
+	delivery, ok := s.data.deliveries.rows[id]
+	if !ok || delivery.Status != from {
+		return false, nil
+	}
+	delivery.Status = to
+	delivery.UpdatedAt = time.Now()
+	s.data.deliveries.rows[id] = delivery
+	return true, nil
+}
+
+func (s memoryDeliveries) UpdateStatus(ctx context.Context, delivery *models.Delivery) error {
+	defer s.lock()()
+	row, ok := s.data.deliveries.rows[delivery.ID]
+	if !ok {
+		return nil
+	}
+	row.Status = delivery.Status
+	row.Error = delivery.Error
+	row.Payload = delivery.Payload
+	row.Post = delivery.Post
+	row.UpdatedAt = time.Now()
+	delivery.UpdatedAt = row.UpdatedAt
+	s.data.deliveries.rows[delivery.ID] = row
+	return nil
+}
+
+func (s memoryDeliveries) Stats(ctx context.Context, userIDs []uint) (map[uint]DeliveryStats, error) {
+	defer s.lock()()
+	wanted := make(map[uint]bool, len(userIDs))
+	for _, id := range userIDs {
+		wanted[id] = true
+	}
+
+	stats := make(map[uint]DeliveryStats)
+	for _, delivery := range s.data.deliveries.rows {
+		if !wanted[delivery.UserID] {
+			continue
+		}
+		st := stats[delivery.UserID]
+		st.Total++
+		if delivery.Status == models.DeliveryFailed {
+			st.Failed++
+		}
+		stats[delivery.UserID] = st
+	}
+	return stats, nil
+}
+
+type memoryPosts struct{ *memory }
+
+func (s memoryPosts) Create(ctx context.Context, post *models.Post) error {
+	defer s.lock()()
+	post.ID = s.data.posts.next()
+	touch(&post.CreatedAt, nil)
+	s.data.posts.rows[post.ID] = *post
+	return nil
+}
+
+func (s memoryPosts) ListByDelivery(ctx context.Context, deliveryID uint, status string) ([]models.Post, error) {
+	defer s.lock()()
+	return s.data.posts.filter(func(p models.Post) bool {
+		return p.DeliveryID == deliveryID && p.Status == status
+	}), nil
+}
+
+type memoryUsage struct{ *memory }
+
+func (s memoryUsage) Add(ctx context.Context, userID uint, period string, periodStart time.Time, counts models.UsageCounts, now time.Time) error {
+	defer s.lock()()
+	counter, err := s.find(userID, period, periodStart)
+	if err != nil {
+		counter = models.UsageCounter{
+			ID:          s.data.usage.next(),
+			UserID:      userID,
+			Period:      period,
+			PeriodStart: periodStart,
+		}
+	}
+	counter.Posts += counts.Posts
+	counter.AIRequests += counts.AIRequests
+	counter.PromptTokens += counts.PromptTokens
+	counter.CompletionTokens += counts.CompletionTokens
+	counter.TelegramSends += counts.TelegramSends
+	counter.UpdatedAt = now
+	s.data.usage.rows[counter.ID] = counter
+	return nil
+}
+
+func (s memoryUsage) ReserveAIRequest(ctx context.Context, userID uint, period string, periodStart time.Time, limit int64, now time.Time) (bool, error) {
+	defer s.lock()()
+	counter, err := s.find(userID, period, periodStart)
+	if err != nil {
+		counter = models.UsageCounter{
+			ID:          s.data.usage.next(),
+			UserID:      userID,
+			Period:      period,
+			PeriodStart: periodStart,
+		}
+	}
+	if limit > 0 && counter.AIRequests >= limit {
+		return false, nil
+	}
+	counter.AIRequests++
+	counter.UpdatedAt = now
+	s.data.usage.rows[counter.ID] = counter
+	return true, nil
+}
+
+func (s memoryUsage) find(userID uint, period string, periodStart time.Time) (models.UsageCounter, error) {
+	return s.data.usage.find(func(c models.UsageCounter) bool {
+		return c.UserID == userID && c.Period == period && c.PeriodStart.Equal(periodStart)
+	})
+}
+
+func (s memoryUsage) Get(ctx context.Context, userID uint, period string, periodStart time.Time) (models.UsageCounter, error) {
+	defer s.lock()()
+	counter, err := s.find(userID, period, periodStart)
+	if err != nil {
+		return models.UsageCounter{UserID: userID, Period: period, PeriodStart: periodStart}, nil
+	}
+	return counter, nil
+}
+
+func (s memoryUsage) List(ctx context.Context, userID uint, period string, since time.Time) ([]models.UsageCounter, error) {
+	defer s.lock()()
+	counters := s.data.usage.filter(func(c models.UsageCounter) bool {
+		return c.UserID == userID &&
+			(period == "" || c.Period == period) &&
+			(since.IsZero() || c.PeriodStart.After(since))
+	})
+	sort.SliceStable(counters, func(i, j int) bool {
+		if counters[i].Period != counters[j].Period {
+			return counters[i].Period < counters[j].Period
+		}
+		return counters[i].PeriodStart.Before(counters[j].PeriodStart)
+	})
+	return counters, nil
+}
+
+type memoryAudit struct{ *memory }
+
+func (s memoryAudit) Create(ctx context.Context, entry *models.AuditLog) error {
+	defer s.lock()()
+	entry.ID = s.data.audit.next()
+	touch(&entry.CreatedAt, nil)
+	s.data.audit.rows[entry.ID] = *entry
+	return nil
+}
+
+func (s memoryAudit) List(ctx context.Context, filter AuditFilter) ([]models.AuditLog, int64, error) {
+	defer s.lock()()
+
+	var visible func(models.AuditLog) bool
+	if filter.VisibleTo != 0 {
+		orgIDs := memberOrganizations(s.data, filter.VisibleTo)
+		projectIDs := make(map[uint]bool)
+		for id, p := range s.data.projects.rows {
+			if (p.OrganizationID == nil && p.UserID == filter.VisibleTo) || (p.OrganizationID != nil && orgIDs[*p.OrganizationID]) {
+				projectIDs[id] = true
+			}
+		}
+		visible = func(e models.AuditLog) bool {
+			if isUserSubject(e, filter.VisibleTo) {
+				return true
+			}
+			if e.TargetID == nil {
+				return false
+			}
+			return (e.TargetType == "project" && projectIDs[*e.TargetID]) ||
+				(e.TargetType == "organization" && orgIDs[*e.TargetID])
+		}
+	}
+
+	entries := s.data.audit.filter(func(e models.AuditLog) bool {
+		switch {
+		case visible != nil && !visible(e):
+			return false
+		case filter.Subject != 0 && !isUserSubject(e, filter.Subject):
+			return false
+		case filter.ActorID != nil && (e.ActorID == nil || *e.ActorID != *filter.ActorID):
+			return false
+		case filter.Action != "" && e.Action != filter.Action:
+			return false
+		case filter.TargetType != "" && e.TargetType != filter.TargetType:
+			return false
+		case filter.TargetID != nil && (e.TargetID == nil || *e.TargetID != *filter.TargetID):
+			return false
+		case filter.Since != nil && e.CreatedAt.Before(*filter.Since):
+			return false
+		case filter.Until != nil && !e.CreatedAt.Before(*filter.Until):
+			return false
+		}
+		return true
+	})
+
+	if !filter.OldestFirst {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].ID > entries[j].ID })
+	}
+	return page(entries, filter.Offset, filter.Limit), int64(len(entries)), nil
+}
+
+// isUserSubject — действие пользователя userID или действие над ним
+func isUserSubject(e models.AuditLog, userID uint) bool {
+	return (e.ActorID != nil && *e.ActorID == userID) ||
+		(e.TargetType == "user" && e.TargetID != nil && *e.TargetID == userID)
+}
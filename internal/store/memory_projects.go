@@ -0,0 +1,352 @@
+package store
+
+import (
+	"commitcaster/internal/models"
+	"context"
+	"sort"
+	"time"
+)
+
+type memoryOrganizations struct{ *memory }
+
+func (s memoryOrganizations) Get(ctx context.Context, id uint) (models.Organization, error) {
+	defer s.lock()()
+	org, ok := s.data.organizations.rows[id]
+	if !ok || org.DeletedAt.Valid {
+		return models.Organization{}, ErrNotFound
+	}
+	return org, nil
+}
+
+func (s memoryOrganizations) Create(ctx context.Context, org *models.Organization) error {
+	defer s.lock()()
+	org.ID = s.data.organizations.next()
+	touch(&org.CreatedAt, &org.UpdatedAt)
+	for i := range org.Members {
+		org.Members[i].OrganizationID = org.ID
+		if err := s.createMembership(&org.Members[i]); err != nil {
+			return err
+		}
+	}
+
+	row := *org
+	row.Members = nil
+	s.data.organizations.rows[org.ID] = row
+	return nil
+}
+
+func (s memoryOrganizations) Save(ctx context.Context, org *models.Organization) error {
+	defer s.lock()()
+	touch(&org.CreatedAt, &org.UpdatedAt)
+	row := *org
+	row.Members = nil
+	s.data.organizations.rows[org.ID] = row
+	return nil
+}
+
+func (s memoryOrganizations) Delete(ctx context.Context, id uint) error {
+	defer s.lock()()
+	d := s.data
+	for projectID, project := range d.projects.rows {
+		if project.OrganizationID != nil && *project.OrganizationID == id && !project.DeletedAt.Valid {
+			deleteMemoryProject(d, projectID)
+		}
+	}
+	d.memberships.deleteWhere(func(m models.Membership) bool { return m.OrganizationID == id })
+	if org, ok := d.organizations.rows[id]; ok {
+		softDelete(&org.DeletedAt)
+		d.organizations.rows[id] = org
+	}
+	return nil
+}
+
+func (s memoryOrganizations) Purge(ctx context.Context, id uint) error {
+	defer s.lock()()
+	d := s.data
+	purgeMemoryProjects(d, func(p models.Project) bool { return p.OrganizationID != nil && *p.OrganizationID == id })
+	d.memberships.deleteWhere(func(m models.Membership) bool { return m.OrganizationID == id })
+	delete(d.organizations.rows, id)
+	return nil
+}
+
+func (s memoryOrganizations) GetMembership(ctx context.Context, orgID, userID uint) (models.Membership, error) {
+	defer s.lock()()
+	return s.data.memberships.find(func(m models.Membership) bool {
+		return m.OrganizationID == orgID && m.UserID == userID
+	})
+}
+
+func (s memoryOrganizations) ListMemberships(ctx context.Context, userID uint) ([]models.Membership, error) {
+	defer s.lock()()
+	memberships := s.data.memberships.filter(func(m models.Membership) bool { return m.UserID == userID })
+	sort.SliceStable(memberships, func(i, j int) bool {
+		return memberships[i].OrganizationID < memberships[j].OrganizationID
+	})
+	return memberships, nil
+}
+
+func (s memoryOrganizations) ListMembers(ctx context.Context, orgID uint) ([]Member, error) {
+	defer s.lock()()
+	var members []Member
+	for _, m := range s.data.memberships.filter(func(m models.Membership) bool { return m.OrganizationID == orgID }) {
+		user, ok := s.data.users.rows[m.UserID]
+		if !ok || user.DeletedAt.Valid {
+			continue
+		}
+		members = append(members, Member{
+			UserID:    m.UserID,
+			Email:     user.Email,
+			Name:      user.Name,
+			Role:      m.Role,
+			CreatedAt: m.CreatedAt,
+		})
+	}
+	return members, nil
+}
+
+func (s memoryOrganizations) CreateMembership(ctx context.Context, membership *models.Membership) error {
+	defer s.lock()()
+	return s.createMembership(membership)
+}
+
+func (s memoryOrganizations) createMembership(membership *models.Membership) error {
+	for _, m := range s.data.memberships.rows {
+		if m.OrganizationID == membership.OrganizationID && m.UserID == membership.UserID {
+			return errDuplicate
+		}
+	}
+	membership.ID = s.data.memberships.next()
+	touch(&membership.CreatedAt, &membership.UpdatedAt)
+	s.data.memberships.rows[membership.ID] = *membership
+	return nil
+}
+
+func (s memoryOrganizations) SaveMembership(ctx context.Context, membership *models.Membership) error {
+	defer s.lock()()
+	if membership.ID == 0 {
+		return s.createMembership(membership)
+	}
+	touch(&membership.CreatedAt, &membership.UpdatedAt)
+	s.data.memberships.rows[membership.ID] = *membership
+	return nil
+}
+
+func (s memoryOrganizations) DeleteMembership(ctx context.Context, id uint) error {
+	defer s.lock()()
+	delete(s.data.memberships.rows, id)
+	return nil
+}
+
+func (s memoryOrganizations) CountMembers(ctx context.Context, orgID uint, role string) (int64, error) {
+	defer s.lock()()
+	members := s.data.memberships.filter(func(m models.Membership) bool {
+		return m.OrganizationID == orgID && (role == "" || m.Role == role)
+	})
+	return int64(len(members)), nil
+}
+
+func (s memoryOrganizations) FirstOwner(ctx context.Context, orgID, exceptUserID uint) (models.Membership, error) {
+	defer s.lock()()
+	return s.data.memberships.find(func(m models.Membership) bool {
+		return m.OrganizationID == orgID && m.Role == models.RoleOwner && m.UserID != exceptUserID
+	})
+}
+
+type memoryProjects struct{ *memory }
+
+// load добавляет к проекту каналы и правила маршрутизации по порядку применения
+func (s memoryProjects) load(project models.Project) models.Project {
+	project.Destinations = s.data.destinations.filter(func(d models.Destination) bool { return d.ProjectID == project.ID })
+	project.RoutingRules = s.data.routingRules.filter(func(r models.RoutingRule) bool { return r.ProjectID == project.ID })
+	sort.SliceStable(project.RoutingRules, func(i, j int) bool {
+		return project.RoutingRules[i].Position < project.RoutingRules[j].Position
+	})
+	return project
+}
+
+func (s memoryProjects) list(match func(models.Project) bool) []models.Project {
+	projects := s.data.projects.filter(func(p models.Project) bool { return !p.DeletedAt.Valid && match(p) })
+	for i := range projects {
+		projects[i] = s.load(projects[i])
+	}
+	return projects
+}
+
+func (s memoryProjects) Get(ctx context.Context, id uint) (models.Project, error) {
+	defer s.lock()()
+	project, ok := s.data.projects.rows[id]
+	if !ok || project.DeletedAt.Valid {
+		return models.Project{}, ErrNotFound
+	}
+	return s.load(project), nil
+}
+
+func (s memoryProjects) GetByWebhookToken(ctx context.Context, token string, now time.Time) (models.Project, error) {
+	defer s.lock()()
+	projects := s.list(func(p models.Project) bool {
+		return matchesWebhookToken(token, now, p.WebhookToken, p.PreviousWebhookToken, p.PreviousWebhookTokenExpiresAt)
+	})
+	if len(projects) == 0 {
+		return models.Project{}, ErrNotFound
+	}
+	return projects[0], nil
+}
+
+func (s memoryProjects) ListForUser(ctx context.Context, userID uint) ([]models.Project, error) {
+	defer s.lock()()
+	orgIDs := memberOrganizations(s.data, userID)
+	return s.list(func(p models.Project) bool {
+		if p.OrganizationID == nil {
+			return p.UserID == userID
+		}
+		return orgIDs[*p.OrganizationID]
+	}), nil
+}
+
+func (s memoryProjects) ListByUser(ctx context.Context, userID uint) ([]models.Project, error) {
+	defer s.lock()()
+	return s.list(func(p models.Project) bool { return p.UserID == userID }), nil
+}
+
+func (s memoryProjects) Create(ctx context.Context, project *models.Project) error {
+	defer s.lock()()
+	for _, p := range s.data.projects.rows {
+		if p.WebhookToken == project.WebhookToken {
+			return errDuplicate
+		}
+	}
+	project.ID = s.data.projects.next()
+	s.put(project)
+	return nil
+}
+
+func (s memoryProjects) Save(ctx context.Context, project *models.Project) error {
+	defer s.lock()()
+	if _, ok := s.data.projects.rows[project.ID]; !ok {
+		return ErrNotFound
+	}
+
+	keepDestinations := make(map[uint]bool)
+	for _, d := range project.Destinations {
+		keepDestinations[d.ID] = true
+	}
+	keepRules := make(map[uint]bool)
+	for _, r := range project.RoutingRules {
+		keepRules[r.ID] = true
+	}
+	s.data.destinations.deleteWhere(func(d models.Destination) bool {
+		return d.ProjectID == project.ID && !keepDestinations[d.ID]
+	})
+	s.data.routingRules.deleteWhere(func(r models.RoutingRule) bool {
+		return r.ProjectID == project.ID && !keepRules[r.ID]
+	})
+
+	s.put(project)
+	return nil
+}
+
+// put сохраняет проект, его каналы и правила, назначая id новым записям
+func (s memoryProjects) put(project *models.Project) {
+	touch(&project.CreatedAt, &project.UpdatedAt)
+	for i := range project.Destinations {
+		d := &project.Destinations[i]
+		d.ProjectID = project.ID
+		if d.ID == 0 {
+			d.ID = s.data.destinations.next()
+		}
+		touch(&d.CreatedAt, &d.UpdatedAt)
+		s.data.destinations.rows[d.ID] = *d
+	}
+	for i := range project.RoutingRules {
+		r := &project.RoutingRules[i]
+		r.ProjectID = project.ID
+		if r.ID == 0 {
+			r.ID = s.data.routingRules.next()
+		}
+		touch(&r.CreatedAt, &r.UpdatedAt)
+		s.data.routingRules.rows[r.ID] = *r
+	}
+
+	row := *project
+	row.Destinations = nil
+	row.RoutingRules = nil
+	s.data.projects.rows[project.ID] = row
+}
+
+func (s memoryProjects) Delete(ctx context.Context, id uint) error {
+	defer s.lock()()
+	deleteMemoryProject(s.data, id)
+	return nil
+}
+
+// update изменяет проект fn; удалённые и отсутствующие пропускаются, как UPDATE в БД
+func (s memoryProjects) update(id uint, fn func(p *models.Project)) error {
+	defer s.lock()()
+	project, ok := s.data.projects.rows[id]
+	if !ok || project.DeletedAt.Valid {
+		return nil
+	}
+	fn(&project)
+	project.UpdatedAt = time.Now()
+	s.data.projects.rows[id] = project
+	return nil
+}
+
+func (s memoryProjects) SetGitHubSecret(ctx context.Context, id uint, secret string) error {
+	return s.update(id, func(p *models.Project) { p.GitHubSecret = secret })
+}
+
+func (s memoryProjects) RotateWebhookToken(ctx context.Context, id uint, rotation WebhookRotation) error {
+	return s.update(id, func(p *models.Project) {
+		p.WebhookToken = rotation.Token
+		p.PreviousWebhookToken = rotation.PreviousToken
+		p.PreviousWebhookTokenExpiresAt = rotation.PreviousExpiresAt
+	})
+}
+
+func (s memoryProjects) TransferOwnership(ctx context.Context, orgID, fromUserID, toUserID uint) error {
+	defer s.lock()()
+	for id, project := range s.data.projects.rows {
+		if project.OrganizationID != nil && *project.OrganizationID == orgID && project.UserID == fromUserID {
+			project.UserID = toUserID
+			project.UpdatedAt = time.Now()
+			s.data.projects.rows[id] = project
+		}
+	}
+	return nil
+}
+
+// memberOrganizations возвращает id организаций, в которых состоит пользователь
+func memberOrganizations(d *memoryData, userID uint) map[uint]bool {
+	orgIDs := make(map[uint]bool)
+	for _, m := range d.memberships.rows {
+		if m.UserID == userID {
+			orgIDs[m.OrganizationID] = true
+		}
+	}
+	return orgIDs
+}
+
+// deleteMemoryProject помечает проект удалённым и удаляет его каналы и правила
+func deleteMemoryProject(d *memoryData, id uint) {
+	project, ok := d.projects.rows[id]
+	if !ok || project.DeletedAt.Valid {
+		return
+	}
+	softDelete(&project.DeletedAt)
+	d.projects.rows[id] = project
+	d.destinations.deleteWhere(func(dest models.Destination) bool { return dest.ProjectID == id })
+	d.routingRules.deleteWhere(func(r models.RoutingRule) bool { return r.ProjectID == id })
+}
+
+// purgeMemoryProjects безвозвратно удаляет проекты, в том числе удалённые, с каналами и правилами
+func purgeMemoryProjects(d *memoryData, match func(models.Project) bool) {
+	for id, project := range d.projects.rows {
+		if !match(project) {
+			continue
+		}
+		d.destinations.deleteWhere(func(dest models.Destination) bool { return dest.ProjectID == id })
+		d.routingRules.deleteWhere(func(r models.RoutingRule) bool { return r.ProjectID == id })
+		delete(d.projects.rows, id)
+	}
+}
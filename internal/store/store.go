@@ -0,0 +1,263 @@
+// Package store — хранилища данных SaaS режима. Обработчики работают с интерфейсами
+// хранилищ, реализации — GORM (PostgreSQL, SQLite) и в памяти процесса (разработка и тесты)
+package store
+
+import (
+	"commitcaster/internal/models"
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound — запись не найдена
+var ErrNotFound = errors.New("record not found")
+
+// Store объединяет хранилища одной БД
+type Store struct {
+	Users         UserStore
+	Settings      SettingsStore
+	Sessions      SessionStore
+	Tokens        TokenStore
+	UserTokens    UserTokenStore
+	RecoveryCodes RecoveryCodeStore
+	Organizations OrganizationStore
+	Projects      ProjectStore
+	Deliveries    DeliveryStore
+	Posts         PostStore
+	Usage         UsageStore
+	Audit         AuditStore
+
+	transaction func(ctx context.Context, fn func(tx *Store) error) error
+}
+
+// Transaction выполняет fn с хранилищами одной транзакции: при ошибке изменения отменяются
+func (s *Store) Transaction(ctx context.Context, fn func(tx *Store) error) error {
+	return s.transaction(ctx, fn)
+}
+
+// WebhookRotation — новый webhook токен и прежний, который действует до PreviousExpiresAt
+type WebhookRotation struct {
+	Token             string
+	PreviousToken     string
+	PreviousExpiresAt *time.Time
+}
+
+// UserFilter — условия списка пользователей для администратора
+type UserFilter struct {
+	Query       string // подстрока email или имени без учёта регистра
+	Role        string
+	Deactivated *bool
+	Offset      int
+	Limit       int
+}
+
+type UserStore interface {
+	Get(ctx context.Context, id uint) (models.User, error)
+	GetByEmail(ctx context.Context, email string) (models.User, error)
+	GetByGitHubID(ctx context.Context, githubID int64) (models.User, error)
+	// GetByWebhookToken ищет по текущему токену и по прежнему, пока не истёк его срок
+	GetByWebhookToken(ctx context.Context, token string, now time.Time) (models.User, error)
+	// List возвращает страницу пользователей по id и общее число подходящих
+	List(ctx context.Context, filter UserFilter) ([]models.User, int64, error)
+	Create(ctx context.Context, user *models.User) error
+	// Save сохраняет поля пользователя без настроек
+	Save(ctx context.Context, user *models.User) error
+	// IncrementFailedLogins увеличивает счётчик неудачных попыток входа и возвращает
+	// его значение в БД, с учётом параллельных попыток
+	IncrementFailedLogins(ctx context.Context, id uint) (int, error)
+	SetLoginFailures(ctx context.Context, id uint, failed int, lockedUntil *time.Time) error
+	// MarkEmailVerified отмечает email подтверждённым, если он ещё не подтверждён
+	MarkEmailVerified(ctx context.Context, id uint, at time.Time) error
+	// AdvanceTOTPStep запоминает шаг TOTP последнего принятого кода, если он больше
+	// сохранённого; false — код этого или более позднего шага уже принят (повтор)
+	AdvanceTOTPStep(ctx context.Context, id uint, step int64) (bool, error)
+	SetRole(ctx context.Context, id uint, role string) error
+	SetPlan(ctx context.Context, id uint, plan string) error
+	SetDeactivatedAt(ctx context.Context, id uint, at *time.Time) error
+	RotateWebhookToken(ctx context.Context, id uint, rotation WebhookRotation) error
+	// PromoteAdmins назначает роль admin пользователям с указанными email
+	// (emails в нижнем регистре, регистр email пользователя не важен) и возвращает число изменённых
+	PromoteAdmins(ctx context.Context, emails []string) (int64, error)
+	// Purge безвозвратно удаляет пользователя, его настройки, сессии, токены, личные
+	// проекты, доставки, счётчики и участие в организациях. Записи журнала аудита
+	// остаются, но без IP и User-Agent
+	Purge(ctx context.Context, id uint) error
+}
+
+type SettingsStore interface {
+	GetByUser(ctx context.Context, userID uint) (models.UserSettings, error)
+	Create(ctx context.Context, settings *models.UserSettings) error
+	Save(ctx context.Context, settings *models.UserSettings) error
+	// SetGitHubSecret возвращает ErrNotFound, если у пользователя нет настроек
+	SetGitHubSecret(ctx context.Context, userID uint, secret string) error
+}
+
+type SessionStore interface {
+	Get(ctx context.Context, id uint) (models.Session, error)
+	ListByUser(ctx context.Context, userID uint) ([]models.Session, error)
+	Create(ctx context.Context, session *models.Session) error
+	Save(ctx context.Context, session *models.Session) error
+	// Revoke отзывает сессию, если она ещё не отозвана
+	Revoke(ctx context.Context, id uint, at time.Time) error
+	// RevokeUser отзывает неотозванные сессии пользователя и возвращает их число
+	RevokeUser(ctx context.Context, userID uint, at time.Time) (int64, error)
+	CountActive(ctx context.Context, userID uint, now time.Time) (int64, error)
+	// LastLogins — время последней сессии, открытой самим пользователем, а не через impersonation
+	LastLogins(ctx context.Context, userIDs []uint) (map[uint]time.Time, error)
+
+	GetRefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error)
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	// UseRefreshToken помечает токен использованным; false — он уже был использован
+	UseRefreshToken(ctx context.Context, id uint, at time.Time) (bool, error)
+}
+
+// TokenStore — personal access токены
+type TokenStore interface {
+	Get(ctx context.Context, id uint) (models.PersonalAccessToken, error)
+	GetByHash(ctx context.Context, tokenHash string) (models.PersonalAccessToken, error)
+	ListByUser(ctx context.Context, userID uint) ([]models.PersonalAccessToken, error)
+	Create(ctx context.Context, token *models.PersonalAccessToken) error
+	Revoke(ctx context.Context, id uint, at time.Time) error
+	// RevokeUser отзывает неотозванные токены пользователя и возвращает их число
+	RevokeUser(ctx context.Context, userID uint, at time.Time) (int64, error)
+	// Touch отмечает время последнего использования токена
+	Touch(ctx context.Context, id uint, at time.Time) error
+}
+
+// UserTokenStore — одноразовые токены из писем (подтверждение email, сброс пароля)
+type UserTokenStore interface {
+	GetByHash(ctx context.Context, tokenHash, purpose string) (models.UserToken, error)
+	Create(ctx context.Context, token *models.UserToken) error
+	// Use помечает токен использованным; false — он уже был использован
+	Use(ctx context.Context, id uint, at time.Time) (bool, error)
+	// Invalidate помечает неиспользованные токены пользователя с purpose использованными
+	Invalidate(ctx context.Context, userID uint, purpose string, at time.Time) error
+}
+
+// RecoveryCodeStore — коды восстановления 2FA
+type RecoveryCodeStore interface {
+	Create(ctx context.Context, code *models.RecoveryCode) error
+	// Use помечает неиспользованный код пользователя использованным; false — такого кода нет
+	Use(ctx context.Context, userID uint, codeHash string, at time.Time) (bool, error)
+	DeleteByUser(ctx context.Context, userID uint) error
+}
+
+// Member — участник организации с email и именем
+type Member struct {
+	UserID    uint
+	Email     string
+	Name      string
+	Role      string
+	CreatedAt time.Time
+}
+
+type OrganizationStore interface {
+	Get(ctx context.Context, id uint) (models.Organization, error)
+	// Create создаёт организацию вместе с участниками из org.Members
+	Create(ctx context.Context, org *models.Organization) error
+	Save(ctx context.Context, org *models.Organization) error
+	// Delete удаляет организацию с участниками и проектами
+	Delete(ctx context.Context, id uint) error
+	// Purge безвозвратно удаляет организацию с участниками и проектами, в том числе удалёнными
+	Purge(ctx context.Context, id uint) error
+
+	GetMembership(ctx context.Context, orgID, userID uint) (models.Membership, error)
+	// ListMemberships возвращает участие пользователя в организациях по id организации
+	ListMemberships(ctx context.Context, userID uint) ([]models.Membership, error)
+	// ListMembers возвращает участников организации (кроме удалённых пользователей)
+	ListMembers(ctx context.Context, orgID uint) ([]Member, error)
+	CreateMembership(ctx context.Context, membership *models.Membership) error
+	SaveMembership(ctx context.Context, membership *models.Membership) error
+	DeleteMembership(ctx context.Context, id uint) error
+	// CountMembers считает участников с ролью role (пустая строка — всех)
+	CountMembers(ctx context.Context, orgID uint, role string) (int64, error)
+	// FirstOwner возвращает первого owner организации, кроме пользователя exceptUserID
+	FirstOwner(ctx context.Context, orgID, exceptUserID uint) (models.Membership, error)
+}
+
+// ProjectStore — проекты загружаются с каналами и правилами маршрутизации
+type ProjectStore interface {
+	Get(ctx context.Context, id uint) (models.Project, error)
+	// GetByWebhookToken ищет по текущему токену и по прежнему, пока не истёк его срок
+	GetByWebhookToken(ctx context.Context, token string, now time.Time) (models.Project, error)
+	// ListForUser возвращает личные проекты пользователя и проекты его организаций
+	ListForUser(ctx context.Context, userID uint) ([]models.Project, error)
+	// ListByUser возвращает проекты, созданные пользователем, включая проекты организаций
+	ListByUser(ctx context.Context, userID uint) ([]models.Project, error)
+	Create(ctx context.Context, project *models.Project) error
+	// Save сохраняет проект с каналами и правилами; каналы и правила, которых больше нет
+	// в проекте, удаляются
+	Save(ctx context.Context, project *models.Project) error
+	Delete(ctx context.Context, id uint) error
+	SetGitHubSecret(ctx context.Context, id uint, secret string) error
+	RotateWebhookToken(ctx context.Context, id uint, rotation WebhookRotation) error
+	// TransferOwnership передаёт проекты организации, в том числе удалённые,
+	// от одного пользователя другому
+	TransferOwnership(ctx context.Context, orgID, fromUserID, toUserID uint) error
+}
+
+// DeliveryStats — число доставок пользователя и неудачных среди них
+type DeliveryStats struct {
+	Total  int64
+	Failed int64
+}
+
+type DeliveryStore interface {
+	Create(ctx context.Context, delivery *models.Delivery) error
+	// ListByStatus возвращает доставки со статусом по id
+	ListByStatus(ctx context.Context, status string) ([]models.Delivery, error)
+	// ListByUser возвращает доставки пользователя с постами по id
+	ListByUser(ctx context.Context, userID uint) ([]models.Delivery, error)
+	// ListRecent возвращает последние limit доставок пользователя, новые первыми
+	ListRecent(ctx context.Context, userID uint, limit int) ([]models.Delivery, error)
+	// Claim меняет статус доставки с from на to; false — статус уже изменён
+	Claim(ctx context.Context, id uint, from, to string) (bool, error)
+	// UpdateStatus сохраняет статус, ошибку, payload и пост доставки
+	UpdateStatus(ctx context.Context, delivery *models.Delivery) error
+	Stats(ctx context.Context, userIDs []uint) (map[uint]DeliveryStats, error)
+}
+
+type PostStore interface {
+	Create(ctx context.Context, post *models.Post) error
+	ListByDelivery(ctx context.Context, deliveryID uint, status string) ([]models.Post, error)
+}
+
+type UsageStore interface {
+	// Add атомарно прибавляет counts к счётчику периода, создавая его при необходимости
+	Add(ctx context.Context, userID uint, period string, periodStart time.Time, counts models.UsageCounts, now time.Time) error
+	// ReserveAIRequest атомарно увеличивает ai_requests счётчика периода на 1, если
+	// он меньше limit (0 — без ограничения). false — квота исчерпана, счётчик не изменён
+	ReserveAIRequest(ctx context.Context, userID uint, period string, periodStart time.Time, limit int64, now time.Time) (bool, error)
+	// Get возвращает счётчик периода; если его нет — нулевой
+	Get(ctx context.Context, userID uint, period string, periodStart time.Time) (models.UsageCounter, error)
+	// List возвращает счётчики пользователя по периоду и началу периода: только периоды,
+	// начавшиеся позже since. Пустой period — все периоды, нулевой since — без ограничения
+	List(ctx context.Context, userID uint, period string, since time.Time) ([]models.UsageCounter, error)
+}
+
+// AuditFilter — условия выборки журнала аудита. Нулевые поля не ограничивают выборку
+type AuditFilter struct {
+	// VisibleTo — записи, видимые пользователю: его действия и действия над ним,
+	// его проектами и организациями
+	VisibleTo uint
+	// Subject — действия пользователя и действия над ним
+	Subject uint
+
+	ActorID    *uint
+	Action     string
+	TargetType string
+	TargetID   *uint
+	Since      *time.Time
+	Until      *time.Time
+
+	Offset int
+	Limit  int
+	// OldestFirst — по возрастанию id; по умолчанию новые записи первыми
+	OldestFirst bool
+}
+
+type AuditStore interface {
+	Create(ctx context.Context, entry *models.AuditLog) error
+	// List возвращает страницу записей и общее число подходящих
+	List(ctx context.Context, filter AuditFilter) ([]models.AuditLog, int64, error)
+}
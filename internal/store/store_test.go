@@ -0,0 +1,246 @@
+package store_test
+
+import (
+	"commitcaster/internal/database"
+	"commitcaster/internal/models"
+	"commitcaster/internal/store"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// forEachStore запускает тест на хранилище в памяти и на GORM поверх SQLite
+// с применёнными миграциями: реализации должны вести себя одинаково
+func forEachStore(t *testing.T, test func(t *testing.T, st *store.Store)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, store.NewMemory())
+	})
+	t.Run("gorm", func(t *testing.T) {
+		t.Setenv("DATABASE_URL", database.SQLiteScheme+filepath.Join(t.TempDir(), "test.db"))
+		db, err := database.Connect()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { database.Close(db) })
+		if _, err := database.MigrateUp(context.Background(), db); err != nil {
+			t.Fatal(err)
+		}
+		test(t, store.NewGORM(db))
+	})
+}
+
+func TestUsageAdd(t *testing.T) {
+	forEachStore(t, func(t *testing.T, st *store.Store) {
+		ctx := t.Context()
+		start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+		now := start.Add(time.Hour)
+
+		if err := st.Usage.Add(ctx, 1, models.UsagePeriodMonth, start, models.UsageCounts{Posts: 1, PromptTokens: 10}, now); err != nil {
+			t.Fatal(err)
+		}
+		if err := st.Usage.Add(ctx, 1, models.UsagePeriodMonth, start, models.UsageCounts{Posts: 2, CompletionTokens: 5}, now); err != nil {
+			t.Fatal(err)
+		}
+
+		counter, err := st.Usage.Get(ctx, 1, models.UsagePeriodMonth, start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if counter.Posts != 3 || counter.Tokens() != 15 {
+			t.Errorf("counter = %+v, want 3 posts and 15 tokens", counter.UsageCounts)
+		}
+
+		// Счётчик другого пользователя не затронут, отсутствующий — нулевой
+		other, err := st.Usage.Get(ctx, 2, models.UsagePeriodMonth, start)
+		if err != nil || other.Posts != 0 {
+			t.Errorf("other user counter = %+v, %v", other.UsageCounts, err)
+		}
+	})
+}
+
+func TestUsageReserveAIRequest(t *testing.T) {
+	forEachStore(t, func(t *testing.T, st *store.Store) {
+		ctx := t.Context()
+		start := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+		now := start.Add(time.Hour)
+
+		for i := 0; i < 2; i++ {
+			reserved, err := st.Usage.ReserveAIRequest(ctx, 1, models.UsagePeriodDay, start, 2, now)
+			if err != nil || !reserved {
+				t.Fatalf("reservation %d = %v, %v", i+1, reserved, err)
+			}
+		}
+		reserved, err := st.Usage.ReserveAIRequest(ctx, 1, models.UsagePeriodDay, start, 2, now)
+		if err != nil || reserved {
+			t.Fatalf("reservation over the limit = %v, %v", reserved, err)
+		}
+
+		// Без ограничения резерв всегда проходит
+		if reserved, err := st.Usage.ReserveAIRequest(ctx, 1, models.UsagePeriodDay, start, 0, now); err != nil || !reserved {
+			t.Fatalf("unlimited reservation = %v, %v", reserved, err)
+		}
+
+		counter, err := st.Usage.Get(ctx, 1, models.UsagePeriodDay, start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if counter.AIRequests != 3 {
+			t.Errorf("ai requests = %d, want 3", counter.AIRequests)
+		}
+	})
+}
+
+// createUser создаёт пользователя с уникальными email и webhook токеном
+func createUser(t *testing.T, st *store.Store, email string) models.User {
+	t.Helper()
+	user := models.User{Email: email, PasswordHash: "hash", WebhookToken: "token-" + email}
+	if err := st.Users.Create(t.Context(), &user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestUsersLoginFailures(t *testing.T) {
+	forEachStore(t, func(t *testing.T, st *store.Store) {
+		ctx := t.Context()
+		user := createUser(t, st, "alice@example.com")
+
+		for want := 1; want <= 3; want++ {
+			got, err := st.Users.IncrementFailedLogins(ctx, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Fatalf("IncrementFailedLogins = %d, want %d", got, want)
+			}
+		}
+
+		lockedUntil := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+		if err := st.Users.SetLoginFailures(ctx, user.ID, 3, &lockedUntil); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := st.Users.Get(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loaded.FailedLogins != 3 || loaded.LockedUntil == nil || !loaded.LockedUntil.Equal(lockedUntil) {
+			t.Errorf("after lock: failed=%d locked_until=%v", loaded.FailedLogins, loaded.LockedUntil)
+		}
+
+		if err := st.Users.SetLoginFailures(ctx, user.ID, 0, nil); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err = st.Users.Get(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loaded.FailedLogins != 0 || loaded.LockedUntil != nil {
+			t.Errorf("after reset: failed=%d locked_until=%v", loaded.FailedLogins, loaded.LockedUntil)
+		}
+	})
+}
+
+func TestUsersAdvanceTOTPStep(t *testing.T) {
+	forEachStore(t, func(t *testing.T, st *store.Store) {
+		ctx := t.Context()
+		user := createUser(t, st, "alice@example.com")
+
+		steps := []struct {
+			step int64
+			want bool
+		}{
+			{100, true},
+			{100, false}, // повтор того же кода
+			{99, false},  // более ранний код после принятого
+			{101, true},
+		}
+		for _, s := range steps {
+			got, err := st.Users.AdvanceTOTPStep(ctx, user.ID, s.step)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != s.want {
+				t.Errorf("AdvanceTOTPStep(%d) = %v, want %v", s.step, got, s.want)
+			}
+		}
+
+		loaded, err := st.Users.Get(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loaded.TOTPLastStep != 101 {
+			t.Errorf("TOTPLastStep = %d, want 101", loaded.TOTPLastStep)
+		}
+	})
+}
+
+func TestDeliveriesClaimAndUpdate(t *testing.T) {
+	forEachStore(t, func(t *testing.T, st *store.Store) {
+		ctx := t.Context()
+		user := createUser(t, st, "alice@example.com")
+
+		delivery := models.Delivery{UserID: user.ID, Status: models.DeliveryInterrupted, Payload: `{"ref":"refs/heads/main"}`, Post: "post"}
+		if err := st.Deliveries.Create(ctx, &delivery); err != nil {
+			t.Fatal(err)
+		}
+
+		// Продолжить прерванную доставку может только один экземпляр
+		claimed, err := st.Deliveries.Claim(ctx, delivery.ID, models.DeliveryInterrupted, models.DeliveryProcessing)
+		if err != nil || !claimed {
+			t.Fatalf("first Claim = %v, %v", claimed, err)
+		}
+		claimed, err = st.Deliveries.Claim(ctx, delivery.ID, models.DeliveryInterrupted, models.DeliveryProcessing)
+		if err != nil || claimed {
+			t.Fatalf("second Claim = %v, %v", claimed, err)
+		}
+
+		delivery.Status = models.DeliverySucceeded
+		delivery.Payload = ""
+		delivery.Post = ""
+		if err := st.Deliveries.UpdateStatus(ctx, &delivery); err != nil {
+			t.Fatal(err)
+		}
+
+		interrupted, err := st.Deliveries.ListByStatus(ctx, models.DeliveryInterrupted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(interrupted) != 0 {
+			t.Errorf("interrupted deliveries = %d, want 0", len(interrupted))
+		}
+		deliveries, err := st.Deliveries.ListByUser(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deliveries) != 1 || deliveries[0].Status != models.DeliverySucceeded || deliveries[0].Post != "" || deliveries[0].Payload != "" {
+			t.Errorf("deliveries = %+v", deliveries)
+		}
+	})
+}
+
+func TestDeliveriesKeepInterruptedPost(t *testing.T) {
+	forEachStore(t, func(t *testing.T, st *store.Store) {
+		ctx := t.Context()
+		user := createUser(t, st, "alice@example.com")
+
+		delivery := models.Delivery{UserID: user.ID, Status: models.DeliveryProcessing}
+		if err := st.Deliveries.Create(ctx, &delivery); err != nil {
+			t.Fatal(err)
+		}
+		delivery.Status = models.DeliveryInterrupted
+		delivery.Payload = `{"ref":"refs/heads/main"}`
+		delivery.Post = "generated post"
+		if err := st.Deliveries.UpdateStatus(ctx, &delivery); err != nil {
+			t.Fatal(err)
+		}
+
+		interrupted, err := st.Deliveries.ListByStatus(ctx, models.DeliveryInterrupted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(interrupted) != 1 || interrupted[0].Post != "generated post" || interrupted[0].Payload == "" {
+			t.Errorf("interrupted deliveries = %+v", interrupted)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package usage
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Plan — квоты тарифа. 0 — без ограничения
+type Plan struct {
+	Name              string `json:"name"`
+	DailyAIRequests   int64  `json:"daily_ai_requests"`
+	MonthlyAIRequests int64  `json:"monthly_ai_requests"`
+	MonthlyTokens     int64  `json:"monthly_tokens"`
+	DailyPosts        int64  `json:"daily_posts"`
+	MonthlyPosts      int64  `json:"monthly_posts"`
+}
+
+// builtinPlans — тарифы по умолчанию; USAGE_PLANS может их изменить или добавить новые
+var builtinPlans = map[string]Plan{
+	"free": {
+		DailyAIRequests:   50,
+		MonthlyAIRequests: 1000,
+		MonthlyTokens:     1000000,
+	},
+	"pro": {
+		DailyAIRequests:   500,
+		MonthlyAIRequests: 10000,
+		MonthlyTokens:     10000000,
+	},
+	"unlimited": {},
+}
+
+var (
+	plansOnce sync.Once
+	plans     map[string]Plan
+)
+
+// loadPlans читает тарифы из USAGE_PLANS (JSON объект "имя": {квоты})
+// поверх встроенных
+func loadPlans() map[string]Plan {
+	result := make(map[string]Plan, len(builtinPlans))
+	for name, plan := range builtinPlans {
+		plan.Name = name
+		result[name] = plan
+	}
+
+	if value := os.Getenv("USAGE_PLANS"); value != "" {
+		var custom map[string]Plan
+		if err := json.Unmarshal([]byte(value), &custom); err != nil {
+			slog.Warn("Invalid USAGE_PLANS, using built-in plans", "error", err)
+			return result
+		}
+		for name, plan := range custom {
+			plan.Name = name
+			result[name] = plan
+		}
+	}
+	return result
+}
+
+// Plans возвращает все тарифы, отсортированные по имени
+func Plans() []Plan {
+	plansOnce.Do(func() { plans = loadPlans() })
+
+	list := make([]Plan, 0, len(plans))
+	for _, plan := range plans {
+		list = append(list, plan)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Lookup находит тариф по имени
+func Lookup(name string) (Plan, bool) {
+	plansOnce.Do(func() { plans = loadPlans() })
+
+	plan, ok := plans[name]
+	return plan, ok
+}
+
+// DefaultPlanName — тариф пользователей без назначенного тарифа (USAGE_DEFAULT_PLAN, по умолчанию free)
+func DefaultPlanName() string {
+	if name := os.Getenv("USAGE_DEFAULT_PLAN"); name != "" {
+		return name
+	}
+	return "free"
+}
+
+// PlanFor возвращает тариф пользователя. Неизвестный тариф не ограничивает
+// генерацию, но логируется: это ошибка конфигурации, а не вина пользователя
+func PlanFor(name string) Plan {
+	if name == "" {
+		name = DefaultPlanName()
+	}
+	plan, ok := Lookup(name)
+	if !ok {
+		slog.Warn("Unknown usage plan, quotas are not enforced", "plan", name)
+		return Plan{Name: name}
+	}
+	return plan
+}
@@ -0,0 +1,139 @@
+package usage
+
+import (
+	"commitcaster/internal/models"
+	"commitcaster/internal/store"
+	"context"
+	"fmt"
+	"time"
+)
+
+// DayStart возвращает начало дня (UTC)
+func DayStart(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// MonthStart возвращает начало месяца (UTC)
+func MonthStart(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Record прибавляет показатели к дневному и месячному счётчикам пользователя.
+// Увеличение выполняется атомарным UPSERT, параллельные доставки не теряют значения
+func Record(ctx context.Context, counters store.UsageStore, userID uint, counts models.UsageCounts, now time.Time) error {
+	periods := map[string]time.Time{
+		models.UsagePeriodDay:   DayStart(now),
+		models.UsagePeriodMonth: MonthStart(now),
+	}
+
+	for period, start := range periods {
+		if err := counters.Add(ctx, userID, period, start, counts, now); err != nil {
+			return fmt.Errorf("record usage: %w", err)
+		}
+	}
+	return nil
+}
+
+// Current возвращает счётчики пользователя за текущие день и месяц
+func Current(ctx context.Context, counters store.UsageStore, userID uint, now time.Time) (day, month models.UsageCounter, err error) {
+	day, err = counters.Get(ctx, userID, models.UsagePeriodDay, DayStart(now))
+	if err != nil {
+		return day, month, err
+	}
+	month, err = counters.Get(ctx, userID, models.UsagePeriodMonth, MonthStart(now))
+	return day, month, err
+}
+
+// QuotaError — квота тарифа исчерпана, генерация приостановлена до ResetsAt
+type QuotaError struct {
+	Quota    string    `json:"quota"`
+	Used     int64     `json:"used"`
+	Limit    int64     `json:"limit"`
+	ResetsAt time.Time `json:"resets_at"`
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s quota exceeded (%d/%d), generation paused until %s",
+		e.Quota, e.Used, e.Limit, e.ResetsAt.Format(time.RFC3339))
+}
+
+// Check проверяет, что до конца периода у пользователя осталась квота на генерацию
+func Check(plan Plan, day, month models.UsageCounts, now time.Time) *QuotaError {
+	nextDay := DayStart(now).AddDate(0, 0, 1)
+	nextMonth := MonthStart(now).AddDate(0, 1, 0)
+
+	// Месячные квоты первыми: они дольше блокируют генерацию
+	checks := []struct {
+		quota    string
+		used     int64
+		limit    int64
+		resetsAt time.Time
+	}{
+		{"monthly_ai_requests", month.AIRequests, plan.MonthlyAIRequests, nextMonth},
+		{"monthly_tokens", month.Tokens(), plan.MonthlyTokens, nextMonth},
+		{"monthly_posts", month.Posts, plan.MonthlyPosts, nextMonth},
+		{"daily_ai_requests", day.AIRequests, plan.DailyAIRequests, nextDay},
+		{"daily_posts", day.Posts, plan.DailyPosts, nextDay},
+	}
+	for _, c := range checks {
+		if c.limit > 0 && c.used >= c.limit {
+			return &QuotaError{Quota: c.quota, Used: c.used, Limit: c.limit, ResetsAt: c.resetsAt}
+		}
+	}
+	return nil
+}
+
+// Reserve проверяет квоты тарифа и резервирует AI запрос: дневной и месячный счётчики
+// ai_requests увеличиваются атомарно и только если квота не исчерпана, поэтому
+// параллельные доставки не превышают её. Квоты токенов и постов проверяются по
+// текущим счётчикам: их расход известен только после генерации. Если генерация
+// не удалась, резерв освобождается Release с тем же now
+func Reserve(ctx context.Context, counters store.UsageStore, userID uint, planName string, now time.Time) (*QuotaError, error) {
+	plan := PlanFor(planName)
+	day, month, err := Current(ctx, counters, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	if quotaErr := Check(plan, day.UsageCounts, month.UsageCounts, now); quotaErr != nil {
+		return quotaErr, nil
+	}
+
+	monthStart := MonthStart(now)
+	reserved, err := counters.ReserveAIRequest(ctx, userID, models.UsagePeriodMonth, monthStart, plan.MonthlyAIRequests, now)
+	if err != nil {
+		return nil, fmt.Errorf("reserve usage: %w", err)
+	}
+	if !reserved {
+		return &QuotaError{Quota: "monthly_ai_requests", Used: plan.MonthlyAIRequests, Limit: plan.MonthlyAIRequests, ResetsAt: monthStart.AddDate(0, 1, 0)}, nil
+	}
+
+	reserved, err = counters.ReserveAIRequest(ctx, userID, models.UsagePeriodDay, DayStart(now), plan.DailyAIRequests, now)
+	if err == nil && reserved {
+		return nil, nil
+	}
+
+	// Дневная квота исчерпана: месячный резерв возвращается
+	if releaseErr := counters.Add(ctx, userID, models.UsagePeriodMonth, monthStart, models.UsageCounts{AIRequests: -1}, now); err == nil {
+		err = releaseErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reserve usage: %w", err)
+	}
+	return &QuotaError{Quota: "daily_ai_requests", Used: plan.DailyAIRequests, Limit: plan.DailyAIRequests, ResetsAt: DayStart(now).AddDate(0, 0, 1)}, nil
+}
+
+// Release освобождает AI запрос, зарезервированный Reserve в момент now
+func Release(ctx context.Context, counters store.UsageStore, userID uint, now time.Time) error {
+	return Record(ctx, counters, userID, models.UsageCounts{AIRequests: -1}, now)
+}
+
+// CheckUser загружает счётчики пользователя и проверяет квоты тарифа
+func CheckUser(ctx context.Context, counters store.UsageStore, userID uint, planName string, now time.Time) (*QuotaError, error) {
+	day, month, err := Current(ctx, counters, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	return Check(PlanFor(planName), day.UsageCounts, month.UsageCounts, now), nil
+}
@@ -0,0 +1,178 @@
+package usage
+
+import (
+	"commitcaster/internal/models"
+	"commitcaster/internal/store"
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	now := time.Date(2026, 3, 15, 10, 0, 0, 0, time.UTC)
+	plan := Plan{DailyAIRequests: 10, MonthlyAIRequests: 100, MonthlyTokens: 1000, DailyPosts: 5}
+
+	tests := []struct {
+		name     string
+		day      models.UsageCounts
+		month    models.UsageCounts
+		quota    string
+		resetsAt time.Time
+	}{
+		{"within quotas", models.UsageCounts{AIRequests: 9, Posts: 4}, models.UsageCounts{AIRequests: 99, PromptTokens: 999}, "", time.Time{}},
+		{"daily ai requests", models.UsageCounts{AIRequests: 10}, models.UsageCounts{AIRequests: 10}, "daily_ai_requests", time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{"daily posts", models.UsageCounts{Posts: 5}, models.UsageCounts{}, "daily_posts", time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{"monthly tokens", models.UsageCounts{}, models.UsageCounts{PromptTokens: 600, CompletionTokens: 400}, "monthly_tokens", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		// Месячная квота важнее дневной: она дольше блокирует генерацию
+		{"monthly before daily", models.UsageCounts{AIRequests: 10}, models.UsageCounts{AIRequests: 100}, "monthly_ai_requests", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(plan, tt.day, tt.month, now)
+			if tt.quota == "" {
+				if err != nil {
+					t.Fatalf("Check() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Quota != tt.quota {
+				t.Fatalf("Check() = %v, want %s", err, tt.quota)
+			}
+			if !err.ResetsAt.Equal(tt.resetsAt) {
+				t.Errorf("resets at %v, want %v", err.ResetsAt, tt.resetsAt)
+			}
+		})
+	}
+
+	if err := Check(Plan{}, models.UsageCounts{AIRequests: 1e6}, models.UsageCounts{AIRequests: 1e6}, now); err != nil {
+		t.Errorf("plan without quotas: %v", err)
+	}
+}
+
+func TestPeriodStartIsUTC(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	now := time.Date(2026, 4, 1, 1, 0, 0, 0, moscow) // 31 марта в UTC
+
+	if got, want := DayStart(now), time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("DayStart() = %v, want %v", got, want)
+	}
+	if got, want := MonthStart(now), time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("MonthStart() = %v, want %v", got, want)
+	}
+}
+
+func TestReserveIsAtomic(t *testing.T) {
+	ctx := context.Background()
+	counters := store.NewMemory().Usage
+	now := time.Now()
+
+	// До дневной квоты free (50) остался один запрос
+	if err := Record(ctx, counters, 1, models.UsageCounts{AIRequests: 49}, now); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		reserved int
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			quotaErr, err := Reserve(ctx, counters, 1, "free", now)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if quotaErr == nil {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if reserved != 1 {
+		t.Fatalf("reserved = %d, want 1", reserved)
+	}
+	day, month, err := Current(ctx, counters, 1, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Отказы по дневной квоте не оставляют резерва в месячном счётчике
+	if day.AIRequests != 50 || month.AIRequests != 50 {
+		t.Errorf("ai requests: day %d, month %d, want 50 and 50", day.AIRequests, month.AIRequests)
+	}
+}
+
+func TestReserveAndRelease(t *testing.T) {
+	ctx := context.Background()
+	counters := store.NewMemory().Usage
+	now := time.Now()
+
+	quotaErr, err := Reserve(ctx, counters, 1, "free", now)
+	if err != nil || quotaErr != nil {
+		t.Fatalf("Reserve() = %v, %v", quotaErr, err)
+	}
+	if err := Release(ctx, counters, 1, now); err != nil {
+		t.Fatal(err)
+	}
+
+	day, month, err := Current(ctx, counters, 1, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if day.AIRequests != 0 || month.AIRequests != 0 {
+		t.Errorf("ai requests after release: day %d, month %d", day.AIRequests, month.AIRequests)
+	}
+}
+
+func TestReserveChecksTokenQuota(t *testing.T) {
+	ctx := context.Background()
+	counters := store.NewMemory().Usage
+	now := time.Now()
+
+	if err := Record(ctx, counters, 1, models.UsageCounts{PromptTokens: 1000000}, now); err != nil {
+		t.Fatal(err)
+	}
+	quotaErr, err := Reserve(ctx, counters, 1, "free", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quotaErr == nil || quotaErr.Quota != "monthly_tokens" {
+		t.Fatalf("Reserve() = %v, want monthly_tokens", quotaErr)
+	}
+	if day, _, _ := Current(ctx, counters, 1, now); day.AIRequests != 0 {
+		t.Errorf("rejected reservation counted: %d", day.AIRequests)
+	}
+}
+
+func TestPlanForUnknownPlan(t *testing.T) {
+	plan := PlanFor("no-such-plan")
+	if err := Check(plan, models.UsageCounts{AIRequests: 1e6}, models.UsageCounts{AIRequests: 1e6}, time.Now()); err != nil {
+		t.Errorf("unknown plan enforces quotas: %v", err)
+	}
+}
+
+func TestLoadPlans(t *testing.T) {
+	t.Setenv("USAGE_PLANS", `{"team": {"daily_ai_requests": 200}, "free": {"daily_ai_requests": 5}}`)
+	plans := loadPlans()
+
+	if plans["team"].Name != "team" || plans["team"].DailyAIRequests != 200 {
+		t.Errorf("team plan = %+v", plans["team"])
+	}
+	if plans["free"].DailyAIRequests != 5 || plans["free"].MonthlyAIRequests != 0 {
+		t.Errorf("free plan not replaced: %+v", plans["free"])
+	}
+	if _, ok := plans["pro"]; !ok {
+		t.Error("built-in plan lost")
+	}
+
+	t.Setenv("USAGE_PLANS", "not json")
+	if plans := loadPlans(); plans["free"].DailyAIRequests != 50 {
+		t.Errorf("invalid USAGE_PLANS: free plan = %+v, want built-in", plans["free"])
+	}
+}